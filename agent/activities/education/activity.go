@@ -0,0 +1,60 @@
+// Package education provides education verification activities
+package education
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"encore.app/agent/types"
+)
+
+// Request represents an education verification request
+type Request struct {
+	CandidateName string
+	Education     []types.Education // qualifications to verify, from the research profile
+}
+
+// Result represents the result of an education verification
+type Result struct {
+	Verified   bool
+	VerifiedAt time.Time
+	VerifiedBy string
+	Notes      string
+	Education  []types.Education
+}
+
+// Activity handles education verification operations
+type Activity struct {
+	config types.Config
+}
+
+// NewActivity creates a new education verification activity
+func NewActivity(config types.Config) *Activity {
+	return &Activity{
+		config: config,
+	}
+}
+
+// VerifyEducation verifies a candidate's qualifications with their institutions
+func (a *Activity) VerifyEducation(ctx context.Context, req *Request) (*Result, error) {
+	if req.CandidateName == "" {
+		return nil, fmt.Errorf("candidate name is required")
+	}
+
+	// Validate at least one qualification
+	if len(req.Education) == 0 {
+		return nil, fmt.Errorf("at least one qualification is required")
+	}
+
+	// TODO: Implement actual education verification. Until then no tier
+	// includes the education check, which only screenings started on an
+	// earlier tier version still run.
+	return &Result{
+		Verified:   false,
+		VerifiedAt: time.Now(),
+		VerifiedBy: "system",
+		Notes:      "Verification pending",
+		Education:  req.Education,
+	}, nil
+}
@@ -0,0 +1,46 @@
+// Package verification provides employment verification email activities
+package verification
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"encore.app/agent/types"
+	"encore.app/email"
+)
+
+// Activity handles employment verification email operations
+type Activity struct{}
+
+// NewActivity creates a new verification email activity instance
+func NewActivity(_ interface{}) *Activity {
+	return &Activity{}
+}
+
+// SendVerificationRequest sends an employment verification request to a previous employer
+func (a *Activity) SendVerificationRequest(ctx context.Context, to, from, subject string, data map[string]interface{}) (*types.Result, error) {
+	// Convert data to string map as required by email service
+	templateData := make(map[string]string)
+	for k, v := range data {
+		templateData[k] = fmt.Sprintf("%v", v)
+	}
+
+	// Only pass required data, defaults are handled by email service
+	resp, err := email.Send(ctx, &email.SendParams{
+		To:           to,
+		From:         from,
+		Subject:      subject,
+		TemplateID:   "screening-verification-request-v1",
+		TemplateData: templateData,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send verification request: %w", err)
+	}
+
+	return &types.Result{
+		Sent:      true,
+		MessageID: resp.MessageID,
+		SentAt:    time.Now(),
+	}, nil
+}
@@ -0,0 +1,204 @@
+// Package connector queries automated employment and income verification
+// sources, such as payroll data exchanges, on behalf of the employment
+// activities. Each source is a Connector; a Chain asks them in turn, retrying
+// transient failures, until one has a record of the candidate.
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"encore.app/agent/types"
+	"encore.app/vault"
+	"encore.dev/rlog"
+)
+
+var (
+	// ErrNoRecord is returned when a source has no record of the candidate
+	// at the employer
+	ErrNoRecord = errors.New("no record")
+
+	// ErrRejected is returned when a source rejects a request, such as for
+	// bad credentials. Retrying won't help.
+	ErrRejected = errors.New("request rejected")
+)
+
+// Subject identifies the candidate to a source
+type Subject struct {
+	FullName string
+	SSN      string
+}
+
+// RevealSubject reads the candidate's SSN from the vault to identify them to
+// sources. The read is audited with the given purpose.
+func RevealSubject(ctx context.Context, fullName, piiRef, purpose string) (Subject, error) {
+	if piiRef == "" {
+		return Subject{}, fmt.Errorf("candidate details reference is required")
+	}
+	resp, err := vault.Reveal(ctx, piiRef, &vault.RevealParams{
+		Actor:   "agent",
+		Purpose: purpose,
+	})
+	if err != nil {
+		return Subject{}, fmt.Errorf("reveal candidate details: %w", err)
+	}
+	return Subject{FullName: fullName, SSN: resp.Fields[types.PIIFieldSSN]}, nil
+}
+
+// EmploymentQuery asks a source whether the candidate worked at an employer
+type EmploymentQuery struct {
+	Subject      Subject
+	EmployerName string
+}
+
+// EmploymentRecord is a source's record of the candidate's employment
+type EmploymentRecord struct {
+	Source       string // name of the connector that answered
+	EmployerName string
+	Position     string
+	StartDate    string // YYYY-MM-DD
+	EndDate      string // YYYY-MM-DD, empty while still employed
+	Current      bool
+}
+
+// IncomeQuery asks a source for the candidate's income at an employer
+type IncomeQuery struct {
+	Subject      Subject
+	EmployerName string
+	Year         int
+}
+
+// IncomeRecord is a source's record of the candidate's income, normalized
+// to an annual amount
+type IncomeRecord struct {
+	Source       string // name of the connector that answered
+	EmployerName string
+	Year         int
+	Annual       float64
+	Currency     string // ISO 4217 code
+}
+
+// Connector is an automated verification source
+type Connector interface {
+	// Name identifies the source in results and logs
+	Name() string
+	// Employment returns the candidate's employment record, or ErrNoRecord
+	Employment(ctx context.Context, q *EmploymentQuery) (*EmploymentRecord, error)
+	// Income returns the candidate's income record, or ErrNoRecord
+	Income(ctx context.Context, q *IncomeQuery) (*IncomeRecord, error)
+}
+
+// RetryPolicy controls how often a source is retried after a transient failure
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration // doubled after each attempt
+}
+
+// DefaultRetryPolicy is the retry policy of chains built from config
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: 500 * time.Millisecond}
+
+// Chain asks each of its connectors in turn until one has a record
+type Chain struct {
+	connectors []Connector
+	retry      RetryPolicy
+}
+
+// NewChain creates a chain asking connectors in the given order
+func NewChain(retry RetryPolicy, connectors ...Connector) *Chain {
+	return &Chain{connectors: connectors, retry: retry}
+}
+
+// FromConfig creates a chain of HTTP connectors, one for each endpoint in
+// the config, asked in name order. Each connector authenticates with the key
+// of the same name.
+func FromConfig(config types.Config) *Chain {
+	names := make([]string, 0, len(config.Endpoints))
+	for name := range config.Endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	connectors := make([]Connector, 0, len(names))
+	for _, name := range names {
+		connectors = append(connectors, NewHTTPConnector(name, config.Endpoints[name], config.Keys[name], nil))
+	}
+	return NewChain(DefaultRetryPolicy, connectors...)
+}
+
+// Len returns the number of connectors in the chain
+func (c *Chain) Len() int {
+	return len(c.connectors)
+}
+
+// Employment returns the first employment record found. A source returning
+// no record and no error has no record. It returns ErrNoRecord, joined with
+// any failures, if no source answers.
+func (c *Chain) Employment(ctx context.Context, q *EmploymentQuery) (*EmploymentRecord, error) {
+	var failures []error
+	for _, conn := range c.connectors {
+		var record *EmploymentRecord
+		err := c.do(ctx, conn, func() (err error) {
+			record, err = conn.Employment(ctx, q)
+			if err == nil && record == nil {
+				return ErrNoRecord
+			}
+			return err
+		})
+		if err == nil {
+			record.Source = conn.Name()
+			return record, nil
+		}
+		if !errors.Is(err, ErrNoRecord) {
+			failures = append(failures, fmt.Errorf("%s: %w", conn.Name(), err))
+		}
+	}
+	return nil, errors.Join(append([]error{ErrNoRecord}, failures...)...)
+}
+
+// Income returns the first income record found. A source returning no
+// record and no error has no record. It returns ErrNoRecord, joined with any
+// failures, if no source answers.
+func (c *Chain) Income(ctx context.Context, q *IncomeQuery) (*IncomeRecord, error) {
+	var failures []error
+	for _, conn := range c.connectors {
+		var record *IncomeRecord
+		err := c.do(ctx, conn, func() (err error) {
+			record, err = conn.Income(ctx, q)
+			if err == nil && record == nil {
+				return ErrNoRecord
+			}
+			return err
+		})
+		if err == nil {
+			record.Source = conn.Name()
+			return record, nil
+		}
+		if !errors.Is(err, ErrNoRecord) {
+			failures = append(failures, fmt.Errorf("%s: %w", conn.Name(), err))
+		}
+	}
+	return nil, errors.Join(append([]error{ErrNoRecord}, failures...)...)
+}
+
+// do calls fn, retrying transient failures with exponential backoff
+func (c *Chain) do(ctx context.Context, conn Connector, fn func() error) error {
+	backoff := c.retry.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || errors.Is(err, ErrNoRecord) || errors.Is(err, ErrRejected) || attempt >= c.retry.MaxAttempts {
+			return err
+		}
+
+		rlog.Warn("Verification source failed, retrying", "source", conn.Name(), "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
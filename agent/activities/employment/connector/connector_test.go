@@ -0,0 +1,230 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testRetry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+var testSubject = Subject{FullName: "Jane Doe", SSN: "123-45-6789"}
+
+func TestHTTPConnectorEmployment(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          string
+		failures     int
+		records      map[string]EmploymentResponse
+		wantErr      error
+		wantRequests int
+	}{
+		{
+			name:         "found",
+			key:          "secret",
+			records:      map[string]EmploymentResponse{testSubject.SSN: {Employer: "Acme", Position: "Engineer", StartDate: "2020-01-06"}},
+			wantRequests: 1,
+		},
+		{
+			name:         "retries 503",
+			key:          "secret",
+			failures:     2,
+			records:      map[string]EmploymentResponse{testSubject.SSN: {Employer: "Acme", StartDate: "2020-01-06"}},
+			wantRequests: 3,
+		},
+		{
+			name:         "gives up after max attempts",
+			key:          "secret",
+			failures:     5,
+			records:      map[string]EmploymentResponse{testSubject.SSN: {Employer: "Acme"}},
+			wantErr:      ErrNoRecord, // joined with the failure
+			wantRequests: 3,
+		},
+		{
+			name:         "no record on 404",
+			key:          "secret",
+			wantErr:      ErrNoRecord,
+			wantRequests: 1,
+		},
+		{
+			name:         "rejected on 401",
+			key:          "wrong",
+			records:      map[string]EmploymentResponse{testSubject.SSN: {Employer: "Acme"}},
+			wantErr:      ErrRejected,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &FakeServer{Key: "secret", Failures: tt.failures, Employment: tt.records}
+			srv := fake.Start()
+			defer srv.Close()
+
+			chain := NewChain(testRetry, NewHTTPConnector("fake", srv.URL, tt.key, nil))
+			record, err := chain.Employment(context.Background(), &EmploymentQuery{Subject: testSubject, EmployerName: "Acme"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else {
+				if record.Source != "fake" || record.EmployerName != "Acme" {
+					t.Errorf("record = %+v", record)
+				}
+				if !record.Current {
+					t.Error("record without end date should be current")
+				}
+			}
+			if got := fake.Requests(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestHTTPConnectorIncomePeriods(t *testing.T) {
+	tests := []struct {
+		period  string
+		amount  float64
+		want    float64
+		wantErr bool
+	}{
+		{"annual", 85000, 85000, false},
+		{"monthly", 5000, 60000, false},
+		{"biweekly", 2000, 52000, false},
+		{"weekly", 1000, 52000, false},
+		{"hourly", 25, 52000, false},
+		{"daily", 200, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			fake := &FakeServer{Income: map[string]IncomeResponse{
+				testSubject.SSN: {Employer: "Acme", Year: 2024, Amount: tt.amount, Currency: "usd", Period: tt.period},
+			}}
+			srv := fake.Start()
+			defer srv.Close()
+
+			conn := NewHTTPConnector("fake", srv.URL, "", nil)
+			record, err := conn.Income(context.Background(), &IncomeQuery{Subject: testSubject, EmployerName: "Acme", Year: 2024})
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if record.Annual != tt.want {
+				t.Errorf("annual = %v, want %v", record.Annual, tt.want)
+			}
+			if record.Currency != "USD" {
+				t.Errorf("currency = %q, want USD", record.Currency)
+			}
+		})
+	}
+}
+
+// stubConnector answers from fixed records, counting queries
+type stubConnector struct {
+	name       string
+	employment *EmploymentRecord
+	income     *IncomeRecord
+	err        error
+	queries    int
+}
+
+func (c *stubConnector) Name() string { return c.name }
+
+func (c *stubConnector) Employment(context.Context, *EmploymentQuery) (*EmploymentRecord, error) {
+	c.queries++
+	return c.employment, c.err
+}
+
+func (c *stubConnector) Income(context.Context, *IncomeQuery) (*IncomeRecord, error) {
+	c.queries++
+	return c.income, c.err
+}
+
+func TestChainFallThrough(t *testing.T) {
+	tests := []struct {
+		name       string
+		connectors []*stubConnector
+		wantSource string
+		wantErr    error
+	}{
+		{
+			name: "first answers",
+			connectors: []*stubConnector{
+				{name: "a", employment: &EmploymentRecord{EmployerName: "Acme"}, income: &IncomeRecord{Annual: 1}},
+				{name: "b", employment: &EmploymentRecord{EmployerName: "Acme"}, income: &IncomeRecord{Annual: 1}},
+			},
+			wantSource: "a",
+		},
+		{
+			name: "falls through no record",
+			connectors: []*stubConnector{
+				{name: "a", err: ErrNoRecord},
+				{name: "b", employment: &EmploymentRecord{EmployerName: "Acme"}, income: &IncomeRecord{Annual: 1}},
+			},
+			wantSource: "b",
+		},
+		{
+			name: "falls through rejection",
+			connectors: []*stubConnector{
+				{name: "a", err: ErrRejected},
+				{name: "b", employment: &EmploymentRecord{EmployerName: "Acme"}, income: &IncomeRecord{Annual: 1}},
+			},
+			wantSource: "b",
+		},
+		{
+			name: "nil record is no record",
+			connectors: []*stubConnector{
+				{name: "a"},
+				{name: "b", employment: &EmploymentRecord{EmployerName: "Acme"}, income: &IncomeRecord{Annual: 1}},
+			},
+			wantSource: "b",
+		},
+		{
+			name: "none answer",
+			connectors: []*stubConnector{
+				{name: "a", err: ErrNoRecord},
+				{name: "b"},
+			},
+			wantErr: ErrNoRecord,
+		},
+		{
+			name:    "empty chain",
+			wantErr: ErrNoRecord,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connectors := make([]Connector, len(tt.connectors))
+			for i, c := range tt.connectors {
+				connectors[i] = c
+			}
+			chain := NewChain(testRetry, connectors...)
+
+			employment, err := chain.Employment(context.Background(), &EmploymentQuery{Subject: testSubject})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("employment error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || employment.Source != tt.wantSource {
+				t.Errorf("employment = %+v, %v, want source %q", employment, err, tt.wantSource)
+			}
+
+			income, err := chain.Income(context.Background(), &IncomeQuery{Subject: testSubject})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("income error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || income.Source != tt.wantSource {
+				t.Errorf("income = %+v, %v, want source %q", income, err, tt.wantSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package connector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// FakeServer is a stand-in verification source speaking the HTTP
+// connector's protocol, for tests and local development. Records are keyed
+// by SSN.
+type FakeServer struct {
+	Key        string // required bearer token, any if empty
+	Employment map[string]EmploymentResponse
+	Income     map[string]IncomeResponse
+
+	// Failures is the number of requests to answer with 503 before
+	// answering normally, to exercise retries
+	Failures int
+
+	mu       sync.Mutex
+	requests int
+}
+
+// Start starts serving on a local port. Close the returned server when done;
+// its URL is the connector endpoint.
+func (f *FakeServer) Start() *httptest.Server {
+	return httptest.NewServer(f)
+}
+
+// Requests returns how many requests the server has received
+func (f *FakeServer) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// ServeHTTP answers employment and income queries
+func (f *FakeServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	f.requests++
+	fail := f.requests <= f.Failures
+	f.mu.Unlock()
+
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if f.Key != "" && req.Header.Get("Authorization") != "Bearer "+f.Key {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if fail {
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	var query struct {
+		SSN string `json:"ssn"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&query); err != nil {
+		http.Error(w, "Failed to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	var record interface{}
+	var ok bool
+	switch strings.TrimSuffix(req.URL.Path, "/") {
+	case "/v1/employment":
+		record, ok = f.Employment[query.SSN]
+	case "/v1/income":
+		record, ok = f.Income[query.SSN]
+	default:
+		http.Error(w, "Unknown query", http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		http.Error(w, "No record", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
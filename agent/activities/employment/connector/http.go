@@ -0,0 +1,167 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// EmploymentRequest is the body of an HTTP connector's employment request
+type EmploymentRequest struct {
+	SSN      string `json:"ssn"`
+	FullName string `json:"fullName"`
+	Employer string `json:"employer"`
+}
+
+// EmploymentResponse is the body of a source's employment answer
+type EmploymentResponse struct {
+	Employer  string `json:"employer"`
+	Position  string `json:"position"`
+	StartDate string `json:"startDate"` // YYYY-MM-DD
+	EndDate   string `json:"endDate"`   // YYYY-MM-DD, empty while still employed
+}
+
+// IncomeRequest is the body of an HTTP connector's income request
+type IncomeRequest struct {
+	SSN      string `json:"ssn"`
+	FullName string `json:"fullName"`
+	Employer string `json:"employer"`
+	Year     int    `json:"year"`
+}
+
+// IncomeResponse is the body of a source's income answer
+type IncomeResponse struct {
+	Employer string  `json:"employer"`
+	Year     int     `json:"year"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+	Period   string  `json:"period"` // annual, monthly, biweekly, weekly or hourly
+}
+
+// periodsPerYear converts income periods to annual amounts. Hourly pay
+// assumes full-time hours.
+var periodsPerYear = map[string]float64{
+	"annual":   1,
+	"monthly":  12,
+	"biweekly": 26,
+	"weekly":   52,
+	"hourly":   2080,
+}
+
+// HTTPConnector is a Connector for sources speaking JSON over HTTP. It posts
+// queries to the endpoint's /v1/employment and /v1/income paths with the key
+// as a bearer token; a 404 means the source has no record.
+type HTTPConnector struct {
+	name     string
+	endpoint string
+	key      string
+	client   *http.Client
+}
+
+// NewHTTPConnector creates an HTTP connector, using a default client if none
+// is given
+func NewHTTPConnector(name, endpoint, key string, client *http.Client) *HTTPConnector {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &HTTPConnector{
+		name:     name,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		key:      key,
+		client:   client,
+	}
+}
+
+// Name returns the connector's name
+func (c *HTTPConnector) Name() string {
+	return c.name
+}
+
+// Employment asks the source for the candidate's employment record
+func (c *HTTPConnector) Employment(ctx context.Context, q *EmploymentQuery) (*EmploymentRecord, error) {
+	var resp EmploymentResponse
+	err := c.post(ctx, "/v1/employment", &EmploymentRequest{
+		SSN:      q.Subject.SSN,
+		FullName: q.Subject.FullName,
+		Employer: q.EmployerName,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, date := range []string{resp.StartDate, resp.EndDate} {
+		if _, err := time.Parse(time.DateOnly, date); date != "" && err != nil {
+			return nil, fmt.Errorf("invalid date in response: %q", date)
+		}
+	}
+	return &EmploymentRecord{
+		EmployerName: resp.Employer,
+		Position:     resp.Position,
+		StartDate:    resp.StartDate,
+		EndDate:      resp.EndDate,
+		Current:      resp.EndDate == "",
+	}, nil
+}
+
+// Income asks the source for the candidate's income record
+func (c *HTTPConnector) Income(ctx context.Context, q *IncomeQuery) (*IncomeRecord, error) {
+	var resp IncomeResponse
+	err := c.post(ctx, "/v1/income", &IncomeRequest{
+		SSN:      q.Subject.SSN,
+		FullName: q.Subject.FullName,
+		Employer: q.EmployerName,
+		Year:     q.Year,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	perYear, ok := periodsPerYear[resp.Period]
+	if !ok {
+		return nil, fmt.Errorf("unknown income period in response: %q", resp.Period)
+	}
+	return &IncomeRecord{
+		EmployerName: resp.Employer,
+		Year:         resp.Year,
+		Annual:       resp.Amount * perYear,
+		Currency:     strings.ToUpper(resp.Currency),
+	}, nil
+}
+
+// post sends a query to the source, decoding its answer into out
+func (c *HTTPConnector) post(ctx context.Context, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.key)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNoRecord
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("source unavailable: %s", resp.Status)
+	default:
+		return fmt.Errorf("%w: %s", ErrRejected, resp.Status)
+	}
+}
@@ -3,55 +3,124 @@ package history
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"encore.app/agent/activities/employment/connector"
 	"encore.app/agent/types"
+	"go.temporal.io/sdk/activity"
 )
 
 // Request represents an employment history verification request
 type Request struct {
 	EmployerName    string
 	EmployerContact string
-	Profile         types.ResearchProfile
+	CandidateName   string
+	PIIRef          string                // vault reference identifying the candidate to sources
+	Profile         types.ResearchProfile // researched profile to compare against, if any
 }
 
-// Result represents the result of an employment history verification
+// Result represents the result of an employment history verification.
+// Source is empty when no automated source answered, and the employer is
+// asked by email instead.
 type Result struct {
-	Verified bool
-	Profile  types.ResearchProfile
+	types.VerificationResult
+	Source        string
+	Employment    *connector.EmploymentRecord
+	Discrepancies []string // differences from the researched profile
+	Profile       types.ResearchProfile
 }
 
 // Activity handles employment history verification operations
 type Activity struct {
 	config types.Config
+	chain  *connector.Chain
+	reveal func(ctx context.Context, fullName, piiRef, purpose string) (connector.Subject, error)
 }
 
-// NewActivity creates a new employment history verification activity
+// NewActivity creates a new employment history verification activity,
+// querying the sources in the config
 func NewActivity(config types.Config) *Activity {
 	return &Activity{
 		config: config,
+		chain:  connector.FromConfig(config),
+		reveal: connector.RevealSubject,
 	}
 }
 
 // CheckEmploymentHistory checks employment history with a previous employer
+// through the automated sources
 func (a *Activity) CheckEmploymentHistory(ctx context.Context, req *Request) (*Result, error) {
 	if req.EmployerName == "" || req.EmployerContact == "" {
 		return nil, fmt.Errorf("employer name and contact information are required")
 	}
+	if req.CandidateName == "" {
+		return nil, fmt.Errorf("candidate name is required")
+	}
+
+	result := &Result{
+		VerificationResult: types.VerificationResult{VerifiedAt: time.Now()},
+		Profile:            req.Profile,
+	}
+	if a.chain.Len() == 0 {
+		result.Notes = "No automated source configured"
+		return result, nil
+	}
 
-	// Validate research profile
-	if req.Profile.ID == "" || req.Profile.FullName == "" {
-		return nil, fmt.Errorf("research profile ID and full name are required")
+	subject, err := a.reveal(ctx, req.CandidateName, req.PIIRef, "employment verification")
+	if err != nil {
+		return nil, err
+	}
+	record, err := a.chain.Employment(ctx, &connector.EmploymentQuery{
+		Subject:      subject,
+		EmployerName: req.EmployerName,
+	})
+	if errors.Is(err, connector.ErrNoRecord) {
+		activity.GetLogger(ctx).Info("No automated source answered", "employer", req.EmployerName, "error", err)
+		result.Notes = "No automated source has a record"
+		return result, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to query employment sources: %w", err)
 	}
 
-	// Validate at least one current experience
-	if len(req.Profile.CurrentExperiences) == 0 {
-		return nil, fmt.Errorf("at least one current experience is required")
+	result.Verified = true
+	result.VerifiedBy = record.Source
+	result.Source = record.Source
+	result.Employment = record
+	result.Discrepancies = compareProfile(record, &req.Profile)
+	if len(result.Discrepancies) > 0 {
+		result.Notes = "Record differs from the researched profile"
 	}
+	return result, nil
+}
 
-	// TODO: Implement actual employment verification
-	return &Result{
-		Verified: false,
-		Profile:  req.Profile,
-	}, nil
+// compareProfile lists the differences between a source's record and the
+// researched experience at the same employer
+func compareProfile(record *connector.EmploymentRecord, profile *types.ResearchProfile) []string {
+	var experience *types.Experience
+	for _, experiences := range [][]types.Experience{profile.CurrentExperiences, profile.PreviousExperiences} {
+		for i := range experiences {
+			if strings.EqualFold(experiences[i].Company, record.EmployerName) {
+				experience = &experiences[i]
+			}
+		}
+	}
+	if experience == nil {
+		if profile.ID == "" {
+			return nil
+		}
+		return []string{"employer missing from researched profile"}
+	}
+
+	var discrepancies []string
+	if record.Position != "" && !strings.EqualFold(experience.Position, record.Position) {
+		discrepancies = append(discrepancies, fmt.Sprintf("position: researched %q, recorded %q", experience.Position, record.Position))
+	}
+	// Profiles often only give the month an experience started
+	if len(record.StartDate) >= 7 && !experience.StartDate.IsZero() && experience.StartDate.Format("2006-01") != record.StartDate[:7] {
+		discrepancies = append(discrepancies, fmt.Sprintf("start date: researched %s, recorded %s", experience.StartDate.Format("2006-01"), record.StartDate))
+	}
+	return discrepancies
 }
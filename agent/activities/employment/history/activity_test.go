@@ -0,0 +1,128 @@
+package history
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"encore.app/agent/activities/employment/connector"
+	"encore.app/agent/types"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func fakeReveal(_ context.Context, fullName, _, _ string) (connector.Subject, error) {
+	return connector.Subject{FullName: fullName, SSN: "123-45-6789"}, nil
+}
+
+func TestCheckEmploymentHistory(t *testing.T) {
+	tests := []struct {
+		name         string
+		sources      bool
+		records      map[string]connector.EmploymentResponse
+		wantVerified bool
+		wantSource   string
+	}{
+		{
+			name:         "source answers",
+			sources:      true,
+			records:      map[string]connector.EmploymentResponse{"123-45-6789": {Employer: "Acme", Position: "Engineer", StartDate: "2020-01-06"}},
+			wantVerified: true,
+			wantSource:   "fake",
+		},
+		{
+			// No source means the workflow asks the employer by email
+			name:    "no source has a record",
+			sources: true,
+		},
+		{
+			name: "no sources configured",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &connector.FakeServer{Employment: tt.records}
+			srv := fake.Start()
+			defer srv.Close()
+
+			var connectors []connector.Connector
+			if tt.sources {
+				connectors = append(connectors, connector.NewHTTPConnector("fake", srv.URL, "", nil))
+			}
+			a := &Activity{
+				chain:  connector.NewChain(connector.RetryPolicy{MaxAttempts: 1}, connectors...),
+				reveal: fakeReveal,
+			}
+			var env testsuite.WorkflowTestSuite
+			actEnv := env.NewTestActivityEnvironment()
+			actEnv.RegisterActivity(a.CheckEmploymentHistory)
+
+			val, err := actEnv.ExecuteActivity(a.CheckEmploymentHistory, &Request{
+				EmployerName:    "Acme",
+				EmployerContact: "hr@acme.example",
+				CandidateName:   "Jane Doe",
+				PIIRef:          "ref",
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var result Result
+			if err := val.Get(&result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Verified != tt.wantVerified || result.Source != tt.wantSource {
+				t.Errorf("result = %+v, want verified %v, source %q", result, tt.wantVerified, tt.wantSource)
+			}
+			if tt.wantSource == "" && result.Employment != nil {
+				t.Errorf("employment = %+v, want none", result.Employment)
+			}
+		})
+	}
+}
+
+func TestCompareProfile(t *testing.T) {
+	profile := types.ResearchProfile{
+		ID: "profile",
+		PreviousExperiences: []types.Experience{
+			{Company: "Acme", Position: "Engineer", StartDate: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	tests := []struct {
+		name    string
+		record  connector.EmploymentRecord
+		profile types.ResearchProfile
+		want    []string
+	}{
+		{
+			name:    "matches",
+			record:  connector.EmploymentRecord{EmployerName: "ACME", Position: "engineer", StartDate: "2020-01-20"},
+			profile: profile,
+		},
+		{
+			name:    "position and start differ",
+			record:  connector.EmploymentRecord{EmployerName: "Acme", Position: "Manager", StartDate: "2021-03-01"},
+			profile: profile,
+			want: []string{
+				`position: researched "Engineer", recorded "Manager"`,
+				"start date: researched 2020-01, recorded 2021-03-01",
+			},
+		},
+		{
+			name:    "employer missing",
+			record:  connector.EmploymentRecord{EmployerName: "Globex"},
+			profile: profile,
+			want:    []string{"employer missing from researched profile"},
+		},
+		{
+			name:   "no researched profile",
+			record: connector.EmploymentRecord{EmployerName: "Globex"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareProfile(&tt.record, &tt.profile); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareProfile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
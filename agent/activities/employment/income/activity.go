@@ -3,18 +3,28 @@ package income
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
-	"encore.app/agent"
+	"encore.app/agent/activities/employment/connector"
+	"encore.app/agent/types"
+	"go.temporal.io/sdk/activity"
 )
 
 // Request represents an income verification request
 type Request struct {
 	EmployerName    string
 	EmployerContact string
+	CandidateName   string
+	PIIRef          string // vault reference identifying the candidate to sources
 	Period          string // annual, monthly, etc.
 	Year            int
+
+	// Verification is the employer's answer to the emailed verification
+	// request, used when no automated source answers
+	Verification *types.VerificationSubmissionSignal
 }
 
 // Result represents the result of an income verification
@@ -23,6 +33,7 @@ type Result struct {
 	VerifiedAt time.Time
 	VerifiedBy string
 	Notes      string
+	Source     string // automated source that answered, empty if none did
 	Income     float64
 	Currency   string
 	Period     string
@@ -31,31 +42,92 @@ type Result struct {
 
 // Activity handles income verification operations
 type Activity struct {
-	config agent.Config
+	config types.Config
+	chain  *connector.Chain
+	reveal func(ctx context.Context, fullName, piiRef, purpose string) (connector.Subject, error)
 }
 
-// NewActivity creates a new income verification activity
-func NewActivity(config agent.Config) *Activity {
+// NewActivity creates a new income verification activity, querying the
+// sources in the config
+func NewActivity(config types.Config) *Activity {
 	return &Activity{
 		config: config,
+		chain:  connector.FromConfig(config),
+		reveal: connector.RevealSubject,
 	}
 }
 
 // GetIncomeInformation retrieves income information from a previous employer
+// through the automated sources, falling back to the salary the employer
+// gave when verifying by email
 func (a *Activity) GetIncomeInformation(ctx context.Context, req *Request) (*Result, error) {
 	if req.EmployerName == "" || req.EmployerContact == "" {
 		return nil, fmt.Errorf("employer name and contact information are required")
 	}
 
-	// TODO: Implement actual income verification
-	return &Result{
-		Verified:   false,
+	result := &Result{
 		VerifiedAt: time.Now(),
-		VerifiedBy: "system",
-		Notes:      "Verification pending",
-		Income:     0,
 		Currency:   "USD",
 		Period:     req.Period,
 		Year:       req.Year,
-	}, nil
+	}
+
+	if a.chain.Len() > 0 {
+		subject, err := a.reveal(ctx, req.CandidateName, req.PIIRef, "income verification")
+		if err != nil {
+			return nil, err
+		}
+		record, err := a.chain.Income(ctx, &connector.IncomeQuery{
+			Subject:      subject,
+			EmployerName: req.EmployerName,
+			Year:         req.Year,
+		})
+		switch {
+		case err == nil:
+			result.Verified = true
+			result.VerifiedBy = record.Source
+			result.Source = record.Source
+			result.Income = record.Annual
+			result.Currency = record.Currency
+			result.Period = "annual"
+			result.Year = record.Year
+			return result, nil
+		case errors.Is(err, connector.ErrNoRecord):
+			activity.GetLogger(ctx).Info("No automated source answered", "employer", req.EmployerName, "error", err)
+		default:
+			return nil, fmt.Errorf("failed to query income sources: %w", err)
+		}
+	}
+
+	// Fall back to the salary from the emailed verification
+	if v := req.Verification; v != nil && v.Verified && v.Profile.CurrentSalary != "" && salaryConfirmed(v) {
+		salary, err := strconv.ParseFloat(v.Profile.CurrentSalary, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid verified salary: %w", err)
+		}
+		result.Verified = true
+		result.VerifiedBy = v.VerifiedBy
+		result.Notes = "Verified by the employer by email"
+		result.Income = salary
+		result.Period = "annual"
+		return result, nil
+	}
+
+	result.Notes = "No source could verify income"
+	return result, nil
+}
+
+// salaryConfirmed reports whether the employer confirmed the salary they gave
+func salaryConfirmed(v *types.VerificationSubmissionSignal) bool {
+	for _, field := range v.Unconfirmed {
+		if field == types.VerificationFieldSalary {
+			return false
+		}
+	}
+	for _, d := range v.Disputes {
+		if d.Field == types.VerificationFieldSalary {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,91 @@
+package income
+
+import (
+	"context"
+	"testing"
+
+	"encore.app/agent/activities/employment/connector"
+	"encore.app/agent/types"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func fakeReveal(_ context.Context, fullName, _, _ string) (connector.Subject, error) {
+	return connector.Subject{FullName: fullName, SSN: "123-45-6789"}, nil
+}
+
+func TestGetIncomeInformation(t *testing.T) {
+	emailed := &types.VerificationSubmissionSignal{
+		Verified:   true,
+		VerifiedBy: "hr@acme.example",
+		Profile:    types.EmploymentVerification{CurrentSalary: "90000"},
+	}
+	tests := []struct {
+		name         string
+		records      map[string]connector.IncomeResponse
+		verification *types.VerificationSubmissionSignal
+		wantVerified bool
+		wantSource   string
+		wantIncome   float64
+	}{
+		{
+			name:         "source answers",
+			records:      map[string]connector.IncomeResponse{"123-45-6789": {Employer: "Acme", Year: 2024, Amount: 5000, Currency: "USD", Period: "monthly"}},
+			verification: emailed,
+			wantVerified: true,
+			wantSource:   "fake",
+			wantIncome:   60000,
+		},
+		{
+			name:         "falls back to the emailed salary",
+			verification: emailed,
+			wantVerified: true,
+			wantIncome:   90000,
+		},
+		{
+			name: "emailed salary not confirmed",
+			verification: &types.VerificationSubmissionSignal{
+				Verified:    true,
+				Profile:     types.EmploymentVerification{CurrentSalary: "90000"},
+				Unconfirmed: []string{types.VerificationFieldSalary},
+			},
+		},
+		{
+			name: "no source and no email",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &connector.FakeServer{Income: tt.records}
+			srv := fake.Start()
+			defer srv.Close()
+
+			a := &Activity{
+				chain:  connector.NewChain(connector.RetryPolicy{MaxAttempts: 1}, connector.NewHTTPConnector("fake", srv.URL, "", nil)),
+				reveal: fakeReveal,
+			}
+			var env testsuite.WorkflowTestSuite
+			actEnv := env.NewTestActivityEnvironment()
+			actEnv.RegisterActivity(a.GetIncomeInformation)
+
+			val, err := actEnv.ExecuteActivity(a.GetIncomeInformation, &Request{
+				EmployerName:    "Acme",
+				EmployerContact: "hr@acme.example",
+				CandidateName:   "Jane Doe",
+				PIIRef:          "ref",
+				Period:          "annual",
+				Year:            2024,
+				Verification:    tt.verification,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var result Result
+			if err := val.Get(&result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Verified != tt.wantVerified || result.Source != tt.wantSource || result.Income != tt.wantIncome {
+				t.Errorf("result = %+v, want verified %v, source %q, income %v", result, tt.wantVerified, tt.wantSource, tt.wantIncome)
+			}
+		})
+	}
+}
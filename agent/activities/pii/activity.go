@@ -0,0 +1,32 @@
+// Package pii provides activities managing candidates' personal details in the vault
+package pii
+
+import (
+	"context"
+	"fmt"
+
+	"encore.app/vault"
+	"go.temporal.io/sdk/activity"
+)
+
+// Activity handles personal detail operations
+type Activity struct{}
+
+// NewActivity creates a new personal detail activity instance
+func NewActivity(_ interface{}) *Activity {
+	return &Activity{}
+}
+
+// PurgePII deletes the personal details collected by the calling workflow
+// from the vault, returning how many records were deleted
+func (a *Activity) PurgePII(ctx context.Context, reason string) (int, error) {
+	resp, err := vault.Purge(ctx, &vault.PurgeParams{
+		Subject: activity.GetInfo(ctx).WorkflowExecution.ID,
+		Actor:   "agent",
+		Purpose: reason,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge personal details: %w", err)
+	}
+	return resp.Purged, nil
+}
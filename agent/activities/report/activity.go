@@ -0,0 +1,81 @@
+// Package report builds the report an employer receives when a screening
+// finishes, as versioned JSON and a printable HTML document
+package report
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+//go:embed report.go.html
+var htmlSource string
+
+var htmlTemplate = template.Must(template.New("report").Parse(htmlSource))
+
+// Store saves reports
+type Store interface {
+	// SaveReport saves a screening's report, replacing any earlier one
+	SaveReport(ctx context.Context, report *Report, requestedBy string, html []byte) error
+	// DeleteReports deletes a screening workflow's reports, returning how many
+	DeleteReports(ctx context.Context, workflowID string) (int, error)
+}
+
+// Result represents the outcome of storing a report
+type Result struct {
+	SchemaVersion int
+	GeneratedAt   time.Time
+}
+
+// Activity handles report operations
+type Activity struct {
+	store Store
+}
+
+// NewActivity creates a new report activity saving to the store
+func NewActivity(store Store) *Activity {
+	return &Activity{store: store}
+}
+
+// StoreReport builds the report of the calling workflow's screening, renders
+// it and saves it
+func (a *Activity) StoreReport(ctx context.Context, in *Input) (*Result, error) {
+	if in.JobID == "" || in.RequestedBy == "" {
+		return nil, fmt.Errorf("job ID and requesting employer are required")
+	}
+
+	info := activity.GetInfo(ctx)
+	report := Build(in, info.WorkflowExecution.ID, info.WorkflowExecution.RunID, time.Now())
+	html, err := RenderHTML(report)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.store.SaveReport(ctx, report, in.RequestedBy, html); err != nil {
+		return nil, fmt.Errorf("failed to save report: %w", err)
+	}
+	return &Result{SchemaVersion: report.SchemaVersion, GeneratedAt: report.GeneratedAt}, nil
+}
+
+// DeleteReport deletes the report of the calling workflow's screening,
+// returning how many were deleted
+func (a *Activity) DeleteReport(ctx context.Context) (int, error) {
+	deleted, err := a.store.DeleteReports(ctx, activity.GetInfo(ctx).WorkflowExecution.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete report: %w", err)
+	}
+	return deleted, nil
+}
+
+// RenderHTML renders a report as an HTML document, styled to print to PDF
+func RenderHTML(report *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, report); err != nil {
+		return nil, fmt.Errorf("render report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
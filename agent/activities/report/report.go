@@ -0,0 +1,255 @@
+package report
+
+import (
+	"time"
+
+	"encore.app/agent/activities/education"
+	"encore.app/agent/activities/employment/history"
+	"encore.app/agent/activities/employment/income"
+	"encore.app/agent/types"
+)
+
+// SchemaVersion is the version of the report's JSON format. Bump it when
+// changing the format in a way readers would notice.
+const SchemaVersion = 1
+
+// Input is what a finished screening knows, from which its report is built
+type Input struct {
+	JobID            string
+	RequestedBy      string
+	Tier             string
+	TierVersion      int
+	Status           types.Status
+	CandidateEmail   string
+	CurrentEmployer  string
+	PreviousEmployer string
+	ConsentReceived  bool
+	Candidate        *types.CandidateDetails
+	Research         *types.ResearchSubmissionSignal
+	Verification     *types.VerificationSubmissionSignal
+	History          *history.Result
+	Income           *income.Result
+	Education        *education.Result
+	CompletedSteps   []string
+	SkippedSteps     []string
+	RemainingSteps   []string
+	Adjudication     *types.Adjudication
+}
+
+// Report is the employer's deliverable for a finished screening
+type Report struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	JobID         string    `json:"jobId"`
+	WorkflowID    string    `json:"workflowId"`
+	RunID         string    `json:"runId"`
+	GeneratedAt   time.Time `json:"generatedAt"`
+	Tier          string    `json:"tier"`
+	TierVersion   int       `json:"tierVersion"`
+	Status        string    `json:"status"`
+
+	Decision   *DecisionSection   `json:"decision,omitempty"`
+	Candidate  CandidateSection   `json:"candidate"`
+	Consent    ConsentSection     `json:"consent"`
+	Research   *ResearchSection   `json:"research,omitempty"`
+	Employment *EmploymentSection `json:"employment,omitempty"`
+	Income     *IncomeSection     `json:"income,omitempty"`
+	Education  *EducationSection  `json:"education,omitempty"`
+
+	CompletedSteps  []string `json:"completedSteps"`
+	SkippedSteps    []string `json:"skippedSteps"`
+	IncompleteSteps []string `json:"incompleteSteps"` // steps the screening ended without
+}
+
+// DecisionSection is the adjudicated decision on the screening
+type DecisionSection struct {
+	Decision types.Decision  `json:"decision"` // pass, review or fail
+	Reasons  []ReasonSection `json:"reasons"`
+	RuleSet  string          `json:"ruleSet"`
+}
+
+// ReasonSection is a rule that contributed to the decision
+type ReasonSection struct {
+	Rule     string         `json:"rule"`
+	Decision types.Decision `json:"decision"`
+	Detail   string         `json:"detail"`
+}
+
+// CandidateSection identifies the candidate
+type CandidateSection struct {
+	FullName         string `json:"fullName"`
+	Email            string `json:"email"`
+	CurrentEmployer  string `json:"currentEmployer"`
+	PreviousEmployer string `json:"previousEmployer"`
+}
+
+// ConsentSection records the candidate's consent
+type ConsentSection struct {
+	Received bool `json:"received"`
+}
+
+// ResearchSection summarizes the researched professional profile
+type ResearchSection struct {
+	Verified             bool               `json:"verified"`
+	ProfileURL           string             `json:"profileUrl,omitempty"`
+	Headline             string             `json:"headline,omitempty"`
+	Experiences          []types.Experience `json:"experiences"`
+	Education            []types.Education  `json:"education"`
+	ExperienceInDays     int                `json:"experienceInDays"`
+	HasMilitaryService   bool               `json:"hasMilitaryService"`
+	HasSecurityClearance bool               `json:"hasSecurityClearance"`
+}
+
+// EmploymentSection is the verified employment at the previous employer
+type EmploymentSection struct {
+	Verified      bool                        `json:"verified"`
+	Method        string                      `json:"method"` // automated or email
+	VerifiedBy    string                      `json:"verifiedBy,omitempty"`
+	Outcome       types.VerificationOutcome   `json:"outcome,omitempty"`
+	Employer      string                      `json:"employer,omitempty"`
+	Position      string                      `json:"position,omitempty"`
+	StartDate     string                      `json:"startDate,omitempty"`
+	EndDate       string                      `json:"endDate,omitempty"`
+	Unconfirmed   []string                    `json:"unconfirmed,omitempty"`
+	Disputes      []types.VerificationDispute `json:"disputes,omitempty"`
+	Discrepancies []string                    `json:"discrepancies,omitempty"` // differences from the researched profile
+	Notes         string                      `json:"notes,omitempty"`
+}
+
+// IncomeSection is the verified income at the previous employer
+type IncomeSection struct {
+	Verified   bool    `json:"verified"`
+	VerifiedBy string  `json:"verifiedBy,omitempty"`
+	Amount     float64 `json:"amount"`
+	Currency   string  `json:"currency"`
+	Period     string  `json:"period"`
+	Year       int     `json:"year"`
+	Notes      string  `json:"notes,omitempty"`
+}
+
+// EducationSection is the verified education
+type EducationSection struct {
+	Verified   bool              `json:"verified"`
+	VerifiedBy string            `json:"verifiedBy,omitempty"`
+	Education  []types.Education `json:"education"`
+	Notes      string            `json:"notes,omitempty"`
+}
+
+// Employment verification methods
+const (
+	MethodAutomated = "automated"
+	MethodEmail     = "email"
+)
+
+// Build builds the report of a screening
+func Build(in *Input, workflowID, runID string, now time.Time) *Report {
+	r := &Report{
+		SchemaVersion: SchemaVersion,
+		JobID:         in.JobID,
+		WorkflowID:    workflowID,
+		RunID:         runID,
+		GeneratedAt:   now.UTC(),
+		Tier:          in.Tier,
+		TierVersion:   in.TierVersion,
+		Status:        string(in.Status),
+		Candidate: CandidateSection{
+			Email:            in.CandidateEmail,
+			CurrentEmployer:  in.CurrentEmployer,
+			PreviousEmployer: in.PreviousEmployer,
+		},
+		Consent:         ConsentSection{Received: in.ConsentReceived},
+		CompletedSteps:  nonNil(in.CompletedSteps),
+		SkippedSteps:    nonNil(in.SkippedSteps),
+		IncompleteSteps: nonNil(in.RemainingSteps),
+	}
+	if in.Candidate != nil {
+		r.Candidate.FullName = in.Candidate.FullName
+	}
+
+	if a := in.Adjudication; a != nil {
+		r.Decision = &DecisionSection{Decision: a.Decision, Reasons: []ReasonSection{}, RuleSet: a.RuleSet}
+		for _, reason := range a.Reasons {
+			r.Decision.Reasons = append(r.Decision.Reasons, ReasonSection{
+				Rule:     reason.Rule,
+				Decision: reason.Decision,
+				Detail:   reason.Detail,
+			})
+		}
+	}
+
+	if rs := in.Research; rs != nil {
+		p := rs.Profile
+		r.Research = &ResearchSection{
+			Verified:             rs.Verified,
+			ProfileURL:           p.CanonicalURL,
+			Headline:             p.Headline,
+			Experiences:          append(append([]types.Experience{}, p.CurrentExperiences...), p.PreviousExperiences...),
+			Education:            append([]types.Education{}, p.Education...),
+			ExperienceInDays:     p.ExperienceInDays,
+			HasMilitaryService:   p.HasMilitaryService,
+			HasSecurityClearance: p.HasSecurityClearance,
+		}
+	}
+
+	switch {
+	case in.History != nil && in.History.Source != "":
+		h := in.History
+		r.Employment = &EmploymentSection{
+			Verified:      h.Verified,
+			Method:        MethodAutomated,
+			VerifiedBy:    h.VerifiedBy,
+			Discrepancies: h.Discrepancies,
+			Notes:         h.Notes,
+		}
+		if e := h.Employment; e != nil {
+			r.Employment.Employer = e.EmployerName
+			r.Employment.Position = e.Position
+			r.Employment.StartDate = e.StartDate
+			r.Employment.EndDate = e.EndDate
+		}
+	case in.Verification != nil:
+		v := in.Verification
+		r.Employment = &EmploymentSection{
+			Verified:    v.Verified,
+			Method:      MethodEmail,
+			VerifiedBy:  v.VerifiedBy,
+			Outcome:     v.Outcome,
+			Employer:    v.Profile.CompanyName,
+			Position:    v.Profile.Position,
+			StartDate:   v.Profile.StartDate,
+			EndDate:     v.Profile.EndDate,
+			Unconfirmed: v.Unconfirmed,
+			Disputes:    v.Disputes,
+			Notes:       v.Notes,
+		}
+	}
+
+	if i := in.Income; i != nil {
+		r.Income = &IncomeSection{
+			Verified:   i.Verified,
+			VerifiedBy: i.VerifiedBy,
+			Amount:     i.Income,
+			Currency:   i.Currency,
+			Period:     i.Period,
+			Year:       i.Year,
+			Notes:      i.Notes,
+		}
+	}
+
+	if e := in.Education; e != nil {
+		r.Education = &EducationSection{
+			Verified:   e.Verified,
+			VerifiedBy: e.VerifiedBy,
+			Education:  nonNil(e.Education),
+			Notes:      e.Notes,
+		}
+	}
+	return r
+}
+
+// nonNil returns an empty slice for nil, so it encodes as [] rather than null
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,198 @@
+package report
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"encore.app/agent/activities/education"
+	"encore.app/agent/activities/employment/connector"
+	"encore.app/agent/activities/employment/history"
+	"encore.app/agent/activities/employment/income"
+	"encore.app/agent/types"
+)
+
+var testNow = time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+func TestBuild(t *testing.T) {
+	verification := &types.VerificationSubmissionSignal{
+		Verified: true,
+		Outcome:  types.VerificationPartial,
+		Profile: types.EmploymentVerification{
+			CompanyName: "Acme",
+			Position:    "Engineer",
+			StartDate:   "2020-01-01",
+			EndDate:     "2022-06-30",
+		},
+		Unconfirmed: []string{types.VerificationFieldPosition},
+		VerifiedBy:  "Jo Doe, HR",
+	}
+	automated := &history.Result{
+		VerificationResult: types.VerificationResult{Verified: true, VerifiedBy: "registry"},
+		Source:             "registry",
+		Employment:         &connector.EmploymentRecord{EmployerName: "Acme Inc", Position: "Senior Engineer", StartDate: "2020-01-01"},
+		Discrepancies:      []string{"position"},
+	}
+
+	tests := []struct {
+		name  string
+		in    *Input
+		check func(t *testing.T, r *Report)
+	}{
+		{
+			name: "empty screening",
+			in:   &Input{JobID: "job", Status: types.StatusPending},
+			check: func(t *testing.T, r *Report) {
+				if r.Decision != nil || r.Research != nil || r.Employment != nil || r.Income != nil || r.Education != nil {
+					t.Errorf("unexpected sections: %+v", r)
+				}
+				if r.CompletedSteps == nil || r.SkippedSteps == nil || r.IncompleteSteps == nil {
+					t.Error("steps must not be nil")
+				}
+			},
+		},
+		{
+			name: "header",
+			in: &Input{
+				JobID: "job", Tier: "standard", TierVersion: 2, Status: types.StatusCompleted,
+				CandidateEmail: "c@example.com", PreviousEmployer: "Acme", ConsentReceived: true,
+				Candidate:      &types.CandidateDetails{FullName: "Sam Lee"},
+				CompletedSteps: []string{types.CheckConsent},
+			},
+			check: func(t *testing.T, r *Report) {
+				if r.SchemaVersion != SchemaVersion || r.WorkflowID != "wf" || r.RunID != "run" {
+					t.Errorf("ids = %d %q %q", r.SchemaVersion, r.WorkflowID, r.RunID)
+				}
+				if !r.GeneratedAt.Equal(testNow) || r.GeneratedAt.Location() != time.UTC {
+					t.Errorf("generated at %v, want %v in UTC", r.GeneratedAt, testNow)
+				}
+				if r.Tier != "standard" || r.TierVersion != 2 || r.Status != "completed" {
+					t.Errorf("tier %q v%d status %q", r.Tier, r.TierVersion, r.Status)
+				}
+				want := CandidateSection{FullName: "Sam Lee", Email: "c@example.com", PreviousEmployer: "Acme"}
+				if r.Candidate != want || !r.Consent.Received {
+					t.Errorf("candidate = %+v consent = %+v", r.Candidate, r.Consent)
+				}
+				if !reflect.DeepEqual(r.CompletedSteps, []string{types.CheckConsent}) {
+					t.Errorf("completed steps = %v", r.CompletedSteps)
+				}
+			},
+		},
+		{
+			name: "decision",
+			in: &Input{Adjudication: &types.Adjudication{
+				Decision: types.DecisionReview,
+				Reasons:  []types.DecisionReason{{Rule: "employment_gap", Decision: types.DecisionReview, Detail: "gap"}},
+				RuleSet:  "default",
+			}},
+			check: func(t *testing.T, r *Report) {
+				want := &DecisionSection{
+					Decision: types.DecisionReview,
+					Reasons:  []ReasonSection{{Rule: "employment_gap", Decision: types.DecisionReview, Detail: "gap"}},
+					RuleSet:  "default",
+				}
+				if !reflect.DeepEqual(r.Decision, want) {
+					t.Errorf("decision = %+v, want %+v", r.Decision, want)
+				}
+			},
+		},
+		{
+			name: "pass has no reasons",
+			in:   &Input{Adjudication: &types.Adjudication{Decision: types.DecisionPass}},
+			check: func(t *testing.T, r *Report) {
+				if r.Decision == nil || r.Decision.Reasons == nil || len(r.Decision.Reasons) != 0 {
+					t.Errorf("decision = %+v, want empty reasons", r.Decision)
+				}
+			},
+		},
+		{
+			name: "research",
+			in: &Input{Research: &types.ResearchSubmissionSignal{
+				Verified: true,
+				Profile: types.ResearchProfile{
+					CanonicalURL:        "https://example.com/sam",
+					Headline:            "Engineer",
+					CurrentExperiences:  []types.Experience{{Company: "Beta"}},
+					PreviousExperiences: []types.Experience{{Company: "Acme"}},
+					ExperienceInDays:    1000,
+				},
+			}},
+			check: func(t *testing.T, r *Report) {
+				rs := r.Research
+				if rs == nil || !rs.Verified || rs.ProfileURL != "https://example.com/sam" || rs.ExperienceInDays != 1000 {
+					t.Fatalf("research = %+v", rs)
+				}
+				if len(rs.Experiences) != 2 || rs.Experiences[0].Company != "Beta" || rs.Experiences[1].Company != "Acme" {
+					t.Errorf("experiences = %+v, want current then previous", rs.Experiences)
+				}
+				if rs.Education == nil {
+					t.Error("education must not be nil")
+				}
+			},
+		},
+		{
+			name: "email verification",
+			in:   &Input{Verification: verification},
+			check: func(t *testing.T, r *Report) {
+				want := &EmploymentSection{
+					Verified: true, Method: MethodEmail, VerifiedBy: "Jo Doe, HR", Outcome: types.VerificationPartial,
+					Employer: "Acme", Position: "Engineer", StartDate: "2020-01-01", EndDate: "2022-06-30",
+					Unconfirmed: []string{types.VerificationFieldPosition},
+				}
+				if !reflect.DeepEqual(r.Employment, want) {
+					t.Errorf("employment = %+v, want %+v", r.Employment, want)
+				}
+			},
+		},
+		{
+			name: "automated verification wins over email",
+			in:   &Input{History: automated, Verification: verification},
+			check: func(t *testing.T, r *Report) {
+				want := &EmploymentSection{
+					Verified: true, Method: MethodAutomated, VerifiedBy: "registry",
+					Employer: "Acme Inc", Position: "Senior Engineer", StartDate: "2020-01-01",
+					Discrepancies: []string{"position"},
+				}
+				if !reflect.DeepEqual(r.Employment, want) {
+					t.Errorf("employment = %+v, want %+v", r.Employment, want)
+				}
+			},
+		},
+		{
+			name: "history without a source falls back to email",
+			in:   &Input{History: &history.Result{}, Verification: verification},
+			check: func(t *testing.T, r *Report) {
+				if r.Employment == nil || r.Employment.Method != MethodEmail {
+					t.Errorf("employment = %+v, want email method", r.Employment)
+				}
+			},
+		},
+		{
+			name: "income and education",
+			in: &Input{
+				Income:    &income.Result{Verified: true, VerifiedBy: "payroll", Income: 85000, Currency: "USD", Period: "annual", Year: 2023},
+				Education: &education.Result{Verified: false, Notes: "no record"},
+			},
+			check: func(t *testing.T, r *Report) {
+				wantIncome := &IncomeSection{Verified: true, VerifiedBy: "payroll", Amount: 85000, Currency: "USD", Period: "annual", Year: 2023}
+				if !reflect.DeepEqual(r.Income, wantIncome) {
+					t.Errorf("income = %+v, want %+v", r.Income, wantIncome)
+				}
+				wantEducation := &EducationSection{Notes: "no record", Education: []types.Education{}}
+				if !reflect.DeepEqual(r.Education, wantEducation) {
+					t.Errorf("education = %+v, want %+v", r.Education, wantEducation)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Build(tt.in, "wf", "run", testNow)
+			tt.check(t, r)
+			if _, err := json.Marshal(r); err != nil {
+				t.Errorf("marshal: %v", err)
+			}
+		})
+	}
+}
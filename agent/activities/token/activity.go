@@ -0,0 +1,54 @@
+// Package token provides activities issuing signed workflow tokens
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"encore.app/agent/utils"
+	"go.temporal.io/sdk/activity"
+)
+
+// Request represents a request for a token to the calling workflow
+type Request struct {
+	Purpose string
+	TTL     time.Duration
+}
+
+// Activity handles token operations
+type Activity struct {
+	keys *utils.KeyRing
+}
+
+// NewActivity creates a new token activity signing with the given keys
+func NewActivity(keys *utils.KeyRing) *Activity {
+	return &Activity{
+		keys: keys,
+	}
+}
+
+// IssueToken issues a signed, expiring, single-use token for the calling
+// workflow. Tokens are issued by an activity so that signing keys stay out
+// of workflow code and each token is recorded in the workflow's history.
+func (a *Activity) IssueToken(ctx context.Context, req *Request) (string, error) {
+	if req.Purpose == "" || req.TTL <= 0 {
+		return "", fmt.Errorf("token purpose and TTL are required")
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	info := activity.GetInfo(ctx)
+	return a.keys.Sign(&utils.Claims{
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		Purpose:    req.Purpose,
+		ExpiresAt:  time.Now().Add(req.TTL).Unix(),
+		Nonce:      base64.RawURLEncoding.EncodeToString(nonce),
+	})
+}
@@ -5,23 +5,43 @@ import (
 	"crypto/tls"
 	"fmt"
 
+	"encore.app/agent/activities/education"
 	"encore.app/agent/activities/email/consent"
 	"encore.app/agent/activities/email/reminder"
 	"encore.app/agent/activities/email/research"
+	"encore.app/agent/activities/email/verification"
+	"encore.app/agent/activities/employment/history"
+	"encore.app/agent/activities/employment/income"
+	"encore.app/agent/activities/pii"
+	"encore.app/agent/activities/report"
+	"encore.app/agent/activities/token"
+	"encore.app/agent/types"
+	"encore.app/agent/utils"
 	"encore.app/agent/workflows"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 
 	"encore.dev"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
 	"go.temporal.io/sdk/worker"
 )
 
 // Use an environment-specific task queue so we can use the same
 // Temporal Cluster for all cloud environments.
+//
+// The queue is versioned. Changes to the screening workflow that running
+// screenings can't replay are gated with workflow.GetVersion where that's
+// practical. Otherwise bump agentTaskQueueVersion: new screenings start on
+// the new queue, and a worker of the previous release must keep serving the
+// old queue until its screenings have finished (the queue is drained)
+// before it is stopped. Version 2 is the tiered, vault-backed screening,
+// which screenings started before it can't replay.
+const agentTaskQueueVersion = 2
+
 var (
 	envName        = encore.Meta().Environment.Name
-	agentTaskQueue = envName + "-agent"
+	agentTaskQueue = fmt.Sprintf("%s-agent-v%d", envName, agentTaskQueueVersion)
 )
 
 // GetTaskQueue returns the task queue name
@@ -35,16 +55,29 @@ func (s *Service) Client() client.Client {
 }
 
 var secrets struct {
-	TemporalApiKey string
+	TemporalApiKey   string
+	TokenSigningKeys string // comma-separated id:key pairs, current key first
+
+	VerificationSourceKeys string // comma-separated name:key pairs of VerificationSources
 }
 
 //encore:service
 type Service struct {
 	client client.Client
 	worker worker.Worker
+	tokens *utils.KeyRing
 }
 
 func initService() (*Service, error) {
+	tokenKeys, err := loadTokenKeys()
+	if err != nil {
+		return nil, err
+	}
+	sources, err := verificationConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	opts := client.Options{
 		HostPort:  cfg.TemporalServer,
 		Namespace: cfg.TemporalNamespace,
@@ -70,6 +103,11 @@ func initService() (*Service, error) {
 				),
 			},
 		},
+		// Personal details belong in the vault, so any that reach Temporal are redacted
+		DataConverter: converter.NewCodecDataConverter(
+			converter.GetDefaultDataConverter(),
+			utils.NewRedactingCodec(types.PIIFieldSSN, types.PIIFieldAddress, types.PIIFieldPhoneNumber, types.PIIFieldPhoneCountryCode),
+		),
 		Credentials: func() client.Credentials {
 			if encore.Meta().Environment.Cloud == "local" {
 				return nil // No auth needed for local development
@@ -86,19 +124,34 @@ func initService() (*Service, error) {
 	researchActivity := research.NewActivity(nil)
 	consentActivity := consent.NewActivity(nil)
 	reminderActivity := reminder.NewActivity(nil)
+	verificationActivity := verification.NewActivity(nil)
+	incomeActivity := income.NewActivity(sources)
+	historyActivity := history.NewActivity(sources)
+	educationActivity := education.NewActivity(types.Config{})
+	tokenActivity := token.NewActivity(tokenKeys)
+	piiActivity := pii.NewActivity(nil)
+	reportActivity := report.NewActivity(reportStore{})
 
 	w := worker.New(c, agentTaskQueue, worker.Options{})
 	w.RegisterWorkflow(workflows.Agent)
 	w.RegisterActivity(researchActivity.SendResearchRequest)
 	w.RegisterActivity(consentActivity.SendConsentEmail)
 	w.RegisterActivity(reminderActivity.SendReminder)
+	w.RegisterActivity(verificationActivity.SendVerificationRequest)
+	w.RegisterActivity(incomeActivity.GetIncomeInformation)
+	w.RegisterActivity(historyActivity.CheckEmploymentHistory)
+	w.RegisterActivity(educationActivity.VerifyEducation)
+	w.RegisterActivity(tokenActivity.IssueToken)
+	w.RegisterActivity(piiActivity.PurgePII)
+	w.RegisterActivity(reportActivity.StoreReport)
+	w.RegisterActivity(reportActivity.DeleteReport)
 
 	err = w.Start()
 	if err != nil {
 		c.Close()
 		return nil, fmt.Errorf("start temporal worker: %v", err)
 	}
-	return &Service{client: c, worker: w}, nil
+	return &Service{client: c, worker: w, tokens: tokenKeys}, nil
 }
 
 func (s *Service) Shutdown(force context.Context) {
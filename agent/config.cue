@@ -3,8 +3,75 @@ package agent
 TemporalServer: string | *"localhost:7233"
 TemporalNamespace: string | *"default"
 
+// Tier versions are listed oldest first and never changed once released:
+// add a version instead, so running screenings keep their definition.
+// Reminder hours must be ascending and within the grace periods.
+#Tier: {
+    Version: int & >0
+    Checks: [...("consent" | "research" | "employment_history" | "income" | "education")]
+    ConsentGraceHours: int | *72
+    ResearchGraceHours: int | *72
+    VerificationGraceHours: int | *72
+    ConsentReminders: [...int] | *[24, 48, 70]
+    ResearchReminders: [...int] | *[24, 48, 70]
+    PriceCents: int
+    Currency: string | *"USD"
+}
+Tiers: [string]: [...#Tier]
+Tiers: {
+    basic: [{
+        Version: 1
+        Checks: ["consent", "research", "employment_history"]
+        PriceCents: 2900
+    }]
+    standard: [{
+        Version: 1
+        Checks: ["consent", "research", "employment_history", "income"]
+        PriceCents: 4900
+    }]
+    // No tier offers the education check until education verification is
+    // implemented: until then it always leaves qualifications unverified
+}
+
+// Automated employment verification sources by name, asked in name order.
+// Their keys are in the VerificationSourceKeys secret.
+VerificationSources: [string]: string
+VerificationSources: {}
+
+// Adjudication rules, layered: defaults, then tier, then employer, then the
+// employer's tier. A later layer replaces the same rule, and "pass" turns it off.
+#Rule: {
+    Rule: "consent_declined" | "incomplete" | "employment_unverified" | "employment_no_record" | "employment_disputed" | "date_mismatch" | "employment_gap" | "income_unverified" | "education_unverified"
+    Decision: "pass" | "review" | "fail"
+    MaxGapMonths: int | *0
+}
+Adjudication: {
+    Default: [...#Rule]
+    Tiers: [string]: [...#Rule]
+    // Keyed on the employer's user ID
+    Employers: [string]: {
+        Default: [...#Rule] | *[]
+        Tiers: [string]: [...#Rule]
+    }
+}
+Adjudication: {
+    Default: [
+        {Rule: "consent_declined", Decision: "fail"},
+        {Rule: "employment_no_record", Decision: "fail"},
+        {Rule: "employment_disputed", Decision: "review"},
+        {Rule: "employment_unverified", Decision: "review"},
+        {Rule: "date_mismatch", Decision: "review"},
+        {Rule: "employment_gap", Decision: "review", MaxGapMonths: 6},
+        {Rule: "incomplete", Decision: "review"},
+    ]
+    Tiers: {
+        standard: [{Rule: "income_unverified", Decision: "review"}]
+    }
+    Employers: {}
+}
+
 // Use cloud settings for any non-local environment
 if #Meta.Environment.Cloud != "local" {
     TemporalServer: "us-east-1.aws.api.temporal.io:7233"
     TemporalNamespace: "totaltalent-qa.bodly"
-}
\ No newline at end of file
+}
@@ -1,10 +1,175 @@
 package agent
 
-import "encore.dev/config"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"encore.app/agent/types"
+	"encore.app/agent/workflows"
+	"encore.dev/config"
+)
 
 type Config struct {
 	TemporalServer    string
 	TemporalNamespace string
+
+	// Tiers lists the versions of each screening tier, oldest first. New
+	// screenings use the latest version; running ones keep the version
+	// they started with.
+	Tiers map[string][]TierConfig
+
+	// VerificationSources maps the name of each automated employment
+	// verification source to its endpoint. Sources are asked in name order,
+	// and employers are asked by email when none answers.
+	VerificationSources map[string]string
+
+	// Adjudication holds the rules that decide finished screenings
+	Adjudication AdjudicationConfig
+}
+
+// AdjudicationConfig layers adjudication rules from the least to the most
+// specific: the defaults, then the tier's, then the employer's, then the
+// employer's for the tier. A rule in a later layer replaces the same rule
+// from an earlier one, and a decision of "pass" turns it off.
+type AdjudicationConfig struct {
+	Default   []RuleConfig
+	Tiers     map[string][]RuleConfig
+	Employers map[string]EmployerRulesConfig // by the employer's user ID
+}
+
+// EmployerRulesConfig is an employer's adjudication rules
+type EmployerRulesConfig struct {
+	Default []RuleConfig
+	Tiers   map[string][]RuleConfig
+}
+
+// RuleConfig configures an adjudication rule, see workflows.Rule*
+type RuleConfig struct {
+	Rule         string
+	Decision     string // pass, review or fail
+	MaxGapMonths int    // employment_gap only
+}
+
+// TierConfig is one version of a screening tier
+type TierConfig struct {
+	Version int
+	Checks  []string // see types.Check*
+
+	ConsentGraceHours      int
+	ResearchGraceHours     int
+	VerificationGraceHours int
+
+	ConsentReminders  []int // hours after the consent email
+	ResearchReminders []int // hours after the research request
+
+	PriceCents int64
+	Currency   string
 }
 
 var cfg = config.Load[*Config]()
+
+// tierPackage returns the latest version of a tier, or false if there is no
+// such tier
+func tierPackage(tier string) (*workflows.TierPackage, bool) {
+	versions := cfg.Tiers[tier]
+	if len(versions) == 0 {
+		return nil, false
+	}
+	tc := versions[len(versions)-1]
+	return &workflows.TierPackage{
+		Name:                    tier,
+		Version:                 tc.Version,
+		Checks:                  tc.Checks,
+		ConsentGracePeriod:      time.Duration(tc.ConsentGraceHours) * time.Hour,
+		ResearchGracePeriod:     time.Duration(tc.ResearchGraceHours) * time.Hour,
+		VerificationGracePeriod: time.Duration(tc.VerificationGraceHours) * time.Hour,
+		Reminders: workflows.ReminderSchedule{
+			Consent:  hours(tc.ConsentReminders),
+			Research: hours(tc.ResearchReminders),
+		},
+		Price: workflows.Price{Amount: tc.PriceCents, Currency: tc.Currency},
+	}, true
+}
+
+// hours converts config values in hours to durations
+func hours(values []int) []time.Duration {
+	durations := make([]time.Duration, len(values))
+	for i, h := range values {
+		durations[i] = time.Duration(h) * time.Hour
+	}
+	return durations
+}
+
+// verificationConfig returns the automated verification sources, with the
+// keys from the comma-separated name:key pairs of the VerificationSourceKeys
+// secret
+func verificationConfig() (types.Config, error) {
+	config := types.Config{
+		Keys:      map[string]string{},
+		Endpoints: map[string]string{},
+	}
+	for name, endpoint := range cfg.VerificationSources {
+		config.Endpoints[name] = endpoint
+	}
+	if secrets.VerificationSourceKeys == "" {
+		return config, nil
+	}
+	for _, pair := range strings.Split(secrets.VerificationSourceKeys, ",") {
+		name, key, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || name == "" {
+			return types.Config{}, fmt.Errorf("invalid verification source key: expected name:key")
+		}
+		if _, ok := config.Endpoints[name]; !ok {
+			return types.Config{}, fmt.Errorf("key for unknown verification source: %s", name)
+		}
+		config.Keys[name] = key
+	}
+	return config, nil
+}
+
+// adjudicationRules resolves the adjudication rules for an employer's
+// screenings of a tier. The employer is the authenticated user ID.
+func adjudicationRules(employer, tier string) *workflows.AdjudicationRules {
+	layers := [][]RuleConfig{cfg.Adjudication.Default, cfg.Adjudication.Tiers[tier]}
+	names := []string{"default"}
+	if len(cfg.Adjudication.Tiers[tier]) > 0 {
+		names = append(names, "tier:"+tier)
+	}
+	if ec, ok := cfg.Adjudication.Employers[employer]; ok {
+		layers = append(layers, ec.Default, ec.Tiers[tier])
+		names = append(names, "employer:"+employer)
+		if len(ec.Tiers[tier]) > 0 {
+			names = append(names, "employer:"+employer+"/tier:"+tier)
+		}
+	}
+
+	// Later layers replace rules in place, keeping the order rules first appear in
+	var order []string
+	byRule := map[string]RuleConfig{}
+	for _, layer := range layers {
+		for _, rc := range layer {
+			if _, ok := byRule[rc.Rule]; !ok {
+				order = append(order, rc.Rule)
+			}
+			byRule[rc.Rule] = rc
+		}
+	}
+
+	rules := &workflows.AdjudicationRules{
+		Name:  strings.Join(names, ","),
+		Rules: []workflows.AdjudicationRule{},
+	}
+	for _, name := range order {
+		rc := byRule[name]
+		if types.Decision(rc.Decision) == types.DecisionPass {
+			continue
+		}
+		rules.Rules = append(rules.Rules, workflows.AdjudicationRule{
+			Rule:         rc.Rule,
+			Decision:     types.Decision(rc.Decision),
+			MaxGapMonths: rc.MaxGapMonths,
+		})
+	}
+	return rules
+}
@@ -3,11 +3,13 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"encore.app/agent/types"
-	"encore.app/agent/utils"
+	"encore.app/vault"
 	"encore.dev/rlog"
 )
 
@@ -52,13 +54,12 @@ func (s *Service) ServeConsentAPI(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Parse workflow token
-	workflowID, runID, err := utils.ParseWorkflowToken(token)
-	if err != nil {
-		rlog.Error("Failed to parse workflow token", "error", err)
-		http.Error(w, "Invalid token", http.StatusBadRequest)
+	// Verify workflow token
+	claims, ok := s.verifyToken(w, token, types.TokenPurposeConsent)
+	if !ok {
 		return
 	}
+	workflowID, runID := claims.WorkflowID, claims.RunID
 
 	// Handle GET request for initial consent info
 	if req.Method == http.MethodGet {
@@ -104,16 +105,33 @@ func (s *Service) ServeConsentAPI(w http.ResponseWriter, req *http.Request) {
 			Accepted: submission.Accepted,
 			CandidateDetails: types.CandidateDetails{
 				FullName:         submission.FullName,
-				SSN:              submission.SSN,
-				Address:          submission.Address,
-				PhoneNumber:      submission.PhoneNumber,
-				PhoneCountryCode: submission.PhoneCountryCode,
 				PreviousEmployer: submission.PreviousEmployer,
 				CurrentEmployer:  submission.CurrentEmployer,
 			},
 		}
 
-		if err := s.Client().SignalWorkflow(context.Background(), workflowID, runID, types.AcceptSubmissionSignalName, signal); err != nil {
+		// Each token can only be submitted once
+		err := redeemToken(req.Context(), claims, "consent", func() error {
+			// Personal details go to the vault, and the workflow gets a reference
+			if submission.Accepted {
+				ref, err := storeCandidatePII(req.Context(), workflowID, &submission)
+				if err != nil {
+					return err
+				}
+				signal.CandidateDetails.PIIRef = ref
+			}
+
+			err := s.Client().SignalWorkflow(context.Background(), workflowID, runID, types.AcceptSubmissionSignalName, signal)
+			if err != nil && submission.Accepted {
+				purgeCandidatePII(req.Context(), workflowID, "consent not delivered")
+			}
+			return err
+		})
+		if errors.Is(err, errTokenUsed) {
+			http.Error(w, "Token already used", http.StatusConflict)
+			return
+		} else if err != nil {
+			rlog.Error("Failed to signal workflow", "error", err)
 			http.Error(w, "Failed to signal workflow", http.StatusInternalServerError)
 			return
 		}
@@ -125,6 +143,39 @@ func (s *Service) ServeConsentAPI(w http.ResponseWriter, req *http.Request) {
 	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
+// storeCandidatePII stores a candidate's personal details in the vault,
+// returning the reference to them
+func storeCandidatePII(ctx context.Context, workflowID string, submission *ConsentSubmission) (string, error) {
+	resp, err := vault.Store(ctx, &vault.StoreParams{
+		Subject: workflowID,
+		Fields: map[string]string{
+			types.PIIFieldSSN:              submission.SSN,
+			types.PIIFieldAddress:          submission.Address,
+			types.PIIFieldPhoneNumber:      submission.PhoneNumber,
+			types.PIIFieldPhoneCountryCode: submission.PhoneCountryCode,
+		},
+		Actor:   "agent",
+		Purpose: "screening consent",
+	})
+	if err != nil {
+		return "", fmt.Errorf("store candidate details: %w", err)
+	}
+	return resp.Ref, nil
+}
+
+// purgeCandidatePII deletes a screening's personal details from the vault,
+// logging rather than returning failures
+func purgeCandidatePII(ctx context.Context, workflowID, purpose string) {
+	_, err := vault.Purge(ctx, &vault.PurgeParams{
+		Subject: workflowID,
+		Actor:   "agent",
+		Purpose: purpose,
+	})
+	if err != nil {
+		rlog.Error("Failed to purge candidate details", "workflow_id", workflowID, "error", err)
+	}
+}
+
 func getWorkflowInfo(workflowID, runID string, s *Service) (string, string, error) {
 	resp, err := s.Client().DescribeWorkflowExecution(context.Background(), workflowID, runID)
 	if err != nil {
@@ -4,15 +4,16 @@ import (
 	"context"
 	"fmt"
 
-	"encore.app/agent/types"
 	"encore.app/agent/workflows"
+	"encore.dev/beta/auth"
 	"encore.dev/rlog"
 	"go.temporal.io/sdk/client"
 )
 
-// Init starts a new screening workflow for a candidate
+// Init starts a new screening workflow for a candidate, on behalf of the
+// authenticated employer
 //
-//encore:api public
+//encore:api auth
 func (s *Service) Init(ctx context.Context, req *workflows.ScreeningWorkflowInput) (*workflows.ScreeningStatus, error) {
 	if req == nil {
 		return nil, fmt.Errorf("request body is required")
@@ -26,31 +27,53 @@ func (s *Service) Init(ctx context.Context, req *workflows.ScreeningWorkflowInpu
 	if req.Tier == "" {
 		return nil, fmt.Errorf("tier is required")
 	}
+	pkg, ok := tierPackage(req.Tier)
+	if !ok {
+		return nil, fmt.Errorf("unknown tier: %s", req.Tier)
+	}
+	if err := pkg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid tier configuration: %w", err)
+	}
 	if req.CurrentEmployer == "" {
 		return nil, fmt.Errorf("current employer is required")
 	}
 	if req.PreviousEmployer == "" {
 		return nil, fmt.Errorf("previous employer is required")
 	}
+	if req.PreviousEmployerEmail == "" {
+		return nil, fmt.Errorf("previous employer email is required")
+	}
 
-	// Generate a unique workflow ID
-	workflowID := fmt.Sprintf("agent.init.v%s", req.JobID)
+	// Employer rules are keyed on the authenticated employer, not on the
+	// current employer the request names, which any caller can set
+	uid, _ := auth.UserID()
+	rules := adjudicationRules(string(uid), req.Tier)
+	if err := rules.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid adjudication configuration: %w", err)
+	}
 
 	options := client.StartWorkflowOptions{
-		ID:        workflowID,
+		ID:        screeningWorkflowID(string(uid), req.JobID),
 		TaskQueue: GetTaskQueue(),
 		Memo: map[string]interface{}{
 			"Email":           req.Email,
 			"CurrentEmployer": req.CurrentEmployer,
+			"RequestedBy":     string(uid),
+			"Tier":            pkg.Name,
+			"TierVersion":     pkg.Version,
 		},
 	}
 
 	input := &workflows.ScreeningWorkflowInput{
-		JobID:            req.JobID,
-		Email:            req.Email,
-		Tier:             req.Tier,
-		CurrentEmployer:  req.CurrentEmployer,
-		PreviousEmployer: req.PreviousEmployer,
+		JobID:                 req.JobID,
+		Email:                 req.Email,
+		Tier:                  req.Tier,
+		CurrentEmployer:       req.CurrentEmployer,
+		PreviousEmployer:      req.PreviousEmployer,
+		PreviousEmployerEmail: req.PreviousEmployerEmail,
+		Package:               pkg,
+		RequestedBy:           string(uid),
+		Rules:                 rules,
 	}
 
 	we, err := s.Client().ExecuteWorkflow(ctx, options, workflows.Agent, input)
@@ -63,12 +86,8 @@ func (s *Service) Init(ctx context.Context, req *workflows.ScreeningWorkflowInpu
 		"run_id", we.GetRunID(),
 		"email", req.Email,
 		"tier", req.Tier,
+		"tier_version", pkg.Version,
 	)
 
-	return &workflows.ScreeningStatus{
-		Status:          types.StatusPending,
-		CompletedSteps:  []string{},
-		RemainingSteps:  []string{},
-		ConsentReceived: false,
-	}, nil
+	return workflows.NewScreeningStatus(pkg), nil
 }
@@ -0,0 +1,9 @@
+CREATE TABLE used_tokens (
+	nonce TEXT NOT NULL,
+	action TEXT NOT NULL,
+	used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (nonce, action)
+);
+
+CREATE INDEX used_tokens_expires_at_idx ON used_tokens (expires_at);
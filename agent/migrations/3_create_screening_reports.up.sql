@@ -0,0 +1,12 @@
+CREATE TABLE screening_reports (
+	job_id TEXT PRIMARY KEY,
+	workflow_id TEXT NOT NULL,
+	run_id TEXT NOT NULL,
+	requested_by TEXT NOT NULL,
+	schema_version INT NOT NULL,
+	report JSONB NOT NULL,
+	html TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX screening_reports_requested_by_idx ON screening_reports (requested_by);
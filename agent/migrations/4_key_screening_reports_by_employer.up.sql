@@ -0,0 +1,4 @@
+-- Job IDs are chosen by employers, so only unique per employer
+ALTER TABLE screening_reports DROP CONSTRAINT screening_reports_pkey;
+ALTER TABLE screening_reports ADD PRIMARY KEY (requested_by, job_id);
+DROP INDEX screening_reports_requested_by_idx;
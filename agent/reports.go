@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"encore.app/agent/activities/report"
+	"encore.dev"
+	"encore.dev/beta/auth"
+	"encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+)
+
+// Report download formats
+const (
+	reportFormatJSON = "json"
+	reportFormatHTML = "html"
+)
+
+// reportStore saves screening reports to the database
+type reportStore struct{}
+
+// SaveReport saves a screening's report, replacing the report of an earlier
+// screening the same employer ran for the job. Job IDs are only unique per
+// employer, so another employer's report for the job is never touched.
+func (reportStore) SaveReport(ctx context.Context, r *report.Report, requestedBy string, html []byte) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal report: %w", err)
+	}
+	_, err = db.Exec(ctx, `
+		INSERT INTO screening_reports (job_id, workflow_id, run_id, requested_by, schema_version, report, html)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (requested_by, job_id) DO UPDATE SET
+			workflow_id = EXCLUDED.workflow_id,
+			run_id = EXCLUDED.run_id,
+			schema_version = EXCLUDED.schema_version,
+			report = EXCLUDED.report,
+			html = EXCLUDED.html,
+			created_at = NOW()
+	`, r.JobID, r.WorkflowID, r.RunID, requestedBy, r.SchemaVersion, data, string(html))
+	if err != nil {
+		return fmt.Errorf("insert report: %w", err)
+	}
+	return nil
+}
+
+// DeleteReports deletes a screening workflow's reports, returning how many
+func (reportStore) DeleteReports(ctx context.Context, workflowID string) (int, error) {
+	res, err := db.Exec(ctx, `DELETE FROM screening_reports WHERE workflow_id = $1`, workflowID)
+	if err != nil {
+		return 0, fmt.Errorf("delete reports: %w", err)
+	}
+	return int(res.RowsAffected()), nil
+}
+
+// DownloadReport downloads the report of the screening for a job, if the
+// authenticated employer requested it. The format query parameter selects
+// json (default) or html, which prints to PDF.
+//
+//encore:api auth raw method=GET path=/api/screenings/:jobID/report
+func (s *Service) DownloadReport(w http.ResponseWriter, req *http.Request) {
+	uid, ok := auth.UserID()
+	if !ok {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	jobID := encore.CurrentRequest().PathParams.Get("jobID")
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = reportFormatJSON
+	}
+	var contentType string
+	switch format {
+	case reportFormatJSON:
+		contentType = "application/json"
+	case reportFormatHTML:
+		contentType = "text/html; charset=utf-8"
+	default:
+		http.Error(w, "Unsupported format", http.StatusBadRequest)
+		return
+	}
+
+	// Reports of other employers' screenings are reported as not found
+	var data []byte
+	var html string
+	err := db.QueryRow(req.Context(), `
+		SELECT report, html FROM screening_reports WHERE job_id = $1 AND requested_by = $2
+	`, jobID, string(uid)).Scan(&data, &html)
+	if err == sqldb.ErrNoRows {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		rlog.Error("Failed to get report", "job_id", jobID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": fmt.Sprintf("screening-report-%s.%s", jobID, format),
+	}))
+	if format == reportFormatJSON {
+		_, err = w.Write(data)
+	} else {
+		_, err = w.Write([]byte(html))
+	}
+	if err != nil {
+		rlog.Error("Failed to write report", "format", format, "error", err)
+	}
+}
@@ -3,11 +3,11 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
 	"encore.app/agent/types"
-	"encore.app/agent/utils"
 	"encore.dev/rlog"
 )
 
@@ -62,13 +62,12 @@ func (s *Service) ServeResearchAPI(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Parse workflow token
-	workflowID, runID, err := utils.ParseWorkflowToken(token)
-	if err != nil {
-		rlog.Error("Failed to parse workflow token", "error", err)
-		http.Error(w, "Invalid token", http.StatusBadRequest)
+	// Verify workflow token
+	claims, ok := s.verifyToken(w, token, types.TokenPurposeResearch)
+	if !ok {
 		return
 	}
+	workflowID, runID := claims.WorkflowID, claims.RunID
 
 	// Handle GET request for research info
 	if req.Method == http.MethodGet {
@@ -138,7 +137,15 @@ func (s *Service) ServeResearchAPI(w http.ResponseWriter, req *http.Request) {
 			},
 		}
 
-		if err := s.Client().SignalWorkflow(context.Background(), workflowID, runID, types.ResearchSubmissionSignalName, signal); err != nil {
+		// Each token can only be submitted once
+		err := redeemToken(req.Context(), claims, "research", func() error {
+			return s.Client().SignalWorkflow(context.Background(), workflowID, runID, types.ResearchSubmissionSignalName, signal)
+		})
+		if errors.Is(err, errTokenUsed) {
+			http.Error(w, "Token already used", http.StatusConflict)
+			return
+		} else if err != nil {
+			rlog.Error("Failed to signal workflow", "error", err)
 			http.Error(w, "Failed to signal workflow", http.StatusInternalServerError)
 			return
 		}
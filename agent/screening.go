@@ -0,0 +1,282 @@
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"encore.app/agent/types"
+	"encore.app/agent/workflows"
+	"encore.app/vault"
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+	"encore.dev/rlog"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// screeningWorkflowID returns the ID of an employer's screening workflow for a
+// job. Employers choose their job IDs, so the ID is namespaced by employer,
+// which also keeps the personal details of their screenings apart in the vault.
+func screeningWorkflowID(employer, jobID string) string {
+	return fmt.Sprintf("agent.screening.%s.%s", employer, jobID)
+}
+
+// legacyScreeningWorkflowID returns the ID of a screening started before
+// workflow IDs were namespaced by employer
+func legacyScreeningWorkflowID(jobID string) string {
+	return fmt.Sprintf("agent.init.v%s", jobID)
+}
+
+// screeningJobID returns the job ID of an employer's screening workflow
+func screeningJobID(employer, workflowID string) string {
+	if prefix := screeningWorkflowID(employer, ""); strings.HasPrefix(workflowID, prefix) {
+		return strings.TrimPrefix(workflowID, prefix)
+	}
+	return strings.TrimPrefix(workflowID, legacyScreeningWorkflowID(""))
+}
+
+// memoString decodes a string field of a workflow's memo, or returns "" if absent
+func memoString(memo *commonpb.Memo, key string) string {
+	var value string
+	if field, ok := memo.GetFields()[key]; ok {
+		if err := converter.GetDefaultDataConverter().FromPayload(field, &value); err != nil {
+			rlog.Error("Failed to decode memo field", "key", key, "error", err)
+		}
+	}
+	return value
+}
+
+// authorizeScreening checks that the screening for a job exists and was
+// requested by the authenticated employer, returning its workflow ID
+func (s *Service) authorizeScreening(ctx context.Context, jobID string) (string, error) {
+	uid, _ := auth.UserID()
+	var notFound *serviceerror.NotFound
+	for _, workflowID := range []string{screeningWorkflowID(string(uid), jobID), legacyScreeningWorkflowID(jobID)} {
+		resp, err := s.Client().DescribeWorkflowExecution(ctx, workflowID, "")
+		if errors.As(err, &notFound) {
+			continue
+		} else if err != nil {
+			return "", fmt.Errorf("failed to describe screening workflow: %w", err)
+		}
+
+		// Don't reveal that other employers' screenings exist
+		if memoString(resp.WorkflowExecutionInfo.GetMemo(), "RequestedBy") != string(uid) {
+			continue
+		}
+		return workflowID, nil
+	}
+	return "", &errs.Error{Code: errs.NotFound, Message: "screening not found"}
+}
+
+// GetScreening returns the current status of the screening for a job
+//
+//encore:api auth method=GET path=/api/screenings/:jobID
+func (s *Service) GetScreening(ctx context.Context, jobID string) (*workflows.ScreeningStatus, error) {
+	workflowID, err := s.authorizeScreening(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Client().QueryWorkflow(ctx, workflowID, "", types.ScreeningStatusQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query screening status: %w", err)
+	}
+
+	var status workflows.ScreeningStatus
+	if err := resp.Get(&status); err != nil {
+		return nil, fmt.Errorf("failed to decode screening status: %w", err)
+	}
+	return &status, nil
+}
+
+// Workflow execution statuses screenings can be filtered by
+var executionStatuses = map[string]bool{
+	"Running":    true,
+	"Completed":  true,
+	"Failed":     true,
+	"Canceled":   true,
+	"Terminated": true,
+	"TimedOut":   true,
+}
+
+// ListScreeningsParams represents the filters for listing screenings
+type ListScreeningsParams struct {
+	Status        string    `query:"status"`         // workflow execution status, e.g. Running or Completed
+	StartedAfter  time.Time `query:"started_after"`  // only screenings started at or after this time
+	StartedBefore time.Time `query:"started_before"` // only screenings started before this time
+	PageSize      int       `query:"page_size"`
+	PageToken     string    `query:"page_token"` // next_page_token of the previous page
+}
+
+// ScreeningSummary summarizes a screening for listing
+type ScreeningSummary struct {
+	JobID           string     `json:"jobId"`
+	WorkflowID      string     `json:"workflowId"`
+	RunID           string     `json:"runId"`
+	Status          string     `json:"status"` // workflow execution status
+	Email           string     `json:"email"`
+	CurrentEmployer string     `json:"currentEmployer"`
+	StartedAt       time.Time  `json:"startedAt"`
+	ClosedAt        *time.Time `json:"closedAt,omitempty"`
+}
+
+// ListScreeningsResponse represents a page of screenings
+type ListScreeningsResponse struct {
+	Screenings    []*ScreeningSummary `json:"screenings"`
+	NextPageToken string              `json:"nextPageToken,omitempty"`
+}
+
+// ListScreenings lists the screenings requested by the authenticated
+// employer, newest first, using Temporal's visibility store. Pages may
+// hold fewer screenings than the page size.
+//
+//encore:api auth method=GET path=/api/screenings
+func (s *Service) ListScreenings(ctx context.Context, params *ListScreeningsParams) (*ListScreeningsResponse, error) {
+	// Not filtered by task queue, so screenings started on earlier versioned
+	// queues are still listed
+	filters := []string{"WorkflowType = 'Agent'"}
+	if params.Status != "" {
+		if !executionStatuses[params.Status] {
+			return nil, &errs.Error{Code: errs.InvalidArgument, Message: fmt.Sprintf("invalid status: %s", params.Status)}
+		}
+		filters = append(filters, fmt.Sprintf("ExecutionStatus = '%s'", params.Status))
+	}
+	if !params.StartedAfter.IsZero() {
+		filters = append(filters, fmt.Sprintf("StartTime >= '%s'", params.StartedAfter.UTC().Format(time.RFC3339)))
+	}
+	if !params.StartedBefore.IsZero() {
+		filters = append(filters, fmt.Sprintf("StartTime < '%s'", params.StartedBefore.UTC().Format(time.RFC3339)))
+	}
+
+	pageToken, err := base64.URLEncoding.DecodeString(params.PageToken)
+	if err != nil {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "invalid page token"}
+	}
+	pageSize := params.PageSize
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	resp, err := s.Client().ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		Namespace:     cfg.TemporalNamespace,
+		PageSize:      int32(pageSize),
+		NextPageToken: pageToken,
+		Query:         strings.Join(filters, " AND ") + " ORDER BY StartTime DESC",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list screening workflows: %w", err)
+	}
+
+	// Visibility can't filter on memos, so other employers' screenings are skipped here
+	uid, _ := auth.UserID()
+	screenings := []*ScreeningSummary{}
+	for _, info := range resp.GetExecutions() {
+		if memoString(info.GetMemo(), "RequestedBy") != string(uid) {
+			continue
+		}
+
+		summary := &ScreeningSummary{
+			JobID:           screeningJobID(string(uid), info.GetExecution().GetWorkflowId()),
+			WorkflowID:      info.GetExecution().GetWorkflowId(),
+			RunID:           info.GetExecution().GetRunId(),
+			Status:          info.GetStatus().String(),
+			Email:           memoString(info.GetMemo(), "Email"),
+			CurrentEmployer: memoString(info.GetMemo(), "CurrentEmployer"),
+			StartedAt:       info.GetStartTime().AsTime(),
+		}
+		if info.GetCloseTime() != nil {
+			closedAt := info.GetCloseTime().AsTime()
+			summary.ClosedAt = &closedAt
+		}
+		screenings = append(screenings, summary)
+	}
+
+	return &ListScreeningsResponse{
+		Screenings:    screenings,
+		NextPageToken: base64.URLEncoding.EncodeToString(resp.GetNextPageToken()),
+	}, nil
+}
+
+// CancelScreening stops the screening for a job at the employer's request.
+// Its status keeps the results collected so far.
+//
+//encore:api auth method=POST path=/api/screenings/:jobID/cancel
+func (s *Service) CancelScreening(ctx context.Context, jobID string) error {
+	workflowID, err := s.authorizeScreening(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	err = s.Client().CancelWorkflow(ctx, workflowID, "")
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return &errs.Error{Code: errs.FailedPrecondition, Message: "screening has already finished"}
+	} else if err != nil {
+		return fmt.Errorf("failed to cancel screening workflow: %w", err)
+	}
+
+	rlog.Info("cancelled screening workflow", "job_id", jobID)
+	return nil
+}
+
+// WithdrawRequest represents a candidate's withdrawal of consent
+type WithdrawRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// WithdrawConsent withdraws the candidate's consent to a screening, using the
+// token from their consent email, which remains valid for withdrawal after
+// the consent is submitted. A running screening stops and purges the personal
+// data it collected. A finished one has its personal details and report
+// purged here.
+//
+//encore:api public method=POST path=/api/withdraw/:token
+func (s *Service) WithdrawConsent(ctx context.Context, token string, req *WithdrawRequest) error {
+	claims, err := s.tokens.Verify(token, types.TokenPurposeConsent, time.Now())
+	if err != nil {
+		return tokenError(err)
+	}
+
+	signal := &types.WithdrawSubmissionSignal{}
+	if req != nil {
+		signal.Reason = req.Reason
+	}
+	err = redeemToken(ctx, claims, "withdraw", func() error {
+		err := s.Client().SignalWorkflow(ctx, claims.WorkflowID, claims.RunID, types.WithdrawSubmissionSignalName, signal)
+		var notFound *serviceerror.NotFound
+		if err != nil && !errors.As(err, &notFound) {
+			return fmt.Errorf("failed to signal workflow: %w", err)
+		}
+		// Purge whether or not the screening is still running, in case it
+		// finished before it could receive the signal
+		return purgeScreening(ctx, claims.WorkflowID)
+	})
+	if errors.Is(err, errTokenUsed) {
+		return tokenError(err)
+	}
+	return err
+}
+
+// purgeScreening deletes the personal details and report of a screening
+func purgeScreening(ctx context.Context, workflowID string) error {
+	resp, err := vault.Purge(ctx, &vault.PurgeParams{
+		Subject: workflowID,
+		Actor:   "candidate",
+		Purpose: "consent withdrawn",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to purge personal details: %w", err)
+	}
+	reports, err := reportStore{}.DeleteReports(ctx, workflowID)
+	if err != nil {
+		return err
+	}
+	rlog.Info("Purged withdrawn screening", "workflow_id", workflowID, "records", resp.Purged, "reports", reports)
+	return nil
+}
@@ -0,0 +1,25 @@
+package agent
+
+import "testing"
+
+func TestScreeningJobID(t *testing.T) {
+	tests := []struct {
+		name       string
+		employer   string
+		workflowID string
+		want       string
+	}{
+		{"namespaced", "user_1", screeningWorkflowID("user_1", "job.1"), "job.1"},
+		{"legacy", "user_1", legacyScreeningWorkflowID("job-1"), "job-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := screeningJobID(tt.employer, tt.workflowID); got != tt.want {
+				t.Errorf("screeningJobID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if screeningWorkflowID("user_1", "job") == screeningWorkflowID("user_2", "job") {
+		t.Error("employers share a workflow ID for the same job")
+	}
+}
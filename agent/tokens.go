@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"encore.app/agent/utils"
+	"encore.dev"
+	"encore.dev/beta/errs"
+	"encore.dev/cron"
+	"encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+)
+
+// db records the tokens that have been used
+var db = sqldb.NewDatabase("agent", sqldb.DatabaseConfig{
+	Migrations: "./migrations",
+})
+
+// errTokenUsed is returned when a token has already been used for an action
+var errTokenUsed = errors.New("token already used")
+
+// loadTokenKeys loads the keys workflow tokens are signed with, from a
+// comma-separated list of id:key pairs with the current key first. Local
+// environments without keys sign with a random key, so their tokens don't
+// survive a restart.
+func loadTokenKeys() (*utils.KeyRing, error) {
+	if secrets.TokenSigningKeys == "" && encore.Meta().Environment.Cloud == "local" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generate token signing key: %w", err)
+		}
+		rlog.Warn("no token signing keys set, using a random key")
+		return utils.NewKeyRing("local", key), nil
+	}
+
+	keys, err := utils.ParseKeyRing(secrets.TokenSigningKeys)
+	if err != nil {
+		return nil, fmt.Errorf("parse token signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+// verifyToken checks a token from a raw endpoint's path against the
+// endpoint's purpose, writing an error response if it is not valid
+func (s *Service) verifyToken(w http.ResponseWriter, token, purpose string) (*utils.Claims, bool) {
+	claims, err := s.tokens.Verify(token, purpose, time.Now())
+	switch {
+	case err == nil:
+		return claims, true
+	case errors.Is(err, utils.ErrTokenExpired):
+		http.Error(w, "Token expired", http.StatusUnauthorized)
+	case errors.Is(err, utils.ErrWrongPurpose):
+		http.Error(w, "Token not valid for this endpoint", http.StatusForbidden)
+	default:
+		rlog.Error("Failed to verify workflow token", "purpose", purpose, "error", err)
+		http.Error(w, "Invalid token", http.StatusBadRequest)
+	}
+	return nil, false
+}
+
+// tokenError converts a token error to an API error
+func tokenError(err error) error {
+	switch {
+	case errors.Is(err, utils.ErrTokenExpired):
+		return &errs.Error{Code: errs.Unauthenticated, Message: "token expired"}
+	case errors.Is(err, utils.ErrWrongPurpose):
+		return &errs.Error{Code: errs.PermissionDenied, Message: "token not valid for this endpoint"}
+	case errors.Is(err, errTokenUsed):
+		return &errs.Error{Code: errs.AlreadyExists, Message: "token already used"}
+	default:
+		return &errs.Error{Code: errs.InvalidArgument, Message: "invalid token"}
+	}
+}
+
+// redeemToken uses up a token for an action while running fn, so the token
+// can't be used for the action again. The token stays unused if fn fails.
+func redeemToken(ctx context.Context, claims *utils.Claims, action string, fn func() error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Concurrent uses of the token wait here until the first commits or rolls back
+	res, err := tx.Exec(ctx, `
+		INSERT INTO used_tokens (nonce, action, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, claims.Nonce, action, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		return fmt.Errorf("record token use: %w", err)
+	}
+	if res.RowsAffected() == 0 {
+		return errTokenUsed
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Used tokens only need remembering until they expire
+var _ = cron.NewJob("prune-used-tokens", cron.JobConfig{
+	Title:    "Prune expired used tokens",
+	Every:    1 * cron.Hour,
+	Endpoint: PruneUsedTokens,
+})
+
+// PruneUsedTokens removes the records of used tokens that have expired
+//
+//encore:api private
+func (s *Service) PruneUsedTokens(ctx context.Context) error {
+	if _, err := db.Exec(ctx, `DELETE FROM used_tokens WHERE expires_at <= NOW()`); err != nil {
+		return fmt.Errorf("prune used tokens: %w", err)
+	}
+	return nil
+}
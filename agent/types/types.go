@@ -13,16 +13,20 @@ const (
 	// VerificationSubmissionSignalName is the name of the signal channel for verification acceptance
 	VerificationSubmissionSignalName = "verification-submission"
 
-	// AcceptGracePeriod is the time allowed for accepting the screening check
-	AcceptGracePeriod = 72 * time.Hour
-
-	// ResearchGracePeriod is the time allowed for completing research
-	ResearchGracePeriod = 72 * time.Hour
+	// WithdrawSubmissionSignalName is the name of the signal channel for consent withdrawal
+	WithdrawSubmissionSignalName = "withdraw-submission"
 
 	// ScreeningStatusQuery is the name of the query for getting workflow status
 	ScreeningStatusQuery = "screening-status"
 )
 
+// Token purposes, limiting each token to the endpoints it was issued for
+const (
+	TokenPurposeConsent      = "consent" // consent and withdrawal by the candidate
+	TokenPurposeResearch     = "research"
+	TokenPurposeVerification = "verification"
+)
+
 // Status represents the current state of a screening check
 type Status string
 
@@ -32,25 +36,59 @@ const (
 	StatusDeclined  Status = "declined"
 	StatusCompleted Status = "completed"
 	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled" // stopped by the employer
+	StatusWithdrawn Status = "withdrawn" // stopped by the candidate, who withdrew consent
 )
 
-// CandidateDetails contains information provided by the candidate
+// Checks a screening tier can include
+const (
+	CheckConsent           = "consent"
+	CheckResearch          = "research"
+	CheckEmploymentHistory = "employment_history"
+	CheckIncome            = "income"
+	CheckEducation         = "education" // not offered by any tier until education verification is implemented
+)
+
+// Screening steps, in the order they run
+const (
+	StepConsent             = "consent"
+	StepResearchRequest     = "research_request"
+	StepResearch            = "research"
+	StepEmploymentHistory   = "employment_history"   // automated sources
+	StepVerificationRequest = "verification_request" // by email, when no source answers
+	StepVerification        = "verification"
+	StepIncome              = "income"
+	StepEducation           = "education"
+)
+
+// CandidateDetails contains information provided by the candidate. Their
+// SSN, address and phone number are kept in the vault, never in workflows.
 type CandidateDetails struct {
 	FullName         string
-	SSN              string
-	Address          string
-	PhoneNumber      string
-	PhoneCountryCode string
+	PIIRef           string // vault reference to the candidate's personal details
 	CurrentEmployer  string // Company requesting the check
 	PreviousEmployer string // Company to verify employment with
 }
 
+// Vault fields of the candidate's personal details
+const (
+	PIIFieldSSN              = "ssn"
+	PIIFieldAddress          = "address"
+	PIIFieldPhoneNumber      = "phoneNumber"
+	PIIFieldPhoneCountryCode = "phoneCountryCode"
+)
+
 // AcceptSubmissionSignal represents the signal sent when a candidate accepts/declines
 type AcceptSubmissionSignal struct {
 	Accepted         bool
 	CandidateDetails CandidateDetails
 }
 
+// WithdrawSubmissionSignal represents the signal sent when a candidate withdraws consent
+type WithdrawSubmissionSignal struct {
+	Reason string
+}
+
 // SocialMediaLinks contains various social media profile URLs
 type SocialMediaLinks struct {
 	LinkedIn      string `json:"linkedIn,omitempty"`
@@ -147,16 +185,76 @@ type VerificationResult struct {
 type EmploymentVerification struct {
 	CompanyName      string
 	Position         string
-	StartDate        string
-	EndDate          string
-	CurrentSalary    string
+	StartDate        string // YYYY-MM-DD
+	EndDate          string // YYYY-MM-DD, empty while still employed
+	CurrentSalary    string // annual, in the employer's currency
 	ReasonForLeaving string
 }
 
+// VerificationOutcome is the verifying employer's answer to a verification request
+type VerificationOutcome string
+
+const (
+	VerificationConfirmed VerificationOutcome = "confirmed" // every detail confirmed
+	VerificationPartial   VerificationOutcome = "partial"   // some details could not be confirmed
+	VerificationDisputed  VerificationOutcome = "disputed"  // some details are wrong
+	VerificationNoRecord  VerificationOutcome = "no_record" // no record of the candidate
+)
+
+// Employment details a verifier can leave unconfirmed or dispute
+const (
+	VerificationFieldPosition         = "position"
+	VerificationFieldStartDate        = "startDate"
+	VerificationFieldEndDate          = "endDate"
+	VerificationFieldSalary           = "salary"
+	VerificationFieldReasonForLeaving = "reasonForLeaving"
+)
+
+// VerificationDispute is a detail the verifier says is wrong
+type VerificationDispute struct {
+	Field  string // see VerificationField*
+	Reason string
+}
+
 // VerificationSubmissionSignal represents the signal sent when employment verification is completed
 type VerificationSubmissionSignal struct {
-	Verified bool
-	Profile  EmploymentVerification
+	Verified    bool // whether the employer confirmed the candidate worked there
+	Outcome     VerificationOutcome
+	Profile     EmploymentVerification
+	Unconfirmed []string              // details the verifier could not confirm, see VerificationField*
+	Disputes    []VerificationDispute // details the verifier says are wrong
+	VerifiedBy  string                // name and title of the verifier
+	Notes       string
+}
+
+// Decision is the adjudicated outcome of a screening
+type Decision string
+
+// Decisions, from best to worst
+const (
+	DecisionPass   Decision = "pass"
+	DecisionReview Decision = "review" // needs a person to decide
+	DecisionFail   Decision = "fail"
+)
+
+// Worse reports whether d is a worse decision than other
+func (d Decision) Worse(other Decision) bool {
+	rank := map[Decision]int{DecisionPass: 0, DecisionReview: 1, DecisionFail: 2}
+	return rank[d] > rank[other]
+}
+
+// DecisionReason is a rule that matched a screening, and why
+type DecisionReason struct {
+	Rule     string
+	Decision Decision
+	Detail   string
+}
+
+// Adjudication is the decision on a screening with the reasons for it
+type Adjudication struct {
+	Decision Decision
+	Reasons  []DecisionReason // rules that matched, none for a pass
+	RuleSet  string           // the configured rule set that was applied
 }
 
 // EmailParams represents parameters for sending an email
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// Redacted replaces the values of redacted fields
+const Redacted = "[REDACTED]"
+
+// RedactingCodec is a Temporal payload codec that blanks personal details
+// out of JSON payloads before they leave the process, so workflow history
+// never holds them even if one is passed to a workflow by mistake. Redaction
+// can't be undone, so payloads are decoded unchanged.
+type RedactingCodec struct {
+	fields map[string]bool
+}
+
+// NewRedactingCodec creates a codec redacting the named fields, matched
+// case-insensitively at any depth
+func NewRedactingCodec(fields ...string) *RedactingCodec {
+	c := &RedactingCodec{fields: make(map[string]bool, len(fields))}
+	for _, f := range fields {
+		c.fields[strings.ToLower(f)] = true
+	}
+	return c
+}
+
+// Encode redacts the fields of JSON payloads
+func (c *RedactingCodec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		result[i] = p
+		if string(p.GetMetadata()[converter.MetadataEncoding]) != converter.MetadataEncodingJSON {
+			continue
+		}
+
+		// Numbers are kept as written, so only redacted fields change
+		dec := json.NewDecoder(bytes.NewReader(p.GetData()))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil || !c.redact(v) {
+			continue
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = &commonpb.Payload{Metadata: p.GetMetadata(), Data: data}
+	}
+	return result, nil
+}
+
+// Decode returns payloads unchanged
+func (c *RedactingCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	return payloads, nil
+}
+
+// redact replaces the values of redacted fields in a decoded JSON value,
+// reporting whether it changed anything
+func (c *RedactingCodec) redact(v any) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if !c.fields[strings.ToLower(k)] {
+				changed = c.redact(field) || changed
+				continue
+			}
+			if field != nil && field != "" && field != Redacted {
+				v[k] = Redacted
+				changed = true
+			}
+		}
+	case []any:
+		for _, elem := range v {
+			changed = c.redact(elem) || changed
+		}
+	}
+	return changed
+}
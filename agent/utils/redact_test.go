@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"testing"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+func TestRedactingCodecEncode(t *testing.T) {
+	codec := NewRedactingCodec("SSN", "dateOfBirth")
+	jsonPayload := func(data string) *commonpb.Payload {
+		return &commonpb.Payload{
+			Metadata: map[string][]byte{converter.MetadataEncoding: []byte(converter.MetadataEncodingJSON)},
+			Data:     []byte(data),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		payload *commonpb.Payload
+		want    string
+	}{
+		{"top level", jsonPayload(`{"name":"Jane","ssn":"123-45-6789"}`), `{"name":"Jane","ssn":"[REDACTED]"}`},
+		{"any case", jsonPayload(`{"SSN":"123-45-6789","DateOfBirth":"1990-01-01"}`), `{"DateOfBirth":"[REDACTED]","SSN":"[REDACTED]"}`},
+		{"nested", jsonPayload(`{"candidate":{"ssn":"123-45-6789"},"list":[{"ssn":"1"}]}`), `{"candidate":{"ssn":"[REDACTED]"},"list":[{"ssn":"[REDACTED]"}]}`},
+		{"numbers kept as written", jsonPayload(`{"ssn":123456789,"salary":85000.50}`), `{"salary":85000.50,"ssn":"[REDACTED]"}`},
+		{"empty values kept", jsonPayload(`{"ssn":"","dateOfBirth":null}`), `{"ssn":"","dateOfBirth":null}`},
+		{"nothing to redact", jsonPayload(`{"name":"Jane"}`), `{"name":"Jane"}`},
+		{"not an object", jsonPayload(`"123-45-6789"`), `"123-45-6789"`},
+		{"invalid json", jsonPayload(`{"ssn":`), `{"ssn":`},
+		{"binary", &commonpb.Payload{
+			Metadata: map[string][]byte{converter.MetadataEncoding: []byte(converter.MetadataEncodingBinary)},
+			Data:     []byte(`{"ssn":"123-45-6789"}`),
+		}, `{"ssn":"123-45-6789"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := codec.Encode([]*commonpb.Payload{tt.payload})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(encoded[0].GetData()); got != tt.want {
+				t.Errorf("encoded = %s, want %s", got, tt.want)
+			}
+			if string(encoded[0].GetMetadata()[converter.MetadataEncoding]) != string(tt.payload.GetMetadata()[converter.MetadataEncoding]) {
+				t.Error("encoding metadata changed")
+			}
+
+			decoded, err := codec.Decode(encoded)
+			if err != nil || string(decoded[0].GetData()) != tt.want {
+				t.Errorf("decoded = %s, %v, want it unchanged", decoded[0].GetData(), err)
+			}
+		})
+	}
+}
@@ -1,31 +1,123 @@
 package utils
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
+)
 
-	"go.temporal.io/sdk/workflow"
+// Token errors
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+	ErrWrongPurpose = errors.New("token is for a different purpose")
 )
 
-// GenerateWorkflowToken generates a token based on workflow execution ID and run ID
-func GenerateWorkflowToken(ctx workflow.Context) (string, error) {
-	info := workflow.GetInfo(ctx)
-	token := fmt.Sprintf("%s:%s", info.WorkflowExecution.ID, info.WorkflowExecution.RunID)
-	return base64.URLEncoding.EncodeToString([]byte(token)), nil
+// tokenVersion prefixes every token, so the format can change later
+const tokenVersion = "v1"
+
+// Claims are the contents of a workflow token
+type Claims struct {
+	WorkflowID string `json:"wid"`
+	RunID      string `json:"rid"`
+	Purpose    string `json:"pur"` // consent, research or verification
+	ExpiresAt  int64  `json:"exp"` // unix seconds
+	Nonce      string `json:"nonce"`
+}
+
+// KeyRing holds the keys workflow tokens are signed with. Tokens are signed
+// with the current key and verified with any key, so keys can be rotated by
+// adding a new current key and removing the old one once its tokens expire.
+type KeyRing struct {
+	current string
+	keys    map[string][]byte
 }
 
-// ParseWorkflowToken extracts workflow execution ID and run ID from a token
-func ParseWorkflowToken(token string) (workflowID, runID string, err error) {
-	decoded, err := base64.URLEncoding.DecodeString(token)
+// ParseKeyRing parses a comma-separated list of id:key pairs, each key
+// base64 encoded. The first key is the current one.
+func ParseKeyRing(spec string) (*KeyRing, error) {
+	k := &KeyRing{keys: make(map[string][]byte)}
+	for _, pair := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" || strings.Contains(id, ".") {
+			return nil, fmt.Errorf("invalid key entry: expected id:key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", id, err)
+		}
+		if len(key) < 32 {
+			return nil, fmt.Errorf("key %s must be at least 32 bytes", id)
+		}
+		if _, ok := k.keys[id]; ok {
+			return nil, fmt.Errorf("duplicate key id: %s", id)
+		}
+		if k.current == "" {
+			k.current = id
+		}
+		k.keys[id] = key
+	}
+	return k, nil
+}
+
+// NewKeyRing creates a key ring with a single key
+func NewKeyRing(id string, key []byte) *KeyRing {
+	return &KeyRing{current: id, keys: map[string][]byte{id: key}}
+}
+
+// Sign creates a token carrying the claims, signed with the current key
+func (k *KeyRing) Sign(c *Claims) (string, error) {
+	payload, err := json.Marshal(c)
 	if err != nil {
-		return "", "", fmt.Errorf("invalid token format: %w", err)
+		return "", fmt.Errorf("marshal claims: %w", err)
 	}
+	signed := tokenVersion + "." + k.current + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(k.keys[k.current], signed)), nil
+}
 
-	parts := strings.Split(string(decoded), ":")
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid token format: expected workflowID:runID")
+// Verify checks a token's signature, purpose and expiry, and returns its claims
+func (k *KeyRing) Verify(token, purpose string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 || parts[0] != tokenVersion {
+		return nil, ErrInvalidToken
+	}
+	key, ok := k.keys[parts[1]]
+	if !ok {
+		return nil, ErrInvalidToken
 	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal(sig, sign(key, strings.Join(parts[:3], "."))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if c.Purpose != purpose {
+		return nil, ErrWrongPurpose
+	}
+	if !now.Before(time.Unix(c.ExpiresAt, 0)) {
+		return nil, ErrTokenExpired
+	}
+	return &c, nil
+}
 
-	return parts[0], parts[1], nil
+// sign computes the HMAC-SHA256 of a message
+func sign(key []byte, msg string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msg))
+	return mac.Sum(nil)
 }
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyRingVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	oldKey, newKey := bytes.Repeat([]byte("a"), 32), bytes.Repeat([]byte("b"), 32)
+	old := NewKeyRing("k1", oldKey)
+	rotated, err := ParseKeyRing("k2:" + base64.StdEncoding.EncodeToString(newKey) + ",k1:" + base64.StdEncoding.EncodeToString(oldKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := NewKeyRing("k1", bytes.Repeat([]byte("c"), 32))
+
+	claims := &Claims{WorkflowID: "wf", RunID: "run", Purpose: "consent", ExpiresAt: now.Add(time.Hour).Unix(), Nonce: "n"}
+	sign := func(k *KeyRing, c *Claims) string {
+		token, err := k.Sign(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := sign(old, claims)
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name    string
+		ring    *KeyRing
+		token   string
+		purpose string
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", old, valid, "consent", now, nil},
+		{"old key after rotation", rotated, valid, "consent", now, nil},
+		{"new key after rotation", rotated, sign(rotated, claims), "consent", now, nil},
+		{"new key before rotation", old, sign(rotated, claims), "consent", now, ErrInvalidToken},
+		{"other key", other, valid, "consent", now, ErrInvalidToken},
+		{"wrong purpose", old, valid, "research", now, ErrWrongPurpose},
+		{"expired", old, valid, "consent", now.Add(time.Hour), ErrTokenExpired},
+		{"tampered claims", old, strings.Join([]string{parts[0], parts[1], base64.RawURLEncoding.EncodeToString([]byte(`{"wid":"other","pur":"consent","exp":9999999999}`)), parts[3]}, "."), "consent", now, ErrInvalidToken},
+		{"tampered key id", rotated, strings.Join([]string{parts[0], "k2", parts[2], parts[3]}, "."), "consent", now, ErrInvalidToken},
+		{"other version", old, "v2" + strings.TrimPrefix(valid, "v1"), "consent", now, ErrInvalidToken},
+		{"truncated", old, strings.Join(parts[:3], "."), "consent", now, ErrInvalidToken},
+		{"empty", old, "", "consent", now, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.ring.Verify(tt.token, tt.purpose, tt.now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *got != *claims {
+				t.Errorf("claims = %+v, want %+v", got, claims)
+			}
+		})
+	}
+}
+
+func TestParseKeyRing(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("a"), 32))
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"single", "k1:" + key, false},
+		{"several", "k2:" + key + ", k1:" + key, false},
+		{"no id", ":" + key, true},
+		{"dot in id", "k.1:" + key, true},
+		{"not base64", "k1:???", true},
+		{"short key", "k1:" + base64.StdEncoding.EncodeToString([]byte("short")), true},
+		{"duplicate id", "k1:" + key + ",k1:" + key, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeyRing(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"encore.app/agent/types"
+	"encore.dev/rlog"
+)
+
+// maxSalary bounds submitted salaries, catching values entered in the wrong unit
+const maxSalary = 100_000_000
+
+// VerificationResponse represents the API response for verification endpoints
+type VerificationResponse struct {
+	CandidateName string `json:"candidateName"`
+	EmployerName  string `json:"employerName"`
+}
+
+// VerificationSubmission represents the verifying employer's submission
+type VerificationSubmission struct {
+	Outcome          types.VerificationOutcome `json:"outcome"`
+	CompanyName      string                    `json:"companyName"`
+	Position         string                    `json:"position"`
+	StartDate        string                    `json:"startDate"` // YYYY-MM-DD
+	EndDate          string                    `json:"endDate"`   // YYYY-MM-DD, empty while still employed
+	CurrentSalary    string                    `json:"currentSalary"`
+	ReasonForLeaving string                    `json:"reasonForLeaving"`
+	Unconfirmed      []string                  `json:"unconfirmed"` // details that could not be confirmed
+	Disputes         []VerificationDispute     `json:"disputes"`    // details that are wrong
+	VerifiedBy       string                    `json:"verifiedBy"`
+	Notes            string                    `json:"notes"`
+}
+
+// VerificationDispute represents a detail the verifier says is wrong
+type VerificationDispute struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// verificationFields lists the details a verifier can leave unconfirmed or dispute
+var verificationFields = map[string]bool{
+	types.VerificationFieldPosition:         true,
+	types.VerificationFieldStartDate:        true,
+	types.VerificationFieldEndDate:          true,
+	types.VerificationFieldSalary:           true,
+	types.VerificationFieldReasonForLeaving: true,
+}
+
+// ServeVerificationAPI handles the employment verification API endpoints
+//
+//encore:api public raw path=/api/verification/*token
+func (s *Service) ServeVerificationAPI(w http.ResponseWriter, req *http.Request) {
+	// Enable CORS for development
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	// Handle preflight requests
+	if req.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Extract and validate token
+	path := strings.TrimPrefix(req.URL.Path, "/api/verification/")
+	token := strings.TrimSuffix(path, "/")
+	if token == "" {
+		http.Error(w, "Invalid token", http.StatusBadRequest)
+		return
+	}
+
+	// Verify workflow token
+	claims, ok := s.verifyToken(w, token, types.TokenPurposeVerification)
+	if !ok {
+		return
+	}
+	workflowID, runID := claims.WorkflowID, claims.RunID
+
+	// Handle GET request for verification info
+	if req.Method == http.MethodGet {
+		_, candidateName, employerName, err := getResearchInfo(workflowID, runID, s)
+		if err != nil {
+			rlog.Error("Failed to get verification details", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		response := VerificationResponse{
+			CandidateName: candidateName,
+			EmployerName:  employerName,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			rlog.Error("Failed to encode response", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Handle POST request for verification submission
+	if req.Method == http.MethodPost {
+		var submission VerificationSubmission
+		if err := json.NewDecoder(req.Body).Decode(&submission); err != nil {
+			http.Error(w, "Failed to parse request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := validateVerification(&submission, time.Now()); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid verification: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		// Signal the workflow with the verification
+		signal := &types.VerificationSubmissionSignal{
+			Verified: submission.Outcome != types.VerificationNoRecord,
+			Outcome:  submission.Outcome,
+			Profile: types.EmploymentVerification{
+				CompanyName:      submission.CompanyName,
+				Position:         submission.Position,
+				StartDate:        submission.StartDate,
+				EndDate:          submission.EndDate,
+				CurrentSalary:    submission.CurrentSalary,
+				ReasonForLeaving: submission.ReasonForLeaving,
+			},
+			Unconfirmed: submission.Unconfirmed,
+			VerifiedBy:  submission.VerifiedBy,
+			Notes:       submission.Notes,
+		}
+		for _, d := range submission.Disputes {
+			signal.Disputes = append(signal.Disputes, types.VerificationDispute{Field: d.Field, Reason: d.Reason})
+		}
+
+		// Each token can only be submitted once
+		err := redeemToken(req.Context(), claims, "verification", func() error {
+			return s.Client().SignalWorkflow(context.Background(), workflowID, runID, types.VerificationSubmissionSignalName, signal)
+		})
+		if errors.Is(err, errTokenUsed) {
+			http.Error(w, "Token already used", http.StatusConflict)
+			return
+		} else if err != nil {
+			rlog.Error("Failed to signal workflow", "error", err)
+			http.Error(w, "Failed to signal workflow", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// validateVerification checks a verification submission, normalizing its
+// salary. Details left unconfirmed may be blank.
+func validateVerification(sub *VerificationSubmission, now time.Time) error {
+	if sub.VerifiedBy == "" {
+		return fmt.Errorf("verifier name is required")
+	}
+
+	switch sub.Outcome {
+	case types.VerificationNoRecord:
+		// Nothing else to check without a record of the candidate
+		return nil
+	case types.VerificationConfirmed:
+		if len(sub.Unconfirmed) > 0 || len(sub.Disputes) > 0 {
+			return fmt.Errorf("a confirmed verification can't have unconfirmed or disputed details")
+		}
+	case types.VerificationPartial:
+		if len(sub.Unconfirmed) == 0 {
+			return fmt.Errorf("a partial verification must list the unconfirmed details")
+		}
+	case types.VerificationDisputed:
+		if len(sub.Disputes) == 0 {
+			return fmt.Errorf("a disputed verification must list the disputed details")
+		}
+	default:
+		return fmt.Errorf("unknown outcome: %q", sub.Outcome)
+	}
+
+	unconfirmed := map[string]bool{}
+	for _, field := range sub.Unconfirmed {
+		if !verificationFields[field] {
+			return fmt.Errorf("unknown detail: %q", field)
+		}
+		unconfirmed[field] = true
+	}
+	for _, d := range sub.Disputes {
+		if !verificationFields[d.Field] {
+			return fmt.Errorf("unknown detail: %q", d.Field)
+		}
+		if d.Reason == "" {
+			return fmt.Errorf("a reason is required for disputing %s", d.Field)
+		}
+	}
+
+	// The position and start date are required unless they couldn't be confirmed
+	if sub.Position == "" && !unconfirmed[types.VerificationFieldPosition] {
+		return fmt.Errorf("position is required")
+	}
+	if sub.StartDate == "" && !unconfirmed[types.VerificationFieldStartDate] {
+		return fmt.Errorf("start date is required")
+	}
+
+	var start, end time.Time
+	if sub.StartDate != "" {
+		var err error
+		if start, err = parseVerificationDate(sub.StartDate, now); err != nil {
+			return fmt.Errorf("start date: %w", err)
+		}
+	}
+	if sub.EndDate != "" {
+		var err error
+		if end, err = parseVerificationDate(sub.EndDate, now); err != nil {
+			return fmt.Errorf("end date: %w", err)
+		}
+		if !start.IsZero() && end.Before(start) {
+			return fmt.Errorf("end date is before start date")
+		}
+	}
+
+	if sub.CurrentSalary != "" {
+		salary, err := parseSalary(sub.CurrentSalary)
+		if err != nil {
+			return err
+		}
+		sub.CurrentSalary = salary
+	}
+	return nil
+}
+
+// parseVerificationDate parses a YYYY-MM-DD date, which can't be in the future
+func parseVerificationDate(value string, now time.Time) (time.Time, error) {
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD")
+	}
+	if date.After(now) {
+		return time.Time{}, fmt.Errorf("can't be in the future")
+	}
+	return date, nil
+}
+
+// parseSalary parses an annual salary such as "85,000.00", returning it
+// without separators
+func parseSalary(value string) (string, error) {
+	normalized := strings.ReplaceAll(strings.TrimSpace(value), ",", "")
+	salary, err := strconv.ParseFloat(normalized, 64)
+	if err != nil || math.IsNaN(salary) || math.IsInf(salary, 0) {
+		return "", fmt.Errorf("salary must be a number")
+	}
+	if salary <= 0 || salary > maxSalary {
+		return "", fmt.Errorf("salary must be between 0 and %d", maxSalary)
+	}
+	return normalized, nil
+}
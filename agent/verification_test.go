@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"encore.app/agent/types"
+)
+
+func TestValidateVerification(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	confirmed := func(change func(sub *VerificationSubmission)) *VerificationSubmission {
+		sub := &VerificationSubmission{
+			Outcome:       types.VerificationConfirmed,
+			Position:      "Engineer",
+			StartDate:     "2020-01-06",
+			EndDate:       "2023-12-31",
+			CurrentSalary: "85,000.00",
+			VerifiedBy:    "Pat HR",
+		}
+		if change != nil {
+			change(sub)
+		}
+		return sub
+	}
+
+	tests := []struct {
+		name       string
+		sub        *VerificationSubmission
+		wantErr    bool
+		wantSalary string
+	}{
+		{"confirmed", confirmed(nil), false, "85000.00"},
+		{"still employed", confirmed(func(s *VerificationSubmission) { s.EndDate = "" }), false, "85000.00"},
+		{"no salary", confirmed(func(s *VerificationSubmission) { s.CurrentSalary = "" }), false, ""},
+		{"no verifier", confirmed(func(s *VerificationSubmission) { s.VerifiedBy = "" }), true, ""},
+		{"unknown outcome", confirmed(func(s *VerificationSubmission) { s.Outcome = "maybe" }), true, ""},
+		{"no record", &VerificationSubmission{Outcome: types.VerificationNoRecord, VerifiedBy: "Pat HR"}, false, ""},
+		{"confirmed with unconfirmed details", confirmed(func(s *VerificationSubmission) {
+			s.Unconfirmed = []string{types.VerificationFieldSalary}
+		}), true, ""},
+		{"partial", confirmed(func(s *VerificationSubmission) {
+			s.Outcome = types.VerificationPartial
+			s.Unconfirmed = []string{types.VerificationFieldPosition, types.VerificationFieldStartDate}
+			s.Position, s.StartDate = "", ""
+		}), false, "85000.00"},
+		{"partial without unconfirmed details", confirmed(func(s *VerificationSubmission) { s.Outcome = types.VerificationPartial }), true, ""},
+		{"partial with unknown detail", confirmed(func(s *VerificationSubmission) {
+			s.Outcome = types.VerificationPartial
+			s.Unconfirmed = []string{"shoeSize"}
+		}), true, ""},
+		{"disputed", confirmed(func(s *VerificationSubmission) {
+			s.Outcome = types.VerificationDisputed
+			s.Disputes = []VerificationDispute{{Field: types.VerificationFieldEndDate, Reason: "left in 2022"}}
+		}), false, "85000.00"},
+		{"disputed without reason", confirmed(func(s *VerificationSubmission) {
+			s.Outcome = types.VerificationDisputed
+			s.Disputes = []VerificationDispute{{Field: types.VerificationFieldEndDate}}
+		}), true, ""},
+		{"disputed without disputes", confirmed(func(s *VerificationSubmission) { s.Outcome = types.VerificationDisputed }), true, ""},
+		{"missing position", confirmed(func(s *VerificationSubmission) { s.Position = "" }), true, ""},
+		{"missing start date", confirmed(func(s *VerificationSubmission) { s.StartDate = "" }), true, ""},
+		{"malformed date", confirmed(func(s *VerificationSubmission) { s.StartDate = "06/01/2020" }), true, ""},
+		{"future date", confirmed(func(s *VerificationSubmission) { s.EndDate = "2024-06-02" }), true, ""},
+		{"end before start", confirmed(func(s *VerificationSubmission) { s.EndDate = "2019-12-31" }), true, ""},
+		{"invalid salary", confirmed(func(s *VerificationSubmission) { s.CurrentSalary = "lots" }), true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateVerification(tt.sub, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && tt.sub.CurrentSalary != tt.wantSalary {
+				t.Errorf("salary = %q, want %q", tt.sub.CurrentSalary, tt.wantSalary)
+			}
+		})
+	}
+}
+
+func TestParseSalary(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"85000", "85000", false},
+		{"85,000.00", "85000.00", false},
+		{" 1,250,000 ", "1250000", false},
+		{"0.01", "0.01", false},
+		{"100000000", "100000000", false},
+		{"100000001", "", true},
+		{"0", "", true},
+		{"-5000", "", true},
+		{"NaN", "", true},
+		{"Inf", "", true},
+		{"$85,000", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseSalary(tt.value)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseSalary(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
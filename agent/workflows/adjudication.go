@@ -0,0 +1,264 @@
+package workflows
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"encore.app/agent/types"
+)
+
+// Adjudication rules
+const (
+	RuleConsentDeclined      = "consent_declined"      // the candidate did not consent
+	RuleIncomplete           = "incomplete"            // steps were not completed
+	RuleEmploymentUnverified = "employment_unverified" // no source or employer verified employment
+	RuleEmploymentNoRecord   = "employment_no_record"  // the employer has no record of the candidate
+	RuleEmploymentDisputed   = "employment_disputed"   // the employer disputes details
+	RuleDateMismatch         = "date_mismatch"         // verified dates differ from the researched profile
+	RuleEmploymentGap        = "employment_gap"        // the researched profile has a gap over MaxGapMonths
+	RuleIncomeUnverified     = "income_unverified"
+	RuleEducationUnverified  = "education_unverified"
+)
+
+// AdjudicationRule is a rule and the decision it leads to when it matches
+type AdjudicationRule struct {
+	Rule         string
+	Decision     types.Decision // review or fail
+	MaxGapMonths int            // employment_gap only
+}
+
+// AdjudicationRules is the rule set a screening is adjudicated with. Init
+// resolves it from config, so running screenings keep the rules they
+// started with.
+type AdjudicationRules struct {
+	Name  string // which configured rule sets were applied
+	Rules []AdjudicationRule
+}
+
+// ruleChecks maps each rule to its check, which returns a detail for each
+// way the screening matches it
+var ruleChecks = map[string]func(r AdjudicationRule, in *ScreeningWorkflowInput, s *ScreeningStatus, now time.Time) []string{
+	RuleConsentDeclined:      checkConsentDeclined,
+	RuleIncomplete:           checkIncomplete,
+	RuleEmploymentUnverified: checkEmploymentUnverified,
+	RuleEmploymentNoRecord:   checkEmploymentNoRecord,
+	RuleEmploymentDisputed:   checkEmploymentDisputed,
+	RuleDateMismatch:         checkDateMismatch,
+	RuleEmploymentGap:        checkEmploymentGap,
+	RuleIncomeUnverified:     checkIncomeUnverified,
+	RuleEducationUnverified:  checkEducationUnverified,
+}
+
+// Validate checks that the rules are known and lead to a review or fail
+func (r *AdjudicationRules) Validate() error {
+	for _, rule := range r.Rules {
+		if _, ok := ruleChecks[rule.Rule]; !ok {
+			return fmt.Errorf("unknown adjudication rule: %s", rule.Rule)
+		}
+		if rule.Decision != types.DecisionReview && rule.Decision != types.DecisionFail {
+			return fmt.Errorf("rule %s: decision must be review or fail", rule.Rule)
+		}
+		if rule.Rule == RuleEmploymentGap && rule.MaxGapMonths <= 0 {
+			return fmt.Errorf("rule %s: max gap months must be positive", rule.Rule)
+		}
+	}
+	return nil
+}
+
+// Adjudicate decides a finished screening: the worst decision of the rules
+// it matches, or a pass if it matches none
+func Adjudicate(rules *AdjudicationRules, input *ScreeningWorkflowInput, status *ScreeningStatus, now time.Time) *types.Adjudication {
+	result := &types.Adjudication{Decision: types.DecisionPass, Reasons: []types.DecisionReason{}}
+	if rules == nil {
+		return result
+	}
+	result.RuleSet = rules.Name
+
+	for _, rule := range rules.Rules {
+		check, ok := ruleChecks[rule.Rule]
+		if !ok {
+			continue
+		}
+		for _, detail := range check(rule, input, status, now) {
+			result.Reasons = append(result.Reasons, types.DecisionReason{
+				Rule:     rule.Rule,
+				Decision: rule.Decision,
+				Detail:   detail,
+			})
+			if rule.Decision.Worse(result.Decision) {
+				result.Decision = rule.Decision
+			}
+		}
+	}
+	return result
+}
+
+func checkConsentDeclined(_ AdjudicationRule, _ *ScreeningWorkflowInput, s *ScreeningStatus, _ time.Time) []string {
+	if s.ConsentReceived {
+		return nil
+	}
+	return []string{"the candidate did not consent"}
+}
+
+func checkIncomplete(_ AdjudicationRule, _ *ScreeningWorkflowInput, s *ScreeningStatus, _ time.Time) []string {
+	// A screening without consent stops there, which consent_declined covers
+	if !s.ConsentReceived || len(s.RemainingSteps) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("steps not completed: %s", strings.Join(s.RemainingSteps, ", "))}
+}
+
+// checksEmployment reports whether the screening checked employment
+func checksEmployment(in *ScreeningWorkflowInput, s *ScreeningStatus) bool {
+	return s.ConsentReceived && in.Package != nil && in.Package.Has(types.CheckEmploymentHistory)
+}
+
+func checkEmploymentUnverified(_ AdjudicationRule, in *ScreeningWorkflowInput, s *ScreeningStatus, _ time.Time) []string {
+	if !checksEmployment(in, s) {
+		return nil
+	}
+	if s.History != nil && s.History.Verified {
+		return nil
+	}
+	switch v := s.Verification; {
+	case v == nil:
+		return []string{fmt.Sprintf("%s did not verify employment", in.PreviousEmployer)}
+	case !v.Verified && v.Outcome != types.VerificationNoRecord:
+		return []string{fmt.Sprintf("%s could not verify employment", in.PreviousEmployer)}
+	}
+	return nil
+}
+
+func checkEmploymentNoRecord(_ AdjudicationRule, in *ScreeningWorkflowInput, s *ScreeningStatus, _ time.Time) []string {
+	if !checksEmployment(in, s) || s.Verification == nil || s.Verification.Outcome != types.VerificationNoRecord {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s has no record of the candidate", in.PreviousEmployer)}
+}
+
+func checkEmploymentDisputed(_ AdjudicationRule, in *ScreeningWorkflowInput, s *ScreeningStatus, _ time.Time) []string {
+	if !checksEmployment(in, s) || s.Verification == nil {
+		return nil
+	}
+	var details []string
+	for _, d := range s.Verification.Disputes {
+		details = append(details, fmt.Sprintf("%s disputes %s: %s", in.PreviousEmployer, d.Field, d.Reason))
+	}
+	return details
+}
+
+func checkDateMismatch(_ AdjudicationRule, in *ScreeningWorkflowInput, s *ScreeningStatus, _ time.Time) []string {
+	if !checksEmployment(in, s) {
+		return nil
+	}
+
+	var details []string
+	if s.Verification != nil {
+		for _, d := range s.Verification.Disputes {
+			if d.Field == types.VerificationFieldStartDate || d.Field == types.VerificationFieldEndDate {
+				details = append(details, fmt.Sprintf("%s disputes the %s", in.PreviousEmployer, d.Field))
+			}
+		}
+	}
+
+	// Compare the verified dates with the researched ones, to the month
+	start, end := verifiedDates(s)
+	experience := researchedExperience(s, in.PreviousEmployer)
+	if experience == nil {
+		return details
+	}
+	if len(start) >= 7 && !experience.StartDate.IsZero() && experience.StartDate.Format("2006-01") != start[:7] {
+		details = append(details, fmt.Sprintf("start date: researched %s, verified %s", experience.StartDate.Format("2006-01"), start))
+	}
+	if len(end) >= 7 && !experience.EndDate.IsZero() && experience.EndDate.Format("2006-01") != end[:7] {
+		details = append(details, fmt.Sprintf("end date: researched %s, verified %s", experience.EndDate.Format("2006-01"), end))
+	}
+	return details
+}
+
+// verifiedDates returns the employment dates verified by a source or the
+// employer, empty if neither verified them
+func verifiedDates(s *ScreeningStatus) (start, end string) {
+	if s.History != nil && s.History.Verified && s.History.Employment != nil {
+		return s.History.Employment.StartDate, s.History.Employment.EndDate
+	}
+	if s.Verification != nil && s.Verification.Verified {
+		return s.Verification.Profile.StartDate, s.Verification.Profile.EndDate
+	}
+	return "", ""
+}
+
+// researchedExperience returns the researched experience at an employer
+func researchedExperience(s *ScreeningStatus, employer string) *types.Experience {
+	if s.Research == nil || !s.Research.Verified {
+		return nil
+	}
+	for _, experiences := range [][]types.Experience{s.Research.Profile.CurrentExperiences, s.Research.Profile.PreviousExperiences} {
+		for i := range experiences {
+			if strings.EqualFold(experiences[i].Company, employer) {
+				return &experiences[i]
+			}
+		}
+	}
+	return nil
+}
+
+func checkEmploymentGap(r AdjudicationRule, _ *ScreeningWorkflowInput, s *ScreeningStatus, now time.Time) []string {
+	if !s.ConsentReceived || s.Research == nil || !s.Research.Verified {
+		return nil
+	}
+
+	var experiences []types.Experience
+	for _, e := range append(append([]types.Experience{}, s.Research.Profile.CurrentExperiences...), s.Research.Profile.PreviousExperiences...) {
+		if !e.StartDate.IsZero() {
+			experiences = append(experiences, e)
+		}
+	}
+	if len(experiences) == 0 {
+		return nil
+	}
+	sort.Slice(experiences, func(i, j int) bool {
+		return experiences[i].StartDate.Before(experiences[j].StartDate)
+	})
+
+	// Walk the experiences in order, tracking when the latest one so far
+	// ended. Experiences without an end date are ongoing.
+	var details []string
+	covered := experiences[0].StartDate
+	for _, e := range experiences {
+		if e.StartDate.After(covered.AddDate(0, r.MaxGapMonths, 0)) {
+			details = append(details, fmt.Sprintf("gap from %s to %s", covered.Format("2006-01"), e.StartDate.Format("2006-01")))
+		}
+		end := e.EndDate
+		if end.IsZero() {
+			end = now
+		}
+		if end.After(covered) {
+			covered = end
+		}
+	}
+	if now.After(covered.AddDate(0, r.MaxGapMonths, 0)) {
+		details = append(details, fmt.Sprintf("no employment since %s", covered.Format("2006-01")))
+	}
+	return details
+}
+
+func checkIncomeUnverified(_ AdjudicationRule, in *ScreeningWorkflowInput, s *ScreeningStatus, _ time.Time) []string {
+	if !s.ConsentReceived || in.Package == nil || !in.Package.Has(types.CheckIncome) {
+		return nil
+	}
+	if s.Income != nil && s.Income.Verified {
+		return nil
+	}
+	return []string{"income could not be verified"}
+}
+
+func checkEducationUnverified(_ AdjudicationRule, _ *ScreeningWorkflowInput, s *ScreeningStatus, _ time.Time) []string {
+	// Education is only checked when the research found qualifications
+	if s.Education == nil || s.Education.Verified {
+		return nil
+	}
+	return []string{"education could not be verified"}
+}
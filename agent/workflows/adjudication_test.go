@@ -0,0 +1,306 @@
+package workflows
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"encore.app/agent/activities/education"
+	"encore.app/agent/activities/employment/connector"
+	"encore.app/agent/activities/employment/history"
+	"encore.app/agent/activities/employment/income"
+	"encore.app/agent/types"
+)
+
+var testNow = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func month(year int, m time.Month) time.Time {
+	return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+var testRules = &AdjudicationRules{
+	Name: "default",
+	Rules: []AdjudicationRule{
+		{Rule: RuleConsentDeclined, Decision: types.DecisionFail},
+		{Rule: RuleIncomplete, Decision: types.DecisionReview},
+		{Rule: RuleEmploymentUnverified, Decision: types.DecisionReview},
+		{Rule: RuleEmploymentNoRecord, Decision: types.DecisionFail},
+		{Rule: RuleEmploymentDisputed, Decision: types.DecisionReview},
+		{Rule: RuleDateMismatch, Decision: types.DecisionReview},
+		{Rule: RuleEmploymentGap, Decision: types.DecisionReview, MaxGapMonths: 6},
+		{Rule: RuleIncomeUnverified, Decision: types.DecisionReview},
+		{Rule: RuleEducationUnverified, Decision: types.DecisionReview},
+	},
+}
+
+// testScreening returns a standard screening that passes every rule, changed by change
+func testScreening(change func(in *ScreeningWorkflowInput, s *ScreeningStatus)) (*ScreeningWorkflowInput, *ScreeningStatus) {
+	in := &ScreeningWorkflowInput{
+		PreviousEmployer: "Acme",
+		Package: &TierPackage{Name: "standard", Version: 1, Checks: []string{
+			types.CheckConsent, types.CheckResearch, types.CheckEmploymentHistory, types.CheckIncome,
+		}},
+	}
+	s := &ScreeningStatus{
+		ConsentReceived: true,
+		Research: &types.ResearchSubmissionSignal{Verified: true, Profile: types.ResearchProfile{
+			CurrentExperiences:  []types.Experience{{Company: "Beta", StartDate: month(2024, time.January)}},
+			PreviousExperiences: []types.Experience{{Company: "Acme", StartDate: month(2020, time.January), EndDate: month(2023, time.December)}},
+		}},
+		History: &history.Result{
+			VerificationResult: types.VerificationResult{Verified: true},
+			Employment:         &connector.EmploymentRecord{EmployerName: "Acme", StartDate: "2020-01-06", EndDate: "2023-12-29"},
+		},
+		Income: &income.Result{Verified: true},
+	}
+	if change != nil {
+		change(in, s)
+	}
+	return in, s
+}
+
+func TestAdjudicate(t *testing.T) {
+	tests := []struct {
+		name         string
+		rules        *AdjudicationRules
+		change       func(in *ScreeningWorkflowInput, s *ScreeningStatus)
+		wantDecision types.Decision
+		wantRules    []string
+	}{
+		{
+			name:         "clean",
+			rules:        testRules,
+			wantDecision: types.DecisionPass,
+		},
+		{
+			name:         "no rules",
+			change:       func(_ *ScreeningWorkflowInput, s *ScreeningStatus) { s.ConsentReceived = false },
+			wantDecision: types.DecisionPass,
+		},
+		{
+			name:         "consent declined",
+			rules:        testRules,
+			change:       func(_ *ScreeningWorkflowInput, s *ScreeningStatus) { s.ConsentReceived = false },
+			wantDecision: types.DecisionFail,
+			wantRules:    []string{RuleConsentDeclined},
+		},
+		{
+			name:         "incomplete",
+			rules:        testRules,
+			change:       func(_ *ScreeningWorkflowInput, s *ScreeningStatus) { s.RemainingSteps = []string{types.StepIncome} },
+			wantDecision: types.DecisionReview,
+			wantRules:    []string{RuleIncomplete},
+		},
+		{
+			name:         "employment unverified",
+			rules:        testRules,
+			change:       func(_ *ScreeningWorkflowInput, s *ScreeningStatus) { s.History = nil },
+			wantDecision: types.DecisionReview,
+			wantRules:    []string{RuleEmploymentUnverified},
+		},
+		{
+			name:  "verified by the employer",
+			rules: testRules,
+			change: func(_ *ScreeningWorkflowInput, s *ScreeningStatus) {
+				s.History = nil
+				s.Verification = &types.VerificationSubmissionSignal{Verified: true, Outcome: types.VerificationConfirmed,
+					Profile: types.EmploymentVerification{StartDate: "2020-01-06", EndDate: "2023-12-29"}}
+			},
+			wantDecision: types.DecisionPass,
+		},
+		{
+			name:  "no record",
+			rules: testRules,
+			change: func(_ *ScreeningWorkflowInput, s *ScreeningStatus) {
+				s.History = nil
+				s.Verification = &types.VerificationSubmissionSignal{Outcome: types.VerificationNoRecord}
+			},
+			wantDecision: types.DecisionFail,
+			wantRules:    []string{RuleEmploymentNoRecord},
+		},
+		{
+			name:  "disputed dates",
+			rules: testRules,
+			change: func(_ *ScreeningWorkflowInput, s *ScreeningStatus) {
+				s.History = nil
+				s.Verification = &types.VerificationSubmissionSignal{Verified: true, Outcome: types.VerificationDisputed,
+					Profile:  types.EmploymentVerification{StartDate: "2020-01-06", EndDate: "2023-12-29"},
+					Disputes: []types.VerificationDispute{{Field: types.VerificationFieldEndDate, Reason: "left in 2022"}}}
+			},
+			wantDecision: types.DecisionReview,
+			wantRules:    []string{RuleEmploymentDisputed, RuleDateMismatch},
+		},
+		{
+			name:  "dates differ from research",
+			rules: testRules,
+			change: func(_ *ScreeningWorkflowInput, s *ScreeningStatus) {
+				s.History.Employment.StartDate = "2019-03-01"
+			},
+			wantDecision: types.DecisionReview,
+			wantRules:    []string{RuleDateMismatch},
+		},
+		{
+			name:  "employment gap",
+			rules: testRules,
+			change: func(_ *ScreeningWorkflowInput, s *ScreeningStatus) {
+				s.Research.Profile.CurrentExperiences[0].StartDate = month(2024, time.March)
+				s.Research.Profile.PreviousExperiences[0].EndDate = month(2022, time.June)
+				s.History.Employment.EndDate = "2022-06-30"
+			},
+			wantDecision: types.DecisionReview,
+			wantRules:    []string{RuleEmploymentGap},
+		},
+		{
+			name:         "income unverified",
+			rules:        testRules,
+			change:       func(_ *ScreeningWorkflowInput, s *ScreeningStatus) { s.Income = &income.Result{} },
+			wantDecision: types.DecisionReview,
+			wantRules:    []string{RuleIncomeUnverified},
+		},
+		{
+			name:  "income not in the tier",
+			rules: testRules,
+			change: func(in *ScreeningWorkflowInput, s *ScreeningStatus) {
+				in.Package.Checks = in.Package.Checks[:3]
+				s.Income = nil
+			},
+			wantDecision: types.DecisionPass,
+		},
+		{
+			name:         "education unverified",
+			rules:        testRules,
+			change:       func(_ *ScreeningWorkflowInput, s *ScreeningStatus) { s.Education = &education.Result{} },
+			wantDecision: types.DecisionReview,
+			wantRules:    []string{RuleEducationUnverified},
+		},
+		{
+			name:  "worst decision wins",
+			rules: testRules,
+			change: func(_ *ScreeningWorkflowInput, s *ScreeningStatus) {
+				s.RemainingSteps = []string{types.StepIncome}
+				s.History = nil
+				s.Verification = &types.VerificationSubmissionSignal{Outcome: types.VerificationNoRecord}
+			},
+			wantDecision: types.DecisionFail,
+			wantRules:    []string{RuleIncomplete, RuleEmploymentNoRecord},
+		},
+		{
+			name: "unknown rule ignored",
+			rules: &AdjudicationRules{Rules: []AdjudicationRule{
+				{Rule: "credit_score", Decision: types.DecisionFail},
+			}},
+			wantDecision: types.DecisionPass,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, s := testScreening(tt.change)
+			got := Adjudicate(tt.rules, in, s, testNow)
+			if got.Decision != tt.wantDecision {
+				t.Errorf("decision = %s, want %s: %+v", got.Decision, tt.wantDecision, got.Reasons)
+			}
+			var rules []string
+			for _, r := range got.Reasons {
+				rules = append(rules, r.Rule)
+				if r.Detail == "" {
+					t.Errorf("reason %s has no detail", r.Rule)
+				}
+			}
+			if !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("rules = %v, want %v", rules, tt.wantRules)
+			}
+			if tt.rules != nil && got.RuleSet != tt.rules.Name {
+				t.Errorf("rule set = %q, want %q", got.RuleSet, tt.rules.Name)
+			}
+		})
+	}
+}
+
+func TestCheckEmploymentGap(t *testing.T) {
+	rule := AdjudicationRule{Rule: RuleEmploymentGap, Decision: types.DecisionReview, MaxGapMonths: 6}
+	tests := []struct {
+		name        string
+		experiences []types.Experience
+		want        []string
+	}{
+		{
+			name: "continuous",
+			experiences: []types.Experience{
+				{Company: "A", StartDate: month(2018, time.January), EndDate: month(2020, time.January)},
+				{Company: "B", StartDate: month(2020, time.February)},
+			},
+		},
+		{
+			name: "gap within the limit",
+			experiences: []types.Experience{
+				{Company: "A", StartDate: month(2018, time.January), EndDate: month(2020, time.January)},
+				{Company: "B", StartDate: month(2020, time.July)},
+			},
+		},
+		{
+			name: "gap over the limit",
+			experiences: []types.Experience{
+				{Company: "A", StartDate: month(2018, time.January), EndDate: month(2020, time.January)},
+				{Company: "B", StartDate: month(2021, time.March)},
+			},
+			want: []string{"gap from 2020-01 to 2021-03"},
+		},
+		{
+			name: "overlapping jobs cover the gap",
+			experiences: []types.Experience{
+				{Company: "A", StartDate: month(2018, time.January), EndDate: month(2019, time.January)},
+				{Company: "B", StartDate: month(2018, time.June), EndDate: month(2021, time.January)},
+				{Company: "C", StartDate: month(2021, time.February)},
+			},
+		},
+		{
+			name: "unordered",
+			experiences: []types.Experience{
+				{Company: "B", StartDate: month(2022, time.January)},
+				{Company: "A", StartDate: month(2018, time.January), EndDate: month(2020, time.January)},
+			},
+			want: []string{"gap from 2020-01 to 2022-01"},
+		},
+		{
+			name: "unemployed since",
+			experiences: []types.Experience{
+				{Company: "A", StartDate: month(2018, time.January), EndDate: month(2023, time.March)},
+			},
+			want: []string{"no employment since 2023-03"},
+		},
+		{
+			name: "undated experiences ignored",
+			experiences: []types.Experience{
+				{Company: "A"},
+				{Company: "B", StartDate: month(2020, time.January)},
+			},
+		},
+		{
+			name: "no experiences",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ScreeningStatus{
+				ConsentReceived: true,
+				Research:        &types.ResearchSubmissionSignal{Verified: true, Profile: types.ResearchProfile{PreviousExperiences: tt.experiences}},
+			}
+			got := checkEmploymentGap(rule, nil, s, testNow)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("details = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Without consent or a verified profile there is nothing to check
+	gap := []types.Experience{{Company: "A", StartDate: month(2018, time.January), EndDate: month(2019, time.January)}}
+	for _, s := range []*ScreeningStatus{
+		{Research: &types.ResearchSubmissionSignal{Verified: true, Profile: types.ResearchProfile{PreviousExperiences: gap}}},
+		{ConsentReceived: true, Research: &types.ResearchSubmissionSignal{Profile: types.ResearchProfile{PreviousExperiences: gap}}},
+		{ConsentReceived: true},
+	} {
+		if got := checkEmploymentGap(rule, nil, s, testNow); got != nil {
+			t.Errorf("details = %v, want none", got)
+		}
+	}
+}
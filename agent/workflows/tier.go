@@ -0,0 +1,109 @@
+package workflows
+
+import (
+	"fmt"
+	"time"
+
+	"encore.app/agent/types"
+)
+
+// TierPackage is one version of a screening tier: the checks a screening
+// runs, how long it waits on each party, and its price. A screening is
+// started with a copy of its tier's package, so it keeps the definition it
+// started with when the tier changes.
+type TierPackage struct {
+	Name    string
+	Version int
+	Checks  []string // see types.Check*
+
+	ConsentGracePeriod      time.Duration
+	ResearchGracePeriod     time.Duration
+	VerificationGracePeriod time.Duration
+	Reminders               ReminderSchedule
+
+	Price Price
+}
+
+// Price is the price of a screening
+type Price struct {
+	Amount   int64  // in minor units, e.g. cents
+	Currency string // ISO 4217 code
+}
+
+// ReminderSchedule lists when reminders are sent while waiting on each
+// submission, as ascending offsets from when it was requested
+type ReminderSchedule struct {
+	Consent  []time.Duration
+	Research []time.Duration
+}
+
+// checkSteps lists the steps of each check, in the order checks run
+var checkSteps = []struct {
+	check string
+	steps []string
+}{
+	{types.CheckConsent, []string{types.StepConsent}},
+	{types.CheckResearch, []string{types.StepResearchRequest, types.StepResearch}},
+	{types.CheckEmploymentHistory, []string{types.StepEmploymentHistory, types.StepVerificationRequest, types.StepVerification}},
+	{types.CheckIncome, []string{types.StepIncome}},
+	{types.CheckEducation, []string{types.StepEducation}},
+}
+
+// Validate checks that the package can be run
+func (t *TierPackage) Validate() error {
+	if t.Name == "" || t.Version <= 0 {
+		return fmt.Errorf("tier name and version are required")
+	}
+	known := map[string]bool{}
+	for _, cs := range checkSteps {
+		known[cs.check] = true
+	}
+	for _, check := range t.Checks {
+		if !known[check] {
+			return fmt.Errorf("tier %s v%d: unknown check: %s", t.Name, t.Version, check)
+		}
+	}
+	// Nothing can be checked without the candidate's consent
+	if !t.Has(types.CheckConsent) {
+		return fmt.Errorf("tier %s v%d: the consent check is required", t.Name, t.Version)
+	}
+	if t.ConsentGracePeriod <= 0 ||
+		(t.Has(types.CheckResearch) && t.ResearchGracePeriod <= 0) ||
+		(t.Has(types.CheckEmploymentHistory) && t.VerificationGracePeriod <= 0) {
+		return fmt.Errorf("tier %s v%d: grace periods must be positive", t.Name, t.Version)
+	}
+	return nil
+}
+
+// Has reports whether the package includes a check
+func (t *TierPackage) Has(check string) bool {
+	for _, c := range t.Checks {
+		if c == check {
+			return true
+		}
+	}
+	return false
+}
+
+// Steps returns the steps of the package's checks, in the order they run
+func (t *TierPackage) Steps() []string {
+	steps := []string{}
+	for _, cs := range checkSteps {
+		if t.Has(cs.check) {
+			steps = append(steps, cs.steps...)
+		}
+	}
+	return steps
+}
+
+// Duration returns the longest a screening waits on others in total
+func (t *TierPackage) Duration() time.Duration {
+	d := t.ConsentGracePeriod
+	if t.Has(types.CheckResearch) {
+		d += t.ResearchGracePeriod
+	}
+	if t.Has(types.CheckEmploymentHistory) {
+		d += t.VerificationGracePeriod
+	}
+	return d
+}
@@ -0,0 +1,81 @@
+package workflows
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"encore.app/agent/types"
+)
+
+func TestTierPackageSteps(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []string
+		want   []string
+	}{
+		{"consent only", []string{types.CheckConsent}, []string{types.StepConsent}},
+		{
+			"basic",
+			[]string{types.CheckConsent, types.CheckResearch, types.CheckEmploymentHistory},
+			[]string{types.StepConsent, types.StepResearchRequest, types.StepResearch,
+				types.StepEmploymentHistory, types.StepVerificationRequest, types.StepVerification},
+		},
+		{
+			"premium listed out of order",
+			[]string{types.CheckEducation, types.CheckIncome, types.CheckEmploymentHistory, types.CheckResearch, types.CheckConsent},
+			[]string{types.StepConsent, types.StepResearchRequest, types.StepResearch,
+				types.StepEmploymentHistory, types.StepVerificationRequest, types.StepVerification,
+				types.StepIncome, types.StepEducation},
+		},
+		{"unknown check", []string{types.CheckConsent, "credit"}, []string{types.StepConsent}},
+		{"none", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg := &TierPackage{Name: "test", Version: 1, Checks: tt.checks}
+			if got := pkg.Steps(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("steps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTierPackageValidate(t *testing.T) {
+	valid := func(change func(p *TierPackage)) *TierPackage {
+		p := &TierPackage{
+			Name: "basic", Version: 1,
+			Checks:                  []string{types.CheckConsent, types.CheckResearch, types.CheckEmploymentHistory},
+			ConsentGracePeriod:      72 * time.Hour,
+			ResearchGracePeriod:     72 * time.Hour,
+			VerificationGracePeriod: 72 * time.Hour,
+		}
+		if change != nil {
+			change(p)
+		}
+		return p
+	}
+	tests := []struct {
+		name    string
+		pkg     *TierPackage
+		wantErr bool
+	}{
+		{"valid", valid(nil), false},
+		{"no name", valid(func(p *TierPackage) { p.Name = "" }), true},
+		{"no version", valid(func(p *TierPackage) { p.Version = 0 }), true},
+		{"unknown check", valid(func(p *TierPackage) { p.Checks = append(p.Checks, "credit") }), true},
+		{"no consent", valid(func(p *TierPackage) { p.Checks = p.Checks[1:] }), true},
+		{"no research grace period", valid(func(p *TierPackage) { p.ResearchGracePeriod = 0 }), true},
+		{"research grace period unused", valid(func(p *TierPackage) {
+			p.Checks = []string{types.CheckConsent}
+			p.ResearchGracePeriod, p.VerificationGracePeriod = 0, 0
+		}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.pkg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -6,29 +6,199 @@ import (
 	"strings"
 	"time"
 
+	"encore.app/agent/activities/education"
 	aconsent "encore.app/agent/activities/email/consent"
+	areminder "encore.app/agent/activities/email/reminder"
+	aresearch "encore.app/agent/activities/email/research"
+	averification "encore.app/agent/activities/email/verification"
+	"encore.app/agent/activities/employment/history"
+	"encore.app/agent/activities/employment/income"
+	apii "encore.app/agent/activities/pii"
+	areport "encore.app/agent/activities/report"
+	atoken "encore.app/agent/activities/token"
 	"encore.app/agent/types"
-	"encore.app/agent/utils"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
 // ScreeningWorkflowInput represents the input parameters for the screening workflow
 type ScreeningWorkflowInput struct {
-	JobID            string
-	Email            string
-	Tier             string
-	CurrentEmployer  string // Company requesting the check
-	PreviousEmployer string // Company to verify employment with
+	JobID                 string
+	Email                 string
+	Tier                  string
+	CurrentEmployer       string // Company requesting the check
+	PreviousEmployer      string // Company to verify employment with
+	PreviousEmployerEmail string // Contact who verifies employment at the previous employer
+
+	// Package is the definition of the tier, set by Init from config
+	Package *TierPackage
+	// RequestedBy is the employer user the report is delivered to, set by Init
+	RequestedBy string
+	// Rules decide the finished screening, set by Init from config
+	Rules *AdjudicationRules
 }
 
+// portalDomain is the domain of the pages linked from screening emails
+const portalDomain = "http://localhost:5173"
+
 // ScreeningStatus represents the current status of a screening check
 type ScreeningStatus struct {
 	Status          types.Status
+	Tier            string
+	TierVersion     int
 	CompletedSteps  []string
 	RemainingSteps  []string
+	SkippedSteps    []string // steps made unnecessary by an earlier one
 	ConsentReceived bool
 	CandidateInfo   *types.CandidateDetails
+	Research        *types.ResearchSubmissionSignal     // nil if research was not submitted in time
+	Verification    *types.VerificationSubmissionSignal // nil if a source answered or the employer did not respond in time
+	Income          *income.Result
+	History         *history.Result     // result of the automated sources
+	Education       *education.Result   // nil without a verified research profile listing qualifications
+	Adjudication    *types.Adjudication // nil until the screening finishes
+	Report          *areport.Result     // nil until the employer's report is stored
+	Reminders       []SentReminder
+}
+
+// SentReminder records a reminder email sent during a screening
+type SentReminder struct {
+	Step   string // step the reminder was sent for
+	To     string
+	SentAt time.Time
+}
+
+// NewScreeningStatus returns the status of a screening of a tier that has
+// not started
+func NewScreeningStatus(pkg *TierPackage) *ScreeningStatus {
+	status := &ScreeningStatus{
+		Status:          types.StatusPending,
+		CompletedSteps:  []string{},
+		RemainingSteps:  []string{},
+		ConsentReceived: false,
+	}
+	if pkg != nil {
+		status.Tier = pkg.Name
+		status.TierVersion = pkg.Version
+		status.RemainingSteps = pkg.Steps()
+	}
+	return status
+}
+
+// complete moves a step from the remaining to the completed steps
+func (s *ScreeningStatus) complete(step string) {
+	s.CompletedSteps = append(s.CompletedSteps, step)
+	remaining := []string{}
+	for _, r := range s.RemainingSteps {
+		if r != step {
+			remaining = append(remaining, r)
+		}
+	}
+	s.RemainingSteps = remaining
+}
+
+// skip marks a step as no longer needed
+func (s *ScreeningStatus) skip(step string) {
+	s.complete(step)
+	s.CompletedSteps = s.CompletedSteps[:len(s.CompletedSteps)-1]
+	s.SkippedSteps = append(s.SkippedSteps, step)
+}
+
+// Reminder describes the reminder emails sent while waiting on a submission
+type Reminder struct {
+	Step     string
+	To       string
+	Subject  string
+	Path     string // portal page the reminder links to, if not the root
+	Token    string
+	Schedule []time.Duration
+	Status   *ScreeningStatus // records the reminders sent
+}
+
+// SendReminder sends a reminder email. A reminder that can't be sent is
+// logged rather than failing the screening.
+func SendReminder(ctx workflow.Context, r *Reminder) error {
+	logger := workflow.GetLogger(ctx)
+	data := map[string]interface{}{
+		"Token":  r.Token,
+		"Email":  r.To,
+		"Path":   r.Path,
+		"Domain": portalDomain,
+	}
+
+	logger.Info("Sending reminder", "recipient", r.To, "path", r.Path)
+	reminderActivity := areminder.NewActivity(nil)
+	if err := workflow.ExecuteActivity(ctx, reminderActivity.SendReminder, r.To, types.HiringSupportEmail, r.Subject, data).Get(ctx, nil); err != nil {
+		logger.Error("Failed to send reminder", "recipient", r.To, "error", err)
+		return err
+	}
+
+	if r.Status != nil {
+		r.Status.Reminders = append(r.Status.Reminders, SentReminder{
+			Step:   r.Step,
+			To:     r.To,
+			SentAt: workflow.Now(ctx),
+		})
+	}
+	return nil
+}
+
+// waitForSignal receives a signal into valuePtr, waiting at most the grace
+// period and sending the scheduled reminders until it arrives. It reports
+// whether the signal arrived.
+func waitForSignal(ctx workflow.Context, name string, grace time.Duration, reminder *Reminder, valuePtr interface{}) (bool, error) {
+	ch := workflow.GetSignalChannel(ctx, name)
+	start := workflow.Now(ctx)
+	deadlineAt := start.Add(grace)
+	deadline := workflow.NewTimer(ctx, grace)
+	next := 0 // index of the next scheduled reminder
+
+	for {
+		received, expired, remind := false, false, false
+		var timerErr error
+
+		s := workflow.NewSelector(ctx)
+		s.AddReceive(ch, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, valuePtr)
+			received = true
+		})
+		s.AddFuture(deadline, func(f workflow.Future) {
+			timerErr = f.Get(ctx, nil)
+			expired = true
+		})
+
+		// Skip reminders already due, and only remind while there is time
+		// left to respond
+		cancelReminder := func() {}
+		if reminder != nil {
+			for next < len(reminder.Schedule) && !start.Add(reminder.Schedule[next]).After(workflow.Now(ctx)) {
+				next++
+			}
+			if next < len(reminder.Schedule) && start.Add(reminder.Schedule[next]).Before(deadlineAt) {
+				reminderCtx, cancel := workflow.WithCancel(ctx)
+				cancelReminder = cancel
+				due := start.Add(reminder.Schedule[next]).Sub(workflow.Now(ctx))
+				s.AddFuture(workflow.NewTimer(reminderCtx, due), func(f workflow.Future) {
+					remind = f.Get(ctx, nil) == nil
+				})
+			}
+		}
+
+		s.Select(ctx)
+		cancelReminder()
+
+		switch {
+		case received:
+			return true, nil
+		case timerErr != nil:
+			return false, timerErr
+		case expired:
+			return false, nil
+		case remind:
+			next++
+			SendReminder(ctx, reminder)
+		}
+	}
 }
 
 // DefaultActivityOptions returns the default activity options with retry policy
@@ -58,10 +228,13 @@ func ValidateEmail(email string) error {
 	return nil
 }
 
-// GenerateToken is a wrapper around utils.GenerateWorkflowToken that includes logging
-func GenerateToken(ctx workflow.Context, purpose string) (string, error) {
+// GenerateToken issues a signed token for this workflow, valid for a purpose
+// until the TTL elapses
+func GenerateToken(ctx workflow.Context, purpose string, ttl time.Duration) (string, error) {
 	logger := workflow.GetLogger(ctx)
-	token, err := utils.GenerateWorkflowToken(ctx)
+	tokenActivity := atoken.NewActivity(nil)
+	var token string
+	err := workflow.ExecuteActivity(ctx, tokenActivity.IssueToken, &atoken.Request{Purpose: purpose, TTL: ttl}).Get(ctx, &token)
 	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to generate %s token", purpose), "error", err)
 		return "", fmt.Errorf("failed to generate %s token: %w", purpose, err)
@@ -69,83 +242,115 @@ func GenerateToken(ctx workflow.Context, purpose string) (string, error) {
 	return token, nil
 }
 
-// WaitForResearch waits for research signal with a timeout
-func WaitForResearch(ctx workflow.Context) (*types.ResearchSubmissionSignal, error) {
-	var researchSignal *types.ResearchSubmissionSignal
-	var signalErr error
-
-	s := workflow.NewSelector(ctx)
-	ch := workflow.GetSignalChannel(ctx, types.ResearchSubmissionSignalName)
-
-	s.AddReceive(ch, func(c workflow.ReceiveChannel, more bool) {
-		var signal types.ResearchSubmissionSignal
-		c.Receive(ctx, &signal)
-		researchSignal = &signal
-		workflow.GetLogger(ctx).Info("Received research response",
-			"verified", signal.Verified,
-			"fullName", signal.Profile.FullName,
-			"currentExperience", len(signal.Profile.CurrentExperiences) > 0,
-		)
-	})
-
-	s.AddFuture(workflow.NewTimer(ctx, types.ResearchGracePeriod), func(f workflow.Future) {
-		signalErr = f.Get(ctx, nil)
-		researchSignal = nil
+// WaitForResearch waits for research signal with a timeout, reminding the
+// researcher until it arrives
+func WaitForResearch(ctx workflow.Context, grace time.Duration, reminder *Reminder) (*types.ResearchSubmissionSignal, error) {
+	var signal types.ResearchSubmissionSignal
+	received, err := waitForSignal(ctx, types.ResearchSubmissionSignalName, grace, reminder, &signal)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Error waiting for research", "error", err)
+		return nil, fmt.Errorf("error waiting for research: %w", err)
+	}
+	if !received {
 		workflow.GetLogger(ctx).Info("Research grace period expired")
-	})
-
-	s.Select(ctx)
-	if signalErr != nil {
-		workflow.GetLogger(ctx).Error("Error waiting for research", "error", signalErr)
-		return nil, fmt.Errorf("error waiting for research: %w", signalErr)
+		return nil, nil
 	}
 
-	return researchSignal, nil
+	workflow.GetLogger(ctx).Info("Received research response",
+		"verified", signal.Verified,
+		"fullName", signal.Profile.FullName,
+		"currentExperience", len(signal.Profile.CurrentExperiences) > 0,
+	)
+	return &signal, nil
 }
 
-// WaitForAcceptance waits for acceptance signal with a timeout
-func WaitForAcceptance(ctx workflow.Context) (bool, error) {
-	accepted := false
-	var signalErr error
-
-	s := workflow.NewSelector(ctx)
-	ch := workflow.GetSignalChannel(ctx, types.AcceptSubmissionSignalName)
+// WaitForVerification waits for employment verification signal with a timeout
+func WaitForVerification(ctx workflow.Context, grace time.Duration) (*types.VerificationSubmissionSignal, error) {
+	var signal types.VerificationSubmissionSignal
+	received, err := waitForSignal(ctx, types.VerificationSubmissionSignalName, grace, nil, &signal)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Error waiting for verification", "error", err)
+		return nil, fmt.Errorf("error waiting for verification: %w", err)
+	}
+	if !received {
+		workflow.GetLogger(ctx).Info("Verification grace period expired")
+		return nil, nil
+	}
 
-	s.AddReceive(ch, func(c workflow.ReceiveChannel, more bool) {
-		var signal types.AcceptSubmissionSignal
-		c.Receive(ctx, &signal)
-		accepted = signal.Accepted
-		workflow.GetLogger(ctx).Info("Received consent response",
-			"accepted", accepted,
-			"fullName", signal.CandidateDetails.FullName,
-			"employer", signal.CandidateDetails.CurrentEmployer,
-		)
-	})
+	workflow.GetLogger(ctx).Info("Received verification response",
+		"verified", signal.Verified,
+		"outcome", signal.Outcome,
+		"company", signal.Profile.CompanyName,
+	)
+	return &signal, nil
+}
 
-	s.AddFuture(workflow.NewTimer(ctx, types.AcceptGracePeriod), func(f workflow.Future) {
-		signalErr = f.Get(ctx, nil)
-		accepted = false
+// WaitForAcceptance waits for acceptance signal with a timeout, reminding the
+// candidate until it arrives. It returns nil if the grace period expires.
+func WaitForAcceptance(ctx workflow.Context, grace time.Duration, reminder *Reminder) (*types.AcceptSubmissionSignal, error) {
+	var signal types.AcceptSubmissionSignal
+	received, err := waitForSignal(ctx, types.AcceptSubmissionSignalName, grace, reminder, &signal)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Error waiting for consent", "error", err)
+		return nil, fmt.Errorf("error waiting for consent: %w", err)
+	}
+	if !received {
 		workflow.GetLogger(ctx).Info("Consent grace period expired")
-	})
-
-	s.Select(ctx)
-	if signalErr != nil {
-		workflow.GetLogger(ctx).Error("Error waiting for consent", "error", signalErr)
-		return false, fmt.Errorf("error waiting for consent: %w", signalErr)
+		return nil, nil
 	}
 
-	return accepted, nil
+	workflow.GetLogger(ctx).Info("Received consent response",
+		"accepted", signal.Accepted,
+		"piiRef", signal.CandidateDetails.PIIRef,
+		"employer", signal.CandidateDetails.CurrentEmployer,
+	)
+	return &signal, nil
 }
 
-// Screening is the main workflow function
-func Agent(ctx workflow.Context, input *ScreeningWorkflowInput) (*ScreeningStatus, error) {
+// StoreReport builds and stores the employer's report of a screening that
+// finished, failed or was cancelled
+func StoreReport(ctx workflow.Context, input *ScreeningWorkflowInput, status *ScreeningStatus) error {
 	logger := workflow.GetLogger(ctx)
-	status := &ScreeningStatus{
-		Status:          types.StatusPending,
-		CompletedSteps:  []string{},
-		RemainingSteps:  []string{"consent", "background_check", "research"},
-		ConsentReceived: false,
+	ctx = workflow.WithActivityOptions(ctx, DefaultActivityOptions())
+
+	in := &areport.Input{
+		JobID:            input.JobID,
+		RequestedBy:      input.RequestedBy,
+		Tier:             status.Tier,
+		TierVersion:      status.TierVersion,
+		Status:           status.Status,
+		CandidateEmail:   input.Email,
+		CurrentEmployer:  input.CurrentEmployer,
+		PreviousEmployer: input.PreviousEmployer,
+		ConsentReceived:  status.ConsentReceived,
+		Candidate:        status.CandidateInfo,
+		Research:         status.Research,
+		Verification:     status.Verification,
+		History:          status.History,
+		Income:           status.Income,
+		Education:        status.Education,
+		CompletedSteps:   status.CompletedSteps,
+		SkippedSteps:     status.SkippedSteps,
+		RemainingSteps:   status.RemainingSteps,
+		Adjudication:     status.Adjudication,
+	}
+	reportActivity := areport.NewActivity(nil)
+	var result areport.Result
+	if err := workflow.ExecuteActivity(ctx, reportActivity.StoreReport, in).Get(ctx, &result); err != nil {
+		logger.Error("Failed to store report", "error", err)
+		return fmt.Errorf("failed to store report: %w", err)
 	}
+	status.Report = &result
+	return nil
+}
+
+// Agent is the screening workflow function. Running screenings replay it, so
+// changes to the activities, timers and signals it runs must be gated with
+// workflow.GetVersion, or ship on a new task queue version (see
+// agentTaskQueueVersion in the agent package).
+func Agent(ctx workflow.Context, input *ScreeningWorkflowInput) (*ScreeningStatus, error) {
+	logger := workflow.GetLogger(ctx)
+	status := NewScreeningStatus(input.Package)
 
 	// Register query handler for getting workflow status
 	err := workflow.SetQueryHandler(ctx, types.ScreeningStatusQuery, func() (*ScreeningStatus, error) {
@@ -157,21 +362,145 @@ func Agent(ctx workflow.Context, input *ScreeningWorkflowInput) (*ScreeningStatu
 		return status, fmt.Errorf("failed to register query handler: %w", err)
 	}
 
-	// Validate email before starting workflow
+	// The candidate may withdraw consent at any time, which stops the screening
+	screenCtx, stop := workflow.WithCancel(ctx)
+	var withdrawal *types.WithdrawSubmissionSignal
+	workflow.Go(screenCtx, func(ctx workflow.Context) {
+		s := workflow.NewSelector(ctx)
+		s.AddReceive(workflow.GetSignalChannel(ctx, types.WithdrawSubmissionSignalName), func(c workflow.ReceiveChannel, more bool) {
+			var signal types.WithdrawSubmissionSignal
+			c.Receive(ctx, &signal)
+			withdrawal = &signal
+			stop()
+		})
+		s.AddReceive(ctx.Done(), func(c workflow.ReceiveChannel, more bool) {})
+		s.Select(ctx)
+	})
+
+	err = screen(screenCtx, input, status)
+	stop()
+	if withdrawal == nil {
+		withdrawal = pendingWithdrawal(ctx)
+	}
+	switch {
+	case withdrawal != nil:
+		logger.Info("Candidate withdrew consent", "reason", withdrawal.Reason)
+		purgeCtx, _ := workflow.NewDisconnectedContext(ctx)
+		return status, PurgeCandidateData(purgeCtx, status)
+	case err == nil:
+		status.Adjudication = Adjudicate(input.Rules, input, status, workflow.Now(ctx))
+		logger.Info("Screening adjudicated", "decision", status.Adjudication.Decision, "reasons", len(status.Adjudication.Reasons))
+	case temporal.IsCanceledError(err):
+		logger.Info("Screening cancelled")
+		status.Status = types.StatusCancelled
+	default:
+		status.Status = types.StatusFailed
+	}
+
+	// Employers get a report of cancelled and failed screenings too. A
+	// cancelled workflow's context is done, so it's stored on a disconnected one.
+	reportCtx, _ := workflow.NewDisconnectedContext(ctx)
+	if reportErr := StoreReport(reportCtx, input, status); reportErr != nil && err == nil {
+		return status, reportErr
+	}
+	// A withdrawal while the report was stored purges it too
+	if withdrawal := pendingWithdrawal(ctx); withdrawal != nil {
+		logger.Info("Candidate withdrew consent", "reason", withdrawal.Reason)
+		return status, PurgeCandidateData(reportCtx, status)
+	}
+	return status, err
+}
+
+// pendingWithdrawal returns a withdrawal the candidate submitted after the
+// screening stopped waiting for one, or nil
+func pendingWithdrawal(ctx workflow.Context) *types.WithdrawSubmissionSignal {
+	var signal types.WithdrawSubmissionSignal
+	if workflow.GetSignalChannel(ctx, types.WithdrawSubmissionSignalName).ReceiveAsync(&signal) {
+		return &signal
+	}
+	return nil
+}
+
+// PurgeCandidateData discards the personal data collected about the
+// candidate, from the vault, the screening's report, the workflow's status
+// and its memo, and marks the screening withdrawn
+func PurgeCandidateData(ctx workflow.Context, status *ScreeningStatus) error {
+	logger := workflow.GetLogger(ctx)
+	ctx = workflow.WithActivityOptions(ctx, DefaultActivityOptions())
+
+	piiActivity := apii.NewActivity(nil)
+	var purged int
+	if err := workflow.ExecuteActivity(ctx, piiActivity.PurgePII, "consent withdrawn").Get(ctx, &purged); err != nil {
+		logger.Error("Failed to purge personal details", "error", err)
+		return fmt.Errorf("failed to purge personal details: %w", err)
+	}
+	logger.Info("Purged personal details", "records", purged)
+
+	if status.Report != nil {
+		reportActivity := areport.NewActivity(nil)
+		var deleted int
+		if err := workflow.ExecuteActivity(ctx, reportActivity.DeleteReport).Get(ctx, &deleted); err != nil {
+			logger.Error("Failed to delete report", "error", err)
+			return fmt.Errorf("failed to delete report: %w", err)
+		}
+		logger.Info("Deleted report", "reports", deleted)
+		status.Report = nil
+	}
+
+	status.Status = types.StatusWithdrawn
+	status.CandidateInfo = nil
+	status.Research = nil
+	status.Verification = nil
+	status.Income = nil
+	status.History = nil
+	status.Education = nil
+	for i := range status.Reminders {
+		status.Reminders[i].To = ""
+	}
+
+	err := workflow.UpsertMemo(ctx, map[string]interface{}{
+		"CandidateEmail": "",
+		"CandidateName":  "",
+		"EmployerName":   "",
+	})
+	if err != nil {
+		logger.Error("Failed to purge workflow memo", "error", err)
+		return fmt.Errorf("failed to purge workflow memo: %w", err)
+	}
+	return nil
+}
+
+// screen runs the steps of a screening, recording their progress in status
+func screen(ctx workflow.Context, input *ScreeningWorkflowInput, status *ScreeningStatus) error {
+	logger := workflow.GetLogger(ctx)
+
+	if input.Package == nil {
+		return fmt.Errorf("tier package is required")
+	}
+	if err := input.Package.Validate(); err != nil {
+		logger.Error("Invalid tier package", "error", err)
+		return err
+	}
+	pkg := input.Package
+
+	// Validate emails before starting workflow
 	if err := ValidateEmail(input.Email); err != nil {
 		logger.Error("Invalid email address", "error", err)
-		status.Status = types.StatusFailed
-		return status, err
+		return err
+	}
+	if err := ValidateEmail(input.PreviousEmployerEmail); err != nil {
+		logger.Error("Invalid previous employer email address", "error", err)
+		return err
 	}
 
 	// Set activity options
 	ctx = workflow.WithActivityOptions(ctx, DefaultActivityOptions())
 
-	// Generate unique token for consent email
-	consentToken, err := GenerateToken(ctx, "consent")
+	// Generate unique token for consent email. The candidate also uses it to
+	// withdraw, so it lasts for the whole screening.
+	consentToken, err := GenerateToken(ctx, types.TokenPurposeConsent, pkg.Duration())
 	if err != nil {
-		status.Status = types.StatusFailed
-		return status, err
+		return err
 	}
 
 	logger.Info("Sending screening consent email", "recipient", input.Email)
@@ -183,7 +512,7 @@ func Agent(ctx workflow.Context, input *ScreeningWorkflowInput) (*ScreeningStatu
 			"Token":    consentToken,
 			"Email":    input.Email,
 			"Employer": input.CurrentEmployer,
-			"Domain":   "http://localhost:5173",
+			"Domain":   portalDomain,
 			"Name":     input.Email, // Default to email until we get candidate details
 		},
 	}
@@ -191,52 +520,242 @@ func Agent(ctx workflow.Context, input *ScreeningWorkflowInput) (*ScreeningStatu
 	consentActivity := aconsent.NewActivity(nil)
 	if err := workflow.ExecuteActivity(ctx, consentActivity.SendConsentEmail, req.To, req.From, req.Subject, req.TemplateData).Get(ctx, nil); err != nil {
 		logger.Error("Failed to send consent email", "error", err)
-		status.Status = types.StatusFailed
-		return status, fmt.Errorf("failed to send consent email: %w", err)
+		return fmt.Errorf("failed to send consent email: %w", err)
 	}
 
 	// Wait for consent response
-	var candidateInfo *types.CandidateDetails
-	s := workflow.NewSelector(ctx)
-	ch := workflow.GetSignalChannel(ctx, types.AcceptSubmissionSignalName)
-	accepted := false
-	var signalErr error
-
-	s.AddReceive(ch, func(c workflow.ReceiveChannel, more bool) {
-		var signal types.AcceptSubmissionSignal
-		c.Receive(ctx, &signal)
-		accepted = signal.Accepted
-		candidateInfo = &signal.CandidateDetails
-		workflow.GetLogger(ctx).Info("Received consent response",
-			"accepted", accepted,
-			"fullName", signal.CandidateDetails.FullName,
-			"employer", signal.CandidateDetails.CurrentEmployer,
-		)
-	})
-
-	s.AddFuture(workflow.NewTimer(ctx, types.AcceptGracePeriod), func(f workflow.Future) {
-		signalErr = f.Get(ctx, nil)
-		accepted = false
-		workflow.GetLogger(ctx).Info("Consent grace period expired")
+	signal, err := WaitForAcceptance(ctx, pkg.ConsentGracePeriod, &Reminder{
+		Step:     types.StepConsent,
+		To:       input.Email,
+		Subject:  "Reminder: Screening Consent Required",
+		Token:    consentToken,
+		Schedule: pkg.Reminders.Consent,
+		Status:   status,
 	})
-
-	s.Select(ctx)
-	if signalErr != nil {
-		workflow.GetLogger(ctx).Error("Error waiting for consent", "error", signalErr)
-		status.Status = types.StatusFailed
-		return status, fmt.Errorf("error waiting for consent: %w", signalErr)
+	if err != nil {
+		return err
 	}
 
+	accepted := signal != nil && signal.Accepted
+	var candidateInfo *types.CandidateDetails
+	if signal != nil {
+		candidateInfo = &signal.CandidateDetails
+	}
 	status.ConsentReceived = accepted
 	status.CandidateInfo = candidateInfo
 	if !accepted {
 		logger.Info("Consent not received or declined")
 		status.Status = types.StatusDeclined
-		return status, nil
+		return nil
 	}
 
-	// TODO: Implement remaining screening workflow steps
+	status.Status = types.StatusAccepted
+	status.complete(types.StepConsent)
+
+	// Let the research and verification pages show who the screening is for
+	err = workflow.UpsertMemo(ctx, map[string]interface{}{
+		"CandidateEmail": input.Email,
+		"CandidateName":  candidateInfo.FullName,
+		"EmployerName":   input.PreviousEmployer,
+	})
+	if err != nil {
+		logger.Error("Failed to update workflow memo", "error", err)
+		return fmt.Errorf("failed to update workflow memo: %w", err)
+	}
+
+	if pkg.Has(types.CheckResearch) {
+		if err := research(ctx, input, status); err != nil {
+			return err
+		}
+	}
+	if pkg.Has(types.CheckEmploymentHistory) {
+		if err := checkEmploymentHistory(ctx, input, status); err != nil {
+			return err
+		}
+		// Ask the employer by email when no automated source answers
+		if status.History.Source == "" {
+			if err := verification(ctx, input, status); err != nil {
+				return err
+			}
+		} else {
+			status.skip(types.StepVerificationRequest)
+			status.skip(types.StepVerification)
+		}
+	}
+	if pkg.Has(types.CheckIncome) {
+		if err := checkIncome(ctx, input, status); err != nil {
+			return err
+		}
+	}
+	if pkg.Has(types.CheckEducation) {
+		if err := checkEducation(ctx, status); err != nil {
+			return err
+		}
+	}
 
+	status.Status = types.StatusCompleted
 	logger.Info("Screening workflow completed successfully")
-	return status, nil
+	return nil
+}
+
+// research asks a researcher to research the candidate's professional
+// profile, and waits for their submission
+func research(ctx workflow.Context, input *ScreeningWorkflowInput, status *ScreeningStatus) error {
+	logger := workflow.GetLogger(ctx)
+
+	researchToken, err := GenerateToken(ctx, types.TokenPurposeResearch, input.Package.ResearchGracePeriod)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Sending research request", "candidate", input.Email)
+	data := map[string]interface{}{
+		"Token":    researchToken,
+		"Email":    input.Email,
+		"Name":     status.CandidateInfo.FullName,
+		"Employer": input.PreviousEmployer,
+		"Domain":   portalDomain,
+	}
+	researchActivity := aresearch.NewActivity(nil)
+	if err := workflow.ExecuteActivity(ctx, researchActivity.SendResearchRequest, types.ResearcherSupportEmail, types.HiringSupportEmail, "Employment Research Requested", data).Get(ctx, nil); err != nil {
+		logger.Error("Failed to send research request", "error", err)
+		return fmt.Errorf("failed to send research request: %w", err)
+	}
+	status.complete(types.StepResearchRequest)
+
+	signal, err := WaitForResearch(ctx, input.Package.ResearchGracePeriod, &Reminder{
+		Step:     types.StepResearch,
+		To:       types.ResearcherSupportEmail,
+		Subject:  "Reminder: Employment Research Pending",
+		Path:     "research",
+		Token:    researchToken,
+		Schedule: input.Package.Reminders.Research,
+		Status:   status,
+	})
+	if err != nil {
+		return err
+	}
+	// Research not submitted in time stays remaining, so the screening
+	// shows it was never done
+	status.Research = signal
+	if signal != nil {
+		status.complete(types.StepResearch)
+	}
+	return nil
+}
+
+// verification asks the previous employer to verify the candidate's
+// employment, and waits for their response
+func verification(ctx workflow.Context, input *ScreeningWorkflowInput, status *ScreeningStatus) error {
+	logger := workflow.GetLogger(ctx)
+
+	verificationToken, err := GenerateToken(ctx, types.TokenPurposeVerification, input.Package.VerificationGracePeriod)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Sending verification request", "recipient", input.PreviousEmployerEmail)
+	data := map[string]interface{}{
+		"Token":    verificationToken,
+		"Email":    input.PreviousEmployerEmail,
+		"Name":     status.CandidateInfo.FullName,
+		"Employer": input.PreviousEmployer,
+		"Domain":   portalDomain,
+	}
+	verificationActivity := averification.NewActivity(nil)
+	if err := workflow.ExecuteActivity(ctx, verificationActivity.SendVerificationRequest, input.PreviousEmployerEmail, types.HiringSupportEmail, "Employment Verification Request", data).Get(ctx, nil); err != nil {
+		logger.Error("Failed to send verification request", "error", err)
+		return fmt.Errorf("failed to send verification request: %w", err)
+	}
+	status.complete(types.StepVerificationRequest)
+
+	signal, err := WaitForVerification(ctx, input.Package.VerificationGracePeriod)
+	if err != nil {
+		return err
+	}
+	status.Verification = signal
+	if signal != nil {
+		status.complete(types.StepVerification)
+	}
+	return nil
+}
+
+// checkIncome checks the candidate's income with their previous employer,
+// through the automated sources or the employer's emailed verification
+func checkIncome(ctx workflow.Context, input *ScreeningWorkflowInput, status *ScreeningStatus) error {
+	logger := workflow.GetLogger(ctx)
+
+	logger.Info("Checking income", "employer", input.PreviousEmployer)
+	incomeActivity := income.NewActivity(types.Config{})
+	incomeReq := &income.Request{
+		EmployerName:    input.PreviousEmployer,
+		EmployerContact: input.PreviousEmployerEmail,
+		CandidateName:   status.CandidateInfo.FullName,
+		PIIRef:          status.CandidateInfo.PIIRef,
+		Period:          "annual",
+		Year:            workflow.Now(ctx).Year(),
+		Verification:    status.Verification,
+	}
+	var incomeResult income.Result
+	if err := workflow.ExecuteActivity(ctx, incomeActivity.GetIncomeInformation, incomeReq).Get(ctx, &incomeResult); err != nil {
+		logger.Error("Failed to check income", "error", err)
+		return fmt.Errorf("failed to check income: %w", err)
+	}
+	status.Income = &incomeResult
+	status.complete(types.StepIncome)
+	return nil
+}
+
+// checkEmploymentHistory checks the candidate's employment at their previous
+// employer with the automated sources, comparing it with the researched
+// profile if there is one
+func checkEmploymentHistory(ctx workflow.Context, input *ScreeningWorkflowInput, status *ScreeningStatus) error {
+	logger := workflow.GetLogger(ctx)
+
+	logger.Info("Checking employment history", "employer", input.PreviousEmployer)
+	historyActivity := history.NewActivity(types.Config{})
+	historyReq := &history.Request{
+		EmployerName:    input.PreviousEmployer,
+		EmployerContact: input.PreviousEmployerEmail,
+		CandidateName:   status.CandidateInfo.FullName,
+		PIIRef:          status.CandidateInfo.PIIRef,
+	}
+	if status.Research != nil && status.Research.Verified {
+		historyReq.Profile = status.Research.Profile
+	}
+	var historyResult history.Result
+	if err := workflow.ExecuteActivity(ctx, historyActivity.CheckEmploymentHistory, historyReq).Get(ctx, &historyResult); err != nil {
+		logger.Error("Failed to check employment history", "error", err)
+		return fmt.Errorf("failed to check employment history: %w", err)
+	}
+	status.History = &historyResult
+	status.complete(types.StepEmploymentHistory)
+	return nil
+}
+
+// checkEducation verifies the qualifications in the candidate's researched
+// profile
+func checkEducation(ctx workflow.Context, status *ScreeningStatus) error {
+	logger := workflow.GetLogger(ctx)
+
+	if status.Research == nil || !status.Research.Verified || len(status.Research.Profile.Education) == 0 {
+		logger.Info("Skipping education check without researched qualifications")
+		status.skip(types.StepEducation)
+		return nil
+	}
+
+	logger.Info("Checking education", "qualifications", len(status.Research.Profile.Education))
+	educationActivity := education.NewActivity(types.Config{})
+	educationReq := &education.Request{
+		CandidateName: status.CandidateInfo.FullName,
+		Education:     status.Research.Profile.Education,
+	}
+	var educationResult education.Result
+	if err := workflow.ExecuteActivity(ctx, educationActivity.VerifyEducation, educationReq).Get(ctx, &educationResult); err != nil {
+		logger.Error("Failed to check education", "error", err)
+		return fmt.Errorf("failed to check education: %w", err)
+	}
+	status.Education = &educationResult
+	status.complete(types.StepEducation)
+	return nil
 }
@@ -0,0 +1,320 @@
+package workflows
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+
+	aconsent "encore.app/agent/activities/email/consent"
+	areminder "encore.app/agent/activities/email/reminder"
+	aresearch "encore.app/agent/activities/email/research"
+	averification "encore.app/agent/activities/email/verification"
+	"encore.app/agent/activities/employment/history"
+	apii "encore.app/agent/activities/pii"
+	areport "encore.app/agent/activities/report"
+	atoken "encore.app/agent/activities/token"
+	"encore.app/agent/types"
+)
+
+var testStart = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// waitResult is what waitWorkflow saw while waiting
+type waitResult struct {
+	Received  bool
+	Reminders []SentReminder
+}
+
+// waitWorkflow waits for the "test" signal, reminding on the schedule
+func waitWorkflow(ctx workflow.Context, grace time.Duration, schedule []time.Duration) (*waitResult, error) {
+	ctx = workflow.WithActivityOptions(ctx, DefaultActivityOptions())
+	status := &ScreeningStatus{}
+	var value string
+	received, err := waitForSignal(ctx, "test", grace, &Reminder{
+		Step:     types.StepConsent,
+		To:       "jane@example.com",
+		Schedule: schedule,
+		Status:   status,
+	}, &value)
+	return &waitResult{Received: received, Reminders: status.Reminders}, err
+}
+
+func TestWaitForSignal(t *testing.T) {
+	schedule := []time.Duration{24 * time.Hour, 48 * time.Hour, 70 * time.Hour}
+	tests := []struct {
+		name          string
+		grace         time.Duration
+		schedule      []time.Duration
+		signalAt      time.Duration // zero for no signal
+		wantReceived  bool
+		wantReminders []time.Duration // when reminders were sent
+	}{
+		{"no signal", 72 * time.Hour, schedule, 0, false, schedule},
+		{"signal stops reminders", 72 * time.Hour, schedule, 30 * time.Hour, true, []time.Duration{24 * time.Hour}},
+		{"signal before the first reminder", 72 * time.Hour, schedule, time.Hour, true, nil},
+		{"no reminders past the deadline", 48 * time.Hour, schedule, 0, false, []time.Duration{24 * time.Hour}},
+		{"reminders already due skipped", 72 * time.Hour, []time.Duration{0, 24 * time.Hour, 24 * time.Hour}, 0, false, []time.Duration{24 * time.Hour}},
+		{"no schedule", 72 * time.Hour, nil, 0, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestWorkflowEnvironment()
+			env.SetStartTime(testStart)
+			env.RegisterWorkflow(waitWorkflow)
+			reminder := areminder.NewActivity(nil)
+			env.OnActivity(reminder.SendReminder, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+				Return(&types.Result{}, nil)
+			if tt.signalAt > 0 {
+				env.RegisterDelayedCallback(func() {
+					env.SignalWorkflow("test", "done")
+				}, tt.signalAt)
+			}
+
+			env.ExecuteWorkflow(waitWorkflow, tt.grace, tt.schedule)
+			if err := env.GetWorkflowError(); err != nil {
+				t.Fatal(err)
+			}
+			var got waitResult
+			if err := env.GetWorkflowResult(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Received != tt.wantReceived {
+				t.Errorf("received = %v, want %v", got.Received, tt.wantReceived)
+			}
+			var sent []time.Duration
+			for _, r := range got.Reminders {
+				sent = append(sent, r.SentAt.Sub(testStart))
+				if r.Step != types.StepConsent || r.To != "jane@example.com" {
+					t.Errorf("reminder = %+v, want the consent reminder to the candidate", r)
+				}
+			}
+			if !reflect.DeepEqual(sent, tt.wantReminders) {
+				t.Errorf("reminders sent at %v, want %v", sent, tt.wantReminders)
+			}
+			env.AssertNumberOfCalls(t, "SendReminder", len(tt.wantReminders))
+		})
+	}
+}
+
+// testSignal is a signal sent to a workflow at a time from its start
+type testSignal struct {
+	at    time.Duration
+	name  string
+	value interface{}
+}
+
+func TestAgent(t *testing.T) {
+	accept := func(at time.Duration) testSignal {
+		return testSignal{at, types.AcceptSubmissionSignalName, types.AcceptSubmissionSignal{
+			Accepted:         true,
+			CandidateDetails: types.CandidateDetails{FullName: "Jane Doe", PIIRef: "ref"},
+		}}
+	}
+	research := func(at time.Duration) testSignal {
+		return testSignal{at, types.ResearchSubmissionSignalName, types.ResearchSubmissionSignal{Verified: true}}
+	}
+	verify := func(at time.Duration) testSignal {
+		return testSignal{at, types.VerificationSubmissionSignalName, types.VerificationSubmissionSignal{Verified: true}}
+	}
+	withdraw := func(at time.Duration) testSignal {
+		return testSignal{at, types.WithdrawSubmissionSignalName, types.WithdrawSubmissionSignal{Reason: "changed my mind"}}
+	}
+	allSteps := []string{types.StepConsent, types.StepResearchRequest, types.StepResearch,
+		types.StepEmploymentHistory, types.StepVerificationRequest, types.StepVerification}
+
+	tests := []struct {
+		name                 string
+		signals              []testSignal
+		historySource        string // source answering the employment history check
+		withdrawDuringReport bool
+
+		wantStatus    types.Status
+		wantCompleted []string
+		wantSkipped   []string
+		wantRemaining []string
+		wantReminders []string // steps reminded about, in order
+		wantReports   int
+		wantPurges    int
+		wantDeletes   int
+	}{
+		{
+			name:          "employer verifies by email",
+			signals:       []testSignal{accept(time.Hour), research(2 * time.Hour), verify(3 * time.Hour)},
+			wantStatus:    types.StatusCompleted,
+			wantCompleted: allSteps,
+			wantRemaining: []string{},
+			wantReports:   1,
+		},
+		{
+			name:          "source answers",
+			signals:       []testSignal{accept(time.Hour), research(2 * time.Hour)},
+			historySource: "fake",
+			wantStatus:    types.StatusCompleted,
+			wantCompleted: []string{types.StepConsent, types.StepResearchRequest, types.StepResearch, types.StepEmploymentHistory},
+			wantSkipped:   []string{types.StepVerificationRequest, types.StepVerification},
+			wantRemaining: []string{},
+			wantReports:   1,
+		},
+		{
+			name:          "reminded before each submission",
+			signals:       []testSignal{accept(30 * time.Hour), research(56 * time.Hour), verify(60 * time.Hour)},
+			wantStatus:    types.StatusCompleted,
+			wantCompleted: allSteps,
+			wantRemaining: []string{},
+			wantReminders: []string{types.StepConsent, types.StepResearch},
+			wantReports:   1,
+		},
+		{
+			name:    "research not submitted",
+			signals: []testSignal{accept(time.Hour), verify(80 * time.Hour)},
+			// The research grace period ends at 73h
+			wantStatus: types.StatusCompleted,
+			wantCompleted: []string{types.StepConsent, types.StepResearchRequest,
+				types.StepEmploymentHistory, types.StepVerificationRequest, types.StepVerification},
+			wantRemaining: []string{types.StepResearch},
+			wantReminders: []string{types.StepResearch, types.StepResearch},
+			wantReports:   1,
+		},
+		{
+			name: "consent declined",
+			signals: []testSignal{{time.Hour, types.AcceptSubmissionSignalName, types.AcceptSubmissionSignal{
+				CandidateDetails: types.CandidateDetails{FullName: "Jane Doe"},
+			}}},
+			wantStatus:    types.StatusDeclined,
+			wantCompleted: []string{},
+			wantRemaining: allSteps,
+			wantReports:   1,
+		},
+		{
+			name:          "consent not given in time",
+			wantStatus:    types.StatusDeclined,
+			wantCompleted: []string{},
+			wantRemaining: allSteps,
+			wantReminders: []string{types.StepConsent, types.StepConsent},
+			wantReports:   1,
+		},
+		{
+			name:          "withdrawn during the screening",
+			signals:       []testSignal{accept(time.Hour), withdraw(30 * time.Hour)},
+			wantStatus:    types.StatusWithdrawn,
+			wantCompleted: []string{types.StepConsent, types.StepResearchRequest},
+			wantRemaining: []string{types.StepResearch, types.StepEmploymentHistory, types.StepVerificationRequest, types.StepVerification},
+			wantReminders: []string{types.StepResearch},
+			wantPurges:    1,
+		},
+		{
+			name:                 "withdrawn while the report is stored",
+			signals:              []testSignal{accept(time.Hour), research(2 * time.Hour)},
+			historySource:        "fake",
+			withdrawDuringReport: true,
+			wantStatus:           types.StatusWithdrawn,
+			wantCompleted:        []string{types.StepConsent, types.StepResearchRequest, types.StepResearch, types.StepEmploymentHistory},
+			wantSkipped:          []string{types.StepVerificationRequest, types.StepVerification},
+			wantRemaining:        []string{},
+			wantReports:          1,
+			wantPurges:           1,
+			wantDeletes:          1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestWorkflowEnvironment()
+			env.SetStartTime(testStart)
+			env.RegisterWorkflow(Agent)
+
+			email := []interface{}{mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything}
+			env.OnActivity(atoken.NewActivity(nil).IssueToken, mock.Anything, mock.Anything).Return("token", nil)
+			env.OnActivity(aconsent.NewActivity(nil).SendConsentEmail, email...).Return(&aconsent.SendEmailConsentResponse{}, nil)
+			env.OnActivity(areminder.NewActivity(nil).SendReminder, email...).Return(&types.Result{}, nil)
+			env.OnActivity(aresearch.NewActivity(nil).SendResearchRequest, email...).Return(&types.Result{}, nil)
+			env.OnActivity(averification.NewActivity(nil).SendVerificationRequest, email...).Return(&types.Result{}, nil)
+			env.OnActivity(history.NewActivity(types.Config{}).CheckEmploymentHistory, mock.Anything, mock.Anything).
+				Return(&history.Result{Source: tt.historySource}, nil)
+			reports := areport.NewActivity(nil)
+			env.OnActivity(reports.StoreReport, mock.Anything, mock.Anything).
+				Return(func(ctx context.Context, in *areport.Input) (*areport.Result, error) {
+					if tt.withdrawDuringReport {
+						env.SignalWorkflow(types.WithdrawSubmissionSignalName, types.WithdrawSubmissionSignal{Reason: "changed my mind"})
+					}
+					return &areport.Result{SchemaVersion: 1}, nil
+				})
+			env.OnActivity(reports.DeleteReport, mock.Anything).Return(1, nil)
+			env.OnActivity(apii.NewActivity(nil).PurgePII, mock.Anything, mock.Anything).Return(1, nil)
+
+			for _, s := range tt.signals {
+				s := s
+				env.RegisterDelayedCallback(func() {
+					env.SignalWorkflow(s.name, s.value)
+				}, s.at)
+			}
+
+			env.ExecuteWorkflow(Agent, &ScreeningWorkflowInput{
+				JobID:                 "job",
+				Email:                 "jane@example.com",
+				Tier:                  "basic",
+				CurrentEmployer:       "Globex",
+				PreviousEmployer:      "Acme",
+				PreviousEmployerEmail: "hr@acme.example",
+				Package: &TierPackage{
+					Name: "basic", Version: 1,
+					Checks:                  []string{types.CheckConsent, types.CheckResearch, types.CheckEmploymentHistory},
+					ConsentGracePeriod:      72 * time.Hour,
+					ResearchGracePeriod:     72 * time.Hour,
+					VerificationGracePeriod: 72 * time.Hour,
+					Reminders: ReminderSchedule{
+						Consent:  []time.Duration{24 * time.Hour, 48 * time.Hour},
+						Research: []time.Duration{24 * time.Hour, 48 * time.Hour},
+					},
+				},
+				RequestedBy: "employer",
+			})
+			if err := env.GetWorkflowError(); err != nil {
+				t.Fatal(err)
+			}
+			var got ScreeningStatus
+			if err := env.GetWorkflowResult(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", got.Status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(got.CompletedSteps, tt.wantCompleted) {
+				t.Errorf("completed steps = %v, want %v", got.CompletedSteps, tt.wantCompleted)
+			}
+			if !reflect.DeepEqual(got.SkippedSteps, tt.wantSkipped) {
+				t.Errorf("skipped steps = %v, want %v", got.SkippedSteps, tt.wantSkipped)
+			}
+			if !reflect.DeepEqual(got.RemainingSteps, tt.wantRemaining) {
+				t.Errorf("remaining steps = %v, want %v", got.RemainingSteps, tt.wantRemaining)
+			}
+			var reminded []string
+			for _, r := range got.Reminders {
+				reminded = append(reminded, r.Step)
+			}
+			if !reflect.DeepEqual(reminded, tt.wantReminders) {
+				t.Errorf("reminded about %v, want %v", reminded, tt.wantReminders)
+			}
+			env.AssertNumberOfCalls(t, "StoreReport", tt.wantReports)
+			env.AssertNumberOfCalls(t, "PurgePII", tt.wantPurges)
+			env.AssertNumberOfCalls(t, "DeleteReport", tt.wantDeletes)
+
+			if tt.wantStatus == types.StatusWithdrawn {
+				if got.CandidateInfo != nil || got.Research != nil || got.History != nil || got.Report != nil {
+					t.Errorf("withdrawn screening kept candidate data: %+v", got)
+				}
+				for _, r := range got.Reminders {
+					if r.To != "" {
+						t.Errorf("withdrawn screening kept reminder recipient %q", r.To)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package carriers
+
+import "fmt"
+
+// Join subscribes a client to the events of a channel
+func (n *Node) Join(clientID, channelID string) error {
+	if _, err := n.getClient(clientID); err != nil {
+		return err
+	}
+	return n.publish(&Frame{Kind: FrameJoin, ClientID: clientID, Channel: channelID})
+}
+
+// Leave unsubscribes a client from the events of a channel
+func (n *Node) Leave(clientID, channelID string) error {
+	if _, err := n.getClient(clientID); err != nil {
+		return err
+	}
+	return n.publish(&Frame{Kind: FrameLeave, ClientID: clientID, Channel: channelID})
+}
+
+// Ack records the last sequence number a client has processed on a channel.
+// When the client reopens its socket without giving last_seq for the
+// channel, it resumes after the acknowledged event.
+func (n *Node) Ack(clientID, channelID string, seq int64) error {
+	if _, err := n.getClient(clientID); err != nil {
+		return err
+	}
+	return n.publish(&Frame{Kind: FrameAck, ClientID: clientID, Channel: channelID, Seq: seq})
+}
+
+// Channels returns the channels a client has joined
+func (n *Node) Channels(clientID string) ([]string, error) {
+	client, err := n.getClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	channels := make([]string, 0, len(client.channels))
+	for id := range client.channels {
+		channels = append(channels, id)
+	}
+	return channels, nil
+}
+
+// getClient looks up a registered client
+func (n *Node) getClient(clientID string) (*wsClient, error) {
+	n.clientsMu.RLock()
+	defer n.clientsMu.RUnlock()
+
+	client, ok := n.clients[clientID]
+	if !ok {
+		return nil, fmt.Errorf("client not found: %s", clientID)
+	}
+	return client, nil
+}
+
+// joined reports whether the client has joined a channel
+func (c *wsClient) joined(channelID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.channels[channelID]
+}
+
+// ack records an acknowledged sequence number, which never moves back
+func (c *wsClient) ack(channelID string, seq int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if seq > c.acked[channelID] {
+		c.acked[channelID] = seq
+	}
+}
+
+// setJoined adds the client to or removes it from a channel
+func (c *wsClient) setJoined(channelID string, joined bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if joined {
+		c.channels[channelID] = true
+	} else {
+		delete(c.channels, channelID)
+	}
+}
@@ -0,0 +1,127 @@
+package carriers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Cluster buses, selecting how the service's instances are connected
+const (
+	ClusterPostgres = "postgres" // LISTEN/NOTIFY on the carriers database
+	ClusterMemory   = "memory"   // in-process only, for a single instance
+)
+
+// Frame kinds
+const (
+	FrameRegister = "register" // a client was registered
+	FrameJoin     = "join"     // a client joined a channel
+	FrameLeave    = "leave"    // a client left a channel
+	FrameAck      = "ack"      // a client acknowledged events of a channel
+	FrameDeliver  = "deliver"  // a message for one client, a channel or everyone
+	FrameAttach   = "attach"   // a client opened its socket on the publishing node
+	FrameDetach   = "detach"   // a client's socket on the publishing node closed
+	FrameHandoff  = "handoff"  // unread messages handed to the node now holding the socket
+)
+
+// Frame is a change to the cluster's clients, published by one node to the others
+type Frame struct {
+	Kind        string         `json:"kind"`
+	Node        string         `json:"node"` // publishing node
+	ClientID    string         `json:"client_id,omitempty"`
+	Channel     string         `json:"channel,omitempty"`
+	Seq         int64          `json:"seq,omitempty"`
+	Data        []byte         `json:"data,omitempty"`
+	ResumeToken string         `json:"resume_token,omitempty"` // register
+	Handler     *HandlerState  `json:"handler,omitempty"`      // register
+	Target      string         `json:"target,omitempty"`       // handoff: receiving node
+	Messages    []FrameMessage `json:"messages,omitempty"`     // handoff
+}
+
+// FrameMessage is an undelivered message handed from one node to another
+type FrameMessage struct {
+	Data    []byte `json:"data"`
+	Channel string `json:"channel,omitempty"`
+	Seq     int64  `json:"seq,omitempty"`
+}
+
+// Bus carries frames between the nodes of a cluster
+type Bus interface {
+	// Publish sends a frame to every node on the bus except its publisher
+	Publish(ctx context.Context, f *Frame) error
+	// Subscribe connects a node to the bus
+	Subscribe(n *Node)
+}
+
+// MemoryBus connects nodes in the same process. With a single node it
+// is the bus of a standalone instance; with several it lets a cluster be
+// exercised in-process.
+type MemoryBus struct {
+	mu    sync.RWMutex
+	nodes []*Node
+}
+
+// NewMemoryBus creates an in-process bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{}
+}
+
+// Subscribe connects a node to the bus
+func (b *MemoryBus) Subscribe(n *Node) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nodes = append(b.nodes, n)
+}
+
+// Publish applies a frame on every other node before returning
+func (b *MemoryBus) Publish(ctx context.Context, f *Frame) error {
+	b.mu.RLock()
+	nodes := b.nodes
+	b.mu.RUnlock()
+
+	for _, n := range nodes {
+		n.receive(f)
+	}
+	return nil
+}
+
+// PortableHandler is a WebSocketHandler that can be recreated on other nodes,
+// letting its client open its socket on any instance
+type PortableHandler interface {
+	WebSocketHandler
+	Kind() string           // name the handler's restore function is registered under
+	State() ([]byte, error) // state the handler is restored from
+}
+
+// HandlerState is the saved state of a PortableHandler
+type HandlerState struct {
+	Kind  string `json:"kind"`
+	State []byte `json:"state"`
+}
+
+// RestoreFunc recreates a handler for a client from its saved state
+type RestoreFunc func(clientID string, state []byte) (WebSocketHandler, error)
+
+var (
+	restorers   = make(map[string]RestoreFunc)
+	restorersMu sync.RWMutex
+)
+
+// RegisterHandler registers the function restoring PortableHandlers of a kind.
+// It must be called on every instance, typically from an init function.
+func RegisterHandler(kind string, restore RestoreFunc) {
+	restorersMu.Lock()
+	defer restorersMu.Unlock()
+	restorers[kind] = restore
+}
+
+// restoreHandler recreates a client's handler from its saved state
+func restoreHandler(clientID string, s *HandlerState) (WebSocketHandler, error) {
+	restorersMu.RLock()
+	restore, ok := restorers[s.Kind]
+	restorersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no handler registered for kind: %s", s.Kind)
+	}
+	return restore(clientID, s.State)
+}
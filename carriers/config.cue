@@ -0,0 +1,12 @@
+package carriers
+
+PingInterval: int | *30
+PongTimeout: int | *75 // must exceed PingInterval
+WriteTimeout: int | *10
+ConnectTimeout: int | *60
+ResumeWindow: int | *120
+HistorySize: int | *256
+MaxMessageSize: int | *65536 // 64 KiB
+SendBuffer: int | *256
+SlowConsumer: "disconnect" | "drop-oldest" | *"coalesce"
+Cluster: *"postgres" | "memory"
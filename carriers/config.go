@@ -0,0 +1,34 @@
+package carriers
+
+import (
+	"time"
+
+	"encore.dev/config"
+)
+
+// Slow-consumer policies, applied when a client's send buffer is full
+const (
+	PolicyDisconnect = "disconnect"  // close the connection
+	PolicyDropOldest = "drop-oldest" // drop the oldest queued message
+	PolicyCoalesce   = "coalesce"    // drop queued typing events, then the oldest message
+)
+
+type Config struct {
+	PingInterval   int    // seconds between keepalive pings
+	PongTimeout    int    // seconds without any frame from the peer before it is considered dead
+	WriteTimeout   int    // seconds allowed for writing a single frame
+	ConnectTimeout int    // seconds a registered client has to open its socket
+	ResumeWindow   int    // seconds a disconnected client may resume its session
+	HistorySize    int    // sequenced events kept per channel for replay
+	MaxMessageSize int    // maximum size of an inbound message, in bytes
+	SendBuffer     int    // outbound messages queued per client
+	SlowConsumer   string // policy for clients that fall behind
+	Cluster        string // bus connecting the service's instances
+}
+
+var cfg = config.Load[*Config]()
+
+// seconds converts a config value in seconds to a duration
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,8 @@
+-- Frames too large for a NOTIFY payload; listeners fetch them by ID
+CREATE TABLE cluster_frames (
+    id BIGSERIAL PRIMARY KEY,
+    payload BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_cluster_frames_created_at ON cluster_frames(created_at);
@@ -0,0 +1,81 @@
+package carriers
+
+import (
+	"sort"
+	"time"
+)
+
+// historyEntry is a sequenced event kept for replay
+type historyEntry struct {
+	out outbound
+	at  time.Time
+}
+
+// coverage records which sequence numbers a channel's history accounts for.
+// Sequence numbers increase but may skip some, so a missing number alone does
+// not mean an event was lost.
+type coverage struct {
+	first   int64 // lowest sequence number this node recorded
+	trimmed int64 // highest sequence number dropped to keep the history bounded
+}
+
+// record keeps a sequenced event for replay. Each channel keeps its most
+// recent HistorySize events, and only for as long as a client may resume.
+func (n *Node) record(out outbound) {
+	n.historyMu.Lock()
+	defer n.historyMu.Unlock()
+
+	now := time.Now()
+	for _, e := range n.history[out.channel] {
+		// Redelivered by the publisher
+		if e.out.seq == out.seq {
+			return
+		}
+	}
+	cov, ok := n.coverage[out.channel]
+	if !ok || out.seq < cov.first {
+		cov.first = out.seq
+	}
+	entries := append(n.history[out.channel], historyEntry{out: out, at: now})
+	if len(entries) > cfg.HistorySize {
+		for _, e := range entries[:len(entries)-cfg.HistorySize] {
+			if e.out.seq > cov.trimmed {
+				cov.trimmed = e.out.seq
+			}
+		}
+		entries = entries[len(entries)-cfg.HistorySize:]
+	}
+	n.history[out.channel] = entries
+	n.coverage[out.channel] = cov
+
+	// Drop channels that have been idle for longer than the resume window
+	cutoff := now.Add(-seconds(cfg.ResumeWindow))
+	for channel, entries := range n.history {
+		if entries[len(entries)-1].at.Before(cutoff) {
+			delete(n.history, channel)
+			delete(n.coverage, channel)
+		}
+	}
+}
+
+// replay returns the events of a channel with a sequence number after seq, in
+// sequence order. complete is false if some of those events are no longer kept,
+// or may have been published before this node started recording the channel.
+func (n *Node) replay(channel string, seq int64) (events []outbound, complete bool) {
+	n.historyMu.Lock()
+	defer n.historyMu.Unlock()
+
+	entries := n.history[channel]
+	for _, e := range entries {
+		if e.out.seq > seq {
+			events = append(events, e.out)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].seq < events[j].seq })
+
+	// Publishers skip sequence numbers, so a gap after seq only means events
+	// were lost if this node trimmed them, or had not yet seen the channel
+	cov, ok := n.coverage[channel]
+	complete = !ok || (cov.trimmed <= seq && cov.first <= seq+1)
+	return events, complete
+}
@@ -0,0 +1,52 @@
+package carriers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	size := cfg.HistorySize
+	t.Cleanup(func() { cfg.HistorySize = size })
+
+	tests := []struct {
+		name         string
+		recorded     []int64
+		historySize  int
+		seq          int64
+		want         []int64
+		wantComplete bool
+	}{
+		{"nothing recorded", nil, 10, 3, nil, true},
+		{"consecutive", []int64{1, 2, 3, 4}, 10, 2, []int64{3, 4}, true},
+		{"up to date", []int64{1, 2, 3}, 10, 3, nil, true},
+		{"skipped numbers", []int64{1, 2, 5, 9}, 10, 1, []int64{2, 5, 9}, true},
+		{"gap right after the cursor", []int64{1, 2, 5}, 10, 2, []int64{5}, true},
+		{"redelivered out of order", []int64{1, 3, 2, 3}, 10, 1, []int64{2, 3}, true},
+		{"trimmed after the cursor", []int64{1, 2, 3, 4, 5}, 3, 1, []int64{3, 4, 5}, false},
+		{"trimmed before the cursor", []int64{1, 2, 3, 4, 5}, 3, 2, []int64{3, 4, 5}, true},
+		{"recorded from after the cursor", []int64{7, 8}, 10, 4, []int64{7, 8}, false},
+		{"recorded from the next event", []int64{5, 6}, 10, 4, []int64{5, 6}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.HistorySize = tt.historySize
+			n := NewNode(NewMemoryBus())
+			for _, seq := range tt.recorded {
+				n.record(outbound{channel: "ch", seq: seq})
+			}
+
+			events, complete := n.replay("ch", tt.seq)
+			var got []int64
+			for _, out := range events {
+				got = append(got, out.seq)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replayed %v, want %v", got, tt.want)
+			}
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+		})
+	}
+}
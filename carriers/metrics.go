@@ -0,0 +1,14 @@
+package carriers
+
+import "encore.dev/metrics"
+
+// ConnectedClients is the number of open WebSocket connections
+var ConnectedClients = metrics.NewGauge[int64]("websocket_connected_clients", metrics.GaugeConfig{})
+
+// DropLabels labels dropped messages by the slow-consumer action taken
+type DropLabels struct {
+	Reason string // disconnect, drop_oldest, coalesced, unread_overflow
+}
+
+// DroppedMessages counts outbound messages that were never written to a client
+var DroppedMessages = metrics.NewCounterGroup[DropLabels, uint64]("websocket_dropped_messages", metrics.CounterConfig{})
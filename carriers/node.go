@@ -0,0 +1,248 @@
+package carriers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"encore.dev/rlog"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Node is one instance's registry of WebSocket clients. Nodes share client
+// registrations, channel membership and events over a Bus, so that an event
+// handled by any instance reaches the client on whichever instance holds its
+// socket.
+type Node struct {
+	id  string
+	bus Bus
+
+	clientsMu sync.RWMutex
+	clients   map[string]*wsClient
+
+	historyMu sync.Mutex
+	history   map[string][]historyEntry // sequenced events per channel, oldest first
+	coverage  map[string]coverage       // which sequence numbers each channel's history accounts for
+}
+
+// NewNode creates a node and connects it to a bus
+func NewNode(bus Bus) *Node {
+	n := &Node{
+		id:       fmt.Sprintf("node_%s", uuid.New().String()),
+		bus:      bus,
+		clients:  make(map[string]*wsClient),
+		history:  make(map[string][]historyEntry),
+		coverage: make(map[string]coverage),
+	}
+	bus.Subscribe(n)
+	return n
+}
+
+// ID returns the node's unique identifier
+func (n *Node) ID() string {
+	return n.id
+}
+
+// self is the node of this instance
+var self = NewNode(newBus())
+
+// newBus creates the bus configured to connect this service's instances
+func newBus() Bus {
+	if cfg.Cluster == ClusterMemory {
+		return NewMemoryBus()
+	}
+	return newPostgresBus(clusterDB)
+}
+
+// publish applies a frame on this node and shares it with the others
+func (n *Node) publish(f *Frame) error {
+	f.Node = n.id
+	err := n.apply(f)
+	if shareErr := n.share(f); shareErr != nil {
+		return shareErr
+	}
+	return err
+}
+
+// share sends a frame to the other nodes
+func (n *Node) share(f *Frame) error {
+	f.Node = n.id
+	ctx, cancel := context.WithTimeout(context.Background(), seconds(cfg.WriteTimeout))
+	defer cancel()
+	if err := n.bus.Publish(ctx, f); err != nil {
+		return fmt.Errorf("publish %s frame: %w", f.Kind, err)
+	}
+	return nil
+}
+
+// receive applies a frame published by another node
+func (n *Node) receive(f *Frame) {
+	if f.Node == n.id {
+		return
+	}
+	if err := n.apply(f); err != nil {
+		rlog.Debug("failed to apply cluster frame", "kind", f.Kind, "client_id", f.ClientID, "error", err)
+	}
+}
+
+// apply updates this node's copy of the cluster state with a frame
+func (n *Node) apply(f *Frame) error {
+	if f.Kind == FrameDeliver {
+		return n.deliverFrame(f)
+	}
+	if f.Kind == FrameRegister {
+		n.registered(f)
+		return nil
+	}
+
+	client, err := n.getClient(f.ClientID)
+	if err != nil {
+		// Registered before this node joined, or already expired
+		return err
+	}
+
+	switch f.Kind {
+	case FrameJoin:
+		client.setJoined(f.Channel, true)
+	case FrameLeave:
+		client.setJoined(f.Channel, false)
+	case FrameAck:
+		client.ack(f.Channel, f.Seq)
+	case FrameAttach:
+		n.attached(client, f.Node)
+	case FrameDetach:
+		client.detachedFrom(f.Node)
+	case FrameHandoff:
+		if f.Target == n.id {
+			client.handoff(f.Messages)
+		}
+	default:
+		return fmt.Errorf("unknown frame kind: %s", f.Kind)
+	}
+	return nil
+}
+
+// registered adds a client registered on another node
+func (n *Node) registered(f *Frame) {
+	var handler WebSocketHandler
+	if f.Handler != nil {
+		h, err := restoreHandler(f.ClientID, f.Handler)
+		if err != nil {
+			rlog.Error("failed to restore handler", "client_id", f.ClientID, "kind", f.Handler.Kind, "error", err)
+		}
+		handler = h
+	}
+	n.add(n.newClient(f.ClientID, f.ResumeToken, f.Node, handler))
+}
+
+// attached records that a client opened its socket on another node. If this
+// node held the client's socket or unread events, it gives them up.
+func (n *Node) attached(client *wsClient, node string) {
+	client.mu.Lock()
+	var unread []outbound
+	if client.owner == n.id {
+		if client.open {
+			client.kickLocked(websocket.ClosePolicyViolation, "connected on another instance")
+		}
+		unread = client.unread
+	}
+	client.owner = node
+	client.open = true
+	client.unread = nil
+	client.mu.Unlock()
+
+	if len(unread) == 0 {
+		return
+	}
+	msgs := make([]FrameMessage, len(unread))
+	for i, out := range unread {
+		msgs[i] = FrameMessage{Data: out.data, Channel: out.channel, Seq: out.seq}
+	}
+	err := n.share(&Frame{Kind: FrameHandoff, ClientID: client.id, Target: node, Messages: msgs})
+	if err != nil {
+		rlog.Error("failed to hand over unread messages", "client_id", client.id, "error", err)
+	}
+}
+
+// detachedFrom records that a client's socket on another node closed
+func (c *wsClient) detachedFrom(node string) {
+	c.mu.Lock()
+	if c.owner != node {
+		c.mu.Unlock()
+		return
+	}
+	c.open = false
+	c.detached = time.Now()
+	c.mu.Unlock()
+
+	c.expireAfter(seconds(cfg.ResumeWindow))
+}
+
+// handoff queues the unread messages another node held for the client
+func (c *wsClient) handoff(msgs []FrameMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || c.owner != c.node.id {
+		return
+	}
+
+	unread := make([]outbound, len(msgs))
+	for i, m := range msgs {
+		unread[i] = outbound{data: m.Data, channel: m.Channel, seq: m.Seq}
+	}
+	if !c.open {
+		c.unread = append(unread, c.unread...)
+		return
+	}
+	c.queue = append(c.pendingLocked(unread, c.resumed), c.queue...)
+	c.signalLocked()
+}
+
+// NewWebSocket registers a client with this instance's node
+func NewWebSocket(handler WebSocketHandler) *WebSocketResponse {
+	return self.NewWebSocket(handler)
+}
+
+// Broadcast sends a message to all connected clients
+func Broadcast(msg []byte) error {
+	return self.Broadcast(msg)
+}
+
+// BroadcastEvent sends a message to the clients that have joined a channel
+func BroadcastEvent(msg []byte, channelID string) error {
+	return self.BroadcastEvent(msg, channelID)
+}
+
+// BroadcastSequencedEvent sends a sequenced message to the clients that have
+// joined a channel, keeping it for replay
+func BroadcastSequencedEvent(msg []byte, channelID string, seq int64) error {
+	return self.BroadcastSequencedEvent(msg, channelID, seq)
+}
+
+// Send sends a message to a single client
+func Send(clientID string, msg []byte) error {
+	return self.Send(clientID, msg)
+}
+
+// Join subscribes a client to the events of a channel
+func Join(clientID, channelID string) error {
+	return self.Join(clientID, channelID)
+}
+
+// Leave unsubscribes a client from the events of a channel
+func Leave(clientID, channelID string) error {
+	return self.Leave(clientID, channelID)
+}
+
+// Ack records the last sequence number a client has processed on a channel
+func Ack(clientID, channelID string, seq int64) error {
+	return self.Ack(clientID, channelID, seq)
+}
+
+// Channels returns the channels a client has joined
+func Channels(clientID string) ([]string, error) {
+	return self.Channels(clientID)
+}
@@ -0,0 +1,225 @@
+package carriers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"encore.dev/cron"
+	"encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+	"github.com/jackc/pgx/v5"
+)
+
+// clusterDB carries frames between the service's instances
+var clusterDB = sqldb.NewDatabase("carriers", sqldb.DatabaseConfig{
+	Migrations: "db/migrations",
+})
+
+// notifyChannel is the Postgres channel frames are announced on
+const notifyChannel = "carriers_frames"
+
+// postgresBus connects the nodes of all instances with LISTEN/NOTIFY. Frames
+// are stored in cluster_frames and announced by ID, so secrets such as resume
+// tokens never travel in a notification, payloads aren't limited by NOTIFY's
+// 8000 bytes, and a listener that reconnects catches up on the frames it
+// missed. Frames are kept for a few minutes; a listener down for longer
+// loses the older ones, though resuming clients can recover sequenced events.
+type postgresBus struct {
+	db *sqldb.Database
+
+	mu     sync.RWMutex
+	nodes  []*Node
+	listen sync.Once
+
+	lastID int64 // highest frame ID dispatched, only used by the listener
+}
+
+func newPostgresBus(db *sqldb.Database) *postgresBus {
+	return &postgresBus{db: db}
+}
+
+// Subscribe connects a node to the bus, starting the listener on first use
+func (b *postgresBus) Subscribe(n *Node) {
+	b.mu.Lock()
+	b.nodes = append(b.nodes, n)
+	b.mu.Unlock()
+
+	b.listen.Do(func() { go b.run() })
+}
+
+// Publish stores a frame and notifies every listening instance of it
+func (b *postgresBus) Publish(ctx context.Context, f *Frame) error {
+	payload, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("marshal frame: %w", err)
+	}
+
+	var id int64
+	err = b.db.QueryRow(ctx, `
+		INSERT INTO cluster_frames (payload) VALUES ($1) RETURNING id
+	`, payload).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("store frame: %w", err)
+	}
+	if _, err := b.db.Exec(ctx, `SELECT pg_notify($1, $2)`, notifyChannel, strconv.FormatInt(id, 10)); err != nil {
+		return fmt.Errorf("notify frame: %w", err)
+	}
+	return nil
+}
+
+// run listens for frames, reconnecting when the connection fails
+func (b *postgresBus) run() {
+	ctx := context.Background()
+	for {
+		err := b.listenOnce(ctx)
+		rlog.Error("cluster listener stopped, reconnecting", "error", err)
+		time.Sleep(time.Second)
+	}
+}
+
+// listenOnce holds a connection listening for frames until it fails
+func (b *postgresBus) listenOnce(ctx context.Context) error {
+	conn, err := b.db.Stdlib().Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	return sqldb.DriverConn(conn, func(c *pgx.Conn) error {
+		if _, err := c.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+			return fmt.Errorf("listen: %w", err)
+		}
+		// Don't return the connection to the pool still listening
+		defer c.Exec(context.Background(), "UNLISTEN "+notifyChannel)
+
+		// Frames stored after LISTEN are also announced, so skip those the
+		// catch up already dispatched
+		replayed, err := b.catchUp(ctx)
+		if err != nil {
+			return err
+		}
+
+		for {
+			notification, err := c.WaitForNotification(ctx)
+			if err != nil {
+				return fmt.Errorf("wait for notification: %w", err)
+			}
+			id, err := strconv.ParseInt(notification.Payload, 10, 64)
+			if err != nil {
+				rlog.Error("invalid cluster frame notification", "payload", notification.Payload)
+				continue
+			}
+			if replayed[id] {
+				continue
+			}
+			f, err := b.fetch(ctx, id)
+			if err != nil {
+				rlog.Error("failed to fetch cluster frame", "id", id, "error", err)
+				continue
+			}
+			b.dispatch(id, f)
+		}
+	})
+}
+
+// catchUp dispatches the frames stored since the last one dispatched, in the
+// order they were published, returning their IDs. The first time it only
+// notes where the stored frames end, as frames from before this instance
+// started are of no use to it.
+func (b *postgresBus) catchUp(ctx context.Context) (map[int64]bool, error) {
+	if b.lastID == 0 {
+		err := b.db.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM cluster_frames`).Scan(&b.lastID)
+		if err != nil {
+			return nil, fmt.Errorf("get last frame: %w", err)
+		}
+		return nil, nil
+	}
+
+	rows, err := b.db.Query(ctx, `
+		SELECT id, payload FROM cluster_frames WHERE id > $1 ORDER BY id
+	`, b.lastID)
+	if err != nil {
+		return nil, fmt.Errorf("get missed frames: %w", err)
+	}
+	defer rows.Close()
+
+	replayed := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		var payload []byte
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, fmt.Errorf("scan frame: %w", err)
+		}
+		var f Frame
+		if err := json.Unmarshal(payload, &f); err != nil {
+			rlog.Error("failed to decode cluster frame", "id", id, "error", err)
+			continue
+		}
+		b.dispatch(id, &f)
+		replayed[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get missed frames: %w", err)
+	}
+	if len(replayed) > 0 {
+		rlog.Info("caught up on cluster frames", "frames", len(replayed))
+	}
+	return replayed, nil
+}
+
+// fetch reads a stored frame
+func (b *postgresBus) fetch(ctx context.Context, id int64) (*Frame, error) {
+	var payload []byte
+	err := b.db.QueryRow(ctx, `
+		SELECT payload FROM cluster_frames WHERE id = $1
+	`, id).Scan(&payload)
+	if err != nil {
+		return nil, fmt.Errorf("get frame %d: %w", id, err)
+	}
+
+	var f Frame
+	if err := json.Unmarshal(payload, &f); err != nil {
+		return nil, fmt.Errorf("unmarshal frame: %w", err)
+	}
+	return &f, nil
+}
+
+// dispatch applies a frame on the nodes of this instance
+func (b *postgresBus) dispatch(id int64, f *Frame) {
+	if id > b.lastID {
+		b.lastID = id
+	}
+
+	b.mu.RLock()
+	nodes := b.nodes
+	b.mu.RUnlock()
+
+	for _, n := range nodes {
+		n.receive(f)
+	}
+}
+
+// Stored frames are only needed until every instance has fetched them, or
+// caught up after reconnecting
+var _ = cron.NewJob("prune-cluster-frames", cron.JobConfig{
+	Title:    "Prune stored cluster frames",
+	Every:    10 * cron.Minute,
+	Endpoint: PruneClusterFrames,
+})
+
+// PruneClusterFrames deletes stored frames that every instance has had time to fetch
+//
+//encore:api private
+func PruneClusterFrames(ctx context.Context) error {
+	_, err := clusterDB.Exec(ctx, `
+		DELETE FROM cluster_frames WHERE created_at < NOW() - INTERVAL '5 minutes'
+	`)
+	if err != nil {
+		return fmt.Errorf("prune cluster frames: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package carriers
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"encore.dev/beta/errs"
+	"encore.dev/rlog"
+)
+
+// HandleSSE streams a client's events as Server-Sent Events, for clients that
+// cannot use WebSockets. Clients are registered and join channels exactly as
+// for a WebSocket, and receive the same messages, each as the data of an SSE
+// event. Sequenced events carry an ID recording the last sequence number sent
+// on each channel, which the browser returns in Last-Event-ID on reconnect to
+// resume the stream. Opening the stream requires the client's resume_token,
+// so it should be part of the stream URL. Messages from the client are sent
+// with POST to the same path.
+//
+//encore:api public raw method=GET path=/api/sse/:client_id
+func HandleSSE(w http.ResponseWriter, req *http.Request) {
+	self.ServeSSE(w, req)
+}
+
+// ServeSSE serves a client's event stream from this node
+func (n *Node) ServeSSE(w http.ResponseWriter, req *http.Request) {
+	client, err := n.getClient(req.URL.Path[len("/api/sse/"):])
+	if err != nil {
+		http.Error(w, "client not found", http.StatusNotFound)
+		return
+	}
+
+	lastSeq, err := parseLastSeq(req.URL.Query()["last_seq"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if id := req.Header.Get("Last-Event-ID"); id != "" {
+		cursor, err := parseEventID(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for channel, seq := range cursor {
+			lastSeq[channel] = seq
+		}
+	}
+
+	kick, err := n.attach(client, req.URL.Query().Get("resume_token"), lastSeq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	updateConnected(1)
+	defer updateConnected(-1)
+	connCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.connected(connCtx)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // stop proxies buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	writeEvents(w, req, client, kick, lastSeq)
+
+	// Keep the client for resuming
+	cancel()
+	if client.detach() {
+		client.disconnected()
+	}
+}
+
+// sseClose is the data of the close event sent when the server ends a stream
+type sseClose struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// writeEvents writes queued messages and keepalive comments to the event
+// stream until the client is kicked or closed, or the request ends
+func writeEvents(w http.ResponseWriter, req *http.Request, client *wsClient, kick <-chan struct{}, cursor map[string]int64) {
+	rc := http.NewResponseController(w)
+	ticker := time.NewTicker(seconds(cfg.PingInterval))
+	defer ticker.Stop()
+
+	// write writes a chunk of the stream, ignoring deadlines the writer doesn't support
+	write := func(chunk string) error {
+		rc.SetWriteDeadline(time.Now().Add(seconds(cfg.WriteTimeout)))
+		if _, err := io.WriteString(w, chunk); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	closeWith := func(code int, reason string) {
+		data, _ := json.Marshal(sseClose{Code: code, Reason: reason})
+		write(fmt.Sprintf("event: close\ndata: %s\n\n", data))
+	}
+
+	// Send anything queued while the stream was being opened
+	write(": connected\n\n")
+
+	for {
+		select {
+		case <-client.notify:
+			for _, out := range client.next() {
+				if err := write(formatEvent(out, cursor)); err != nil {
+					rlog.Error("write event error", "client_id", client.id, "error", err)
+					return
+				}
+			}
+		case <-ticker.C:
+			if err := write(": ping\n\n"); err != nil {
+				return
+			}
+		case <-kick:
+			client.mu.Lock()
+			code, reason := client.closeCode, client.closeReason
+			client.mu.Unlock()
+			closeWith(code, reason)
+			return
+		case <-client.done:
+			closeWith(http.StatusGone, "client closed")
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// formatEvent formats a message as an SSE event. Sequenced messages advance
+// the cursor and carry it as their ID.
+func formatEvent(out outbound, cursor map[string]int64) string {
+	var b strings.Builder
+	if out.seq > 0 {
+		if out.seq > cursor[out.channel] {
+			cursor[out.channel] = out.seq
+		}
+		fmt.Fprintf(&b, "id: %s\n", formatEventID(cursor))
+	}
+	for _, line := range strings.Split(string(out.data), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// formatEventID encodes the last sequence number sent on each channel
+func formatEventID(cursor map[string]int64) string {
+	v := url.Values{}
+	for channel, seq := range cursor {
+		v.Set(channel, strconv.FormatInt(seq, 10))
+	}
+	return v.Encode()
+}
+
+// parseEventID decodes an event ID made by formatEventID
+func parseEventID(id string) (map[string]int64, error) {
+	v, err := url.ParseQuery(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Last-Event-ID: %w", err)
+	}
+	cursor := make(map[string]int64, len(v))
+	for channel := range v {
+		seq, err := strconv.ParseInt(v.Get(channel), 10, 64)
+		if err != nil || seq < 0 {
+			return nil, fmt.Errorf("invalid Last-Event-ID: %s", id)
+		}
+		cursor[channel] = seq
+	}
+	return cursor, nil
+}
+
+// PostSSEMessage accepts a message from an SSE client, as a WebSocket client
+// would send it over its socket. The request body is the message, and the
+// client's resume token is required in the X-Resume-Token header, keeping it
+// out of URLs that get logged. Replies arrive on the event stream.
+//
+//encore:api public raw method=POST path=/api/sse/:client_id
+func PostSSEMessage(w http.ResponseWriter, req *http.Request) {
+	self.ServeSSEMessage(w, req)
+}
+
+// ServeSSEMessage handles a message from an SSE client on this node
+func (n *Node) ServeSSEMessage(w http.ResponseWriter, req *http.Request) {
+	client, err := n.getClient(req.URL.Path[len("/api/sse/"):])
+	if err != nil {
+		http.Error(w, "client not found", http.StatusNotFound)
+		return
+	}
+
+	// Unlike a socket, each POST stands alone, so it must prove it is the client
+	token := req.Header.Get("X-Resume-Token")
+	if !hmac.Equal([]byte(token), []byte(client.resumeToken)) {
+		http.Error(w, "invalid resume token", http.StatusForbidden)
+		return
+	}
+	if client.handler == nil {
+		http.Error(w, "client must send to the instance it registered with", http.StatusConflict)
+		return
+	}
+
+	msg, err := io.ReadAll(http.MaxBytesReader(w, req.Body, int64(cfg.MaxMessageSize)))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "message too large", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, "failed to read message", http.StatusBadRequest)
+		return
+	}
+
+	// Handlers reject bad messages with an errs code; other errors are ours
+	if err := client.handler.OnMessage(req.Context(), msg); err != nil {
+		rlog.Error("handle message error", "client_id", client.id, "error", err)
+		var e *errs.Error
+		if errors.As(err, &e) && e.Code.HTTPStatus() < http.StatusInternalServerError {
+			http.Error(w, e.Message, e.Code.HTTPStatus())
+		} else {
+			http.Error(w, "failed to handle message", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -2,9 +2,16 @@ package carriers
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"encore.dev/rlog"
 	"github.com/gorilla/websocket"
@@ -17,54 +24,213 @@ type WebSocketHandler interface {
 	ClientID() string
 }
 
+// ConnectionHandler is implemented by handlers that track when their client's
+// connection, a socket or an event stream, opens and closes. OnConnect's
+// context is cancelled when the connection ends, including when the client
+// moves to another node; OnDisconnect is only called if it did not.
+type ConnectionHandler interface {
+	OnConnect(ctx context.Context)
+	OnDisconnect(ctx context.Context)
+}
+
 // WebSocketResponse represents the response for a WebSocket connection
 type WebSocketResponse struct {
-	ClientID string `json:"client_id"`
+	ClientID    string `json:"client_id"`
+	ResumeToken string `json:"resume_token"`
+}
+
+// maxUnread is the number of events kept for a client whose socket is not open
+const maxUnread = 100
+
+// outbound is a message waiting to be written to a client
+type outbound struct {
+	data    []byte
+	channel string // channel the message was published to, if any
+	seq     int64  // sequence number within the channel, or 0 if unsequenced
 }
 
-// wsClient represents a connected WebSocket client
+// wsClient represents a client, connected by WebSocket or SSE. Every node
+// keeps a copy of each client; only the node that owns it queues and writes
+// its messages.
 type wsClient struct {
-	id      string
-	handler WebSocketHandler
-	send    chan []byte
+	id          string
+	node        *Node
+	handler     WebSocketHandler // nil if the handler cannot be restored on this node
+	resumeToken string
+	notify      chan struct{} // signalled when messages are queued
+	done        chan struct{} // closed when the client is unregistered
+
+	mu          sync.Mutex
+	owner       string           // ID of the node holding the socket, or that last held it
+	channels    map[string]bool  // channels the client has joined
+	acked       map[string]int64 // last sequence number the client acknowledged per channel
+	open        bool             // whether a socket is open now
+	detached    time.Time        // when the last socket closed
+	closed      bool             // whether the client has been unregistered
+	kick        chan struct{}    // closed to end the current socket
+	closeCode   int              // close frame sent when the current socket ends
+	closeReason string
+	resumed     map[string]bool // channels replayed from history when the socket opened
+	unread      []outbound      // events received while no socket was open, oldest first
+	queue       []outbound      // messages waiting to be written, oldest first
 }
 
-var (
-	clients   = make(map[string]*wsClient)
-	clientsMu sync.RWMutex
-)
+// newClient creates a client owned by the given node
+func (n *Node) newClient(id, resumeToken, owner string, handler WebSocketHandler) *wsClient {
+	return &wsClient{
+		id:          id,
+		node:        n,
+		handler:     handler,
+		resumeToken: resumeToken,
+		notify:      make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		owner:       owner,
+		channels:    make(map[string]bool),
+		acked:       make(map[string]int64),
+		detached:    time.Now(),
+	}
+}
 
 // NewWebSocket creates a new WebSocket connection and registers the client
-func NewWebSocket(handler WebSocketHandler) *WebSocketResponse {
+// with every node. A client that does not open its socket within the connect
+// timeout is dropped. The returned resume token is required to open the
+// socket, and lets the client reopen it after a disconnect, within the resume
+// window, without losing events.
+func (n *Node) NewWebSocket(handler WebSocketHandler) *WebSocketResponse {
 	clientID := handler.ClientID()
 
 	// Register client
-	clientsMu.Lock()
-	clients[clientID] = &wsClient{
-		id:      clientID,
-		handler: handler,
-		send:    make(chan []byte, 256),
+	client := n.newClient(clientID, newResumeToken(), n.id, handler)
+	n.add(client)
+
+	// Other nodes can only serve the socket if they can restore the handler
+	frame := &Frame{Kind: FrameRegister, ClientID: clientID, ResumeToken: client.resumeToken}
+	if h, ok := handler.(PortableHandler); ok {
+		state, err := h.State()
+		if err != nil {
+			rlog.Error("failed to save handler state", "client_id", clientID, "error", err)
+		} else {
+			frame.Handler = &HandlerState{Kind: h.Kind(), State: state}
+		}
+	}
+	if err := n.share(frame); err != nil {
+		rlog.Error("failed to share client registration", "client_id", clientID, "error", err)
 	}
-	clientsMu.Unlock()
 
 	return &WebSocketResponse{
-		ClientID: clientID,
+		ClientID:    clientID,
+		ResumeToken: client.resumeToken,
+	}
+}
+
+// add registers a client on this node
+func (n *Node) add(client *wsClient) {
+	n.clientsMu.Lock()
+	n.clients[client.id] = client
+	n.clientsMu.Unlock()
+
+	client.expireAfter(seconds(cfg.ConnectTimeout))
+}
+
+// newResumeToken generates a secret for resuming a client's session
+func newResumeToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("generate resume token: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// expireAfter unregisters the client if it has no open socket after d
+func (c *wsClient) expireAfter(d time.Duration) {
+	c.mu.Lock()
+	detached := c.detached
+	c.mu.Unlock()
+
+	time.AfterFunc(d, func() {
+		c.mu.Lock()
+		expired := !c.open && c.detached.Equal(detached)
+		c.mu.Unlock()
+		if expired {
+			c.node.unregister(c)
+		}
+	})
+}
+
+// unregister removes a client from the node and closes it
+func (n *Node) unregister(client *wsClient) {
+	n.clientsMu.Lock()
+	if n.clients[client.id] == client {
+		delete(n.clients, client.id)
+	}
+	n.clientsMu.Unlock()
+
+	client.mu.Lock()
+	alreadyClosed := client.closed
+	owned := client.owner == n.id
+	if !alreadyClosed {
+		client.closed = true
+		client.unread = nil
+		client.queue = nil
+		close(client.done)
+	}
+	client.mu.Unlock()
+
+	// Each node expires its own copy; the owner reports the closure
+	if !alreadyClosed && owned && client.handler != nil {
+		client.handler.OnClose(context.Background())
 	}
 }
 
 // Broadcast sends a message to all connected clients
-func Broadcast(msg []byte) error {
-	clientsMu.RLock()
-	defer clientsMu.RUnlock()
+func (n *Node) Broadcast(msg []byte) error {
+	return n.publish(&Frame{Kind: FrameDeliver, Data: msg})
+}
+
+// BroadcastEvent sends a message to the clients that have joined a channel
+func (n *Node) BroadcastEvent(msg []byte, channelID string) error {
+	return n.publish(&Frame{Kind: FrameDeliver, Channel: channelID, Data: msg})
+}
+
+// BroadcastSequencedEvent sends a message carrying a sequence number to the
+// clients that have joined a channel, and keeps it for replay to clients
+// that resume after missing it
+func (n *Node) BroadcastSequencedEvent(msg []byte, channelID string, seq int64) error {
+	return n.publish(&Frame{Kind: FrameDeliver, Channel: channelID, Seq: seq, Data: msg})
+}
+
+// Send sends a message to a single client
+func (n *Node) Send(clientID string, msg []byte) error {
+	if _, err := n.getClient(clientID); err != nil {
+		return err
+	}
+	return n.publish(&Frame{Kind: FrameDeliver, ClientID: clientID, Data: msg})
+}
+
+// deliverFrame queues a delivered message for the clients it is addressed to
+// that this node owns
+func (n *Node) deliverFrame(f *Frame) error {
+	out := outbound{data: f.Data, channel: f.Channel, seq: f.Seq}
+	if out.seq > 0 {
+		n.record(out)
+	}
+
+	n.clientsMu.RLock()
+	defer n.clientsMu.RUnlock()
 
 	var lastErr error
-	for _, client := range clients {
-		select {
-		case client.send <- msg:
-			// Message sent successfully
-		default:
-			err := fmt.Errorf("failed to send message to client %s: buffer full", client.id)
-			rlog.Error("failed to send message to client", "client_id", client.id, "error", err)
+	for _, client := range n.clients {
+		switch {
+		case f.ClientID != "":
+			if client.id != f.ClientID {
+				continue
+			}
+		case f.Channel != "":
+			if !client.joined(f.Channel) {
+				continue
+			}
+		}
+		if err := client.deliver(out); err != nil {
 			lastErr = err
 		}
 	}
@@ -72,17 +238,237 @@ func Broadcast(msg []byte) error {
 	return lastErr
 }
 
-// BroadcastEvent sends a message to all connected clients for a specific channel
-func BroadcastEvent(msg []byte, channelID string) error {
-	// For now, just use Broadcast since we're using a single channel
-	// In the future, we can add channel-specific routing
-	return Broadcast(msg)
+// deliver queues a message for the client if this node owns it. While no
+// socket is open, messages are kept in the client's unread buffer, dropping
+// the oldest. Once open, a full send buffer is handled by the slow-consumer policy.
+func (c *wsClient) deliver(out outbound) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("client closed: %s", c.id)
+	}
+	if c.owner != c.node.id {
+		return nil
+	}
+
+	if !c.open {
+		if len(c.unread) == maxUnread {
+			c.unread = c.unread[1:]
+			DroppedMessages.With(DropLabels{Reason: "unread_overflow"}).Increment()
+		}
+		c.unread = append(c.unread, out)
+		return nil
+	}
+
+	if len(c.queue) >= cfg.SendBuffer {
+		switch cfg.SlowConsumer {
+		case PolicyDropOldest:
+			c.queue = c.queue[1:]
+			DroppedMessages.With(DropLabels{Reason: "drop_oldest"}).Increment()
+		case PolicyCoalesce:
+			c.coalesceLocked()
+		default:
+			// Sequenced events can be replayed when the client resumes
+			DroppedMessages.With(DropLabels{Reason: "disconnect"}).Add(uint64(len(c.queue) + 1))
+			c.queue = nil
+			c.kickLocked(websocket.ClosePolicyViolation, "slow consumer")
+			err := fmt.Errorf("failed to send message to client %s: buffer full", c.id)
+			rlog.Warn("disconnecting slow client", "client_id", c.id, "error", err)
+			return err
+		}
+	}
+
+	c.queue = append(c.queue, out)
+	c.signalLocked()
+	return nil
+}
+
+// signalLocked wakes the writer
+func (c *wsClient) signalLocked() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// coalesceLocked makes room in a full queue by dropping typing events, which
+// are superseded by later ones, or else the oldest message
+func (c *wsClient) coalesceLocked() {
+	kept := c.queue[:0]
+	for _, out := range c.queue {
+		if isTyping(out.data) {
+			DroppedMessages.With(DropLabels{Reason: "coalesced"}).Increment()
+			continue
+		}
+		kept = append(kept, out)
+	}
+	c.queue = kept
+
+	if len(c.queue) >= cfg.SendBuffer {
+		c.queue = c.queue[1:]
+		DroppedMessages.With(DropLabels{Reason: "drop_oldest"}).Increment()
+	}
+}
+
+// isTyping reports whether a message is a typing event
+func isTyping(msg []byte) bool {
+	var event struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(msg, &event) == nil && event.Type == "typing"
+}
+
+// resync tells a resuming client that events of a channel were lost and it
+// must reload the channel's history
+type resync struct {
+	Type      string `json:"type"` // resync_required
+	ChannelID string `json:"channel_id"`
+}
+
+// attach opens a socket session for the client on this node. Every socket,
+// including the first, requires the client's resume token, which only the
+// caller that registered the client was given. When lastSeq gives the last
+// sequence number the client saw on a channel, or the client acknowledged one,
+// newer events are replayed from the channel's history; otherwise the client
+// gets the events it missed while detached.
+func (c *wsClient) attach(resumeToken string, lastSeq map[string]int64) (<-chan struct{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, fmt.Errorf("client closed: %s", c.id)
+	}
+	if c.open {
+		return nil, fmt.Errorf("client already connected: %s", c.id)
+	}
+	if c.handler == nil {
+		return nil, fmt.Errorf("client %s must connect to the instance it registered with", c.id)
+	}
+	if !hmac.Equal([]byte(resumeToken), []byte(c.resumeToken)) {
+		return nil, fmt.Errorf("invalid resume token for client %s", c.id)
+	}
+
+	var queue []outbound
+	resumed := make(map[string]bool)
+	for channel := range c.channels {
+		last, ok := lastSeq[channel]
+		if !ok {
+			last, ok = c.acked[channel]
+		}
+		if !ok {
+			continue
+		}
+		events, complete := c.node.replay(channel, last)
+		if !complete {
+			msg, _ := json.Marshal(resync{Type: "resync_required", ChannelID: channel})
+			queue = append(queue, outbound{data: msg})
+		}
+		queue = append(queue, events...)
+		resumed[channel] = true
+	}
+	// Unread events are only held by this node if it owned the client
+	if c.owner == c.node.id {
+		queue = append(queue, c.pendingLocked(c.unread, resumed)...)
+	}
+
+	c.owner = c.node.id
+	c.open = true
+	c.resumed = resumed
+	c.unread = nil
+	c.queue = queue
+	c.kick = make(chan struct{})
+	c.closeCode = websocket.CloseNormalClosure
+	c.closeReason = ""
+	if len(c.queue) > 0 {
+		c.signalLocked()
+	}
+	return c.kick, nil
 }
 
-// HandleWebSocket upgrades an HTTP connection to WebSocket and manages the connection
+// pendingLocked returns the messages of unread that were not already replayed
+// from the history of a resumed channel
+func (c *wsClient) pendingLocked(unread []outbound, resumed map[string]bool) []outbound {
+	var pending []outbound
+	for _, out := range unread {
+		if resumed[out.channel] && out.seq > 0 {
+			continue
+		}
+		pending = append(pending, out)
+	}
+	return pending
+}
+
+// detach ends the client's socket session on this node, keeping the client
+// registered for the resume window. It reports false if the client has since
+// connected to another node.
+func (c *wsClient) detach() bool {
+	c.mu.Lock()
+	if c.owner != c.node.id {
+		c.mu.Unlock()
+		return false
+	}
+	c.open = false
+	c.detached = time.Now()
+	c.unread = c.queue
+	c.queue = nil
+	c.mu.Unlock()
+
+	if err := c.node.share(&Frame{Kind: FrameDetach, ClientID: c.id}); err != nil {
+		rlog.Error("failed to share client detach", "client_id", c.id, "error", err)
+	}
+	c.expireAfter(seconds(cfg.ResumeWindow))
+	return true
+}
+
+// connected tells the handler that the client's connection opened. ctx is
+// cancelled when the connection ends.
+func (c *wsClient) connected(ctx context.Context) {
+	if h, ok := c.handler.(ConnectionHandler); ok {
+		h.OnConnect(ctx)
+	}
+}
+
+// disconnected tells the handler that the client's connection closed
+func (c *wsClient) disconnected() {
+	if h, ok := c.handler.(ConnectionHandler); ok {
+		h.OnDisconnect(context.Background())
+	}
+}
+
+// next takes the queued messages
+func (c *wsClient) next() []outbound {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	msgs := c.queue
+	c.queue = nil
+	return msgs
+}
+
+// kickLocked ends the current socket with a close frame
+func (c *wsClient) kickLocked(code int, reason string) {
+	if !c.open || c.closeReason != "" {
+		return
+	}
+	c.closeCode = code
+	c.closeReason = reason
+	close(c.kick)
+}
+
+// HandleWebSocket upgrades an HTTP connection to WebSocket and manages the
+// connection. The client ID is public, so the client's resume_token is
+// required to open the socket. To resume after a disconnect, also pass, for
+// each joined channel, last_seq=<channel_id>:<sequence> with the last
+// sequence number received.
 //
 //encore:api public raw method=GET path=/api/ws/:client_id
 func HandleWebSocket(w http.ResponseWriter, req *http.Request) {
+	self.ServeWebSocket(w, req)
+}
+
+// ServeWebSocket serves a client's socket from this node
+func (n *Node) ServeWebSocket(w http.ResponseWriter, req *http.Request) {
 	// Extract client ID from URL path
 	clientID := req.URL.Path[len("/api/ws/"):]
 	if clientID == "" {
@@ -91,14 +477,18 @@ func HandleWebSocket(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Validate client ID
-	clientsMu.RLock()
-	client, exists := clients[clientID]
-	clientsMu.RUnlock()
-	if !exists {
+	client, err := n.getClient(clientID)
+	if err != nil {
 		http.Error(w, "client not found", http.StatusNotFound)
 		return
 	}
 
+	lastSeq, err := parseLastSeq(req.URL.Query()["last_seq"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Upgrade connection to WebSocket
 	c, err := upgrader.Upgrade(w, req, nil)
 	if err != nil {
@@ -108,59 +498,143 @@ func HandleWebSocket(w http.ResponseWriter, req *http.Request) {
 	}
 	defer c.Close()
 
+	kick, err := n.attach(client, req.URL.Query().Get("resume_token"), lastSeq)
+	if err != nil {
+		c.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()),
+			time.Now().Add(seconds(cfg.WriteTimeout)))
+		return
+	}
+	updateConnected(1)
+	defer updateConnected(-1)
+	connCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.connected(connCtx)
+
 	// Start client goroutines
-	done := make(chan struct{})
+	readDone := make(chan struct{})
 	go func() {
-		defer close(done)
-		readMessages(req.Context(), c, client.handler)
+		defer close(readDone)
+		readMessages(req.Context(), c, client)
 	}()
-	writeMessages(c, client.send, done)
+	writeMessages(c, client, kick, readDone)
 
-	// Cleanup client on disconnect
-	clientsMu.Lock()
-	delete(clients, clientID)
-	close(client.send)
-	clientsMu.Unlock()
+	// Keep the client for resuming, giving the peer time to answer the close frame
+	cancel()
+	if client.detach() {
+		client.disconnected()
+	}
+	c.SetReadDeadline(time.Now().Add(seconds(cfg.WriteTimeout)))
+	<-readDone
 }
 
-// readMessages reads messages from the WebSocket connection
-func readMessages(ctx context.Context, c *websocket.Conn, handler WebSocketHandler) {
-	defer func() {
-		c.Close()
-		handler.OnClose(ctx)
-	}()
+// attach opens a session for a client on this node, whatever its transport
+func (n *Node) attach(client *wsClient, resumeToken string, lastSeq map[string]int64) (<-chan struct{}, error) {
+	kick, err := client.attach(resumeToken, lastSeq)
+	if err != nil {
+		return nil, err
+	}
+	// Other nodes stop buffering for the client and hand over what they hold
+	if err := n.share(&Frame{Kind: FrameAttach, ClientID: client.id}); err != nil {
+		rlog.Error("failed to share client attach", "client_id", client.id, "error", err)
+	}
+	return kick, nil
+}
+
+// parseLastSeq parses last_seq query values of the form <channel_id>:<sequence>.
+// Channel IDs may themselves contain colons, so the sequence follows the last one.
+func parseLastSeq(values []string) (map[string]int64, error) {
+	lastSeq := make(map[string]int64)
+	for _, v := range values {
+		i := strings.LastIndex(v, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid last_seq: %s", v)
+		}
+		seq, err := strconv.ParseInt(v[i+1:], 10, 64)
+		if err != nil || seq < 0 {
+			return nil, fmt.Errorf("invalid last_seq: %s", v)
+		}
+		lastSeq[v[:i]] = seq
+	}
+	return lastSeq, nil
+}
+
+// connected counts open sockets for the ConnectedClients gauge
+var (
+	connected   int64
+	connectedMu sync.Mutex
+)
+
+func updateConnected(delta int64) {
+	connectedMu.Lock()
+	defer connectedMu.Unlock()
+	connected += delta
+	ConnectedClients.Set(connected)
+}
+
+// readMessages reads messages from the WebSocket connection until it fails
+// or the peer stops answering pings
+func readMessages(ctx context.Context, c *websocket.Conn, client *wsClient) {
+	defer c.Close()
+
+	c.SetReadLimit(int64(cfg.MaxMessageSize))
+	c.SetReadDeadline(time.Now().Add(seconds(cfg.PongTimeout)))
+	c.SetPongHandler(func(string) error {
+		return c.SetReadDeadline(time.Now().Add(seconds(cfg.PongTimeout)))
+	})
 
 	for {
 		_, message, err := c.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				rlog.Error("websocket error", "error", err)
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				rlog.Error("websocket error", "client_id", client.id, "error", err)
 			}
-			break
+			return
 		}
+		c.SetReadDeadline(time.Now().Add(seconds(cfg.PongTimeout)))
 
-		if err := handler.OnMessage(ctx, message); err != nil {
+		if err := client.handler.OnMessage(ctx, message); err != nil {
 			rlog.Error("handle message error", "error", err)
 		}
 	}
 }
 
-// writeMessages writes messages to the WebSocket connection
-func writeMessages(c *websocket.Conn, send <-chan []byte, done <-chan struct{}) {
-	defer c.Close()
+// writeMessages writes queued messages and keepalive pings to the WebSocket
+// connection until the socket is kicked, the client is closed or the reader stops
+func writeMessages(c *websocket.Conn, client *wsClient, kick <-chan struct{}, readDone <-chan struct{}) {
+	ticker := time.NewTicker(seconds(cfg.PingInterval))
+	defer ticker.Stop()
+
+	closeWith := func(code int, reason string) {
+		deadline := time.Now().Add(seconds(cfg.WriteTimeout))
+		c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	}
 
 	for {
 		select {
-		case message, ok := <-send:
-			if !ok {
-				c.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+		case <-client.notify:
+			for _, out := range client.next() {
+				c.SetWriteDeadline(time.Now().Add(seconds(cfg.WriteTimeout)))
+				if err := c.WriteMessage(websocket.TextMessage, out.data); err != nil {
+					rlog.Error("write message error", "client_id", client.id, "error", err)
+					return
+				}
 			}
-			if err := c.WriteMessage(websocket.TextMessage, message); err != nil {
-				rlog.Error("write message error", "error", err)
+		case <-ticker.C:
+			deadline := time.Now().Add(seconds(cfg.WriteTimeout))
+			if err := c.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
 				return
 			}
-		case <-done:
+		case <-kick:
+			client.mu.Lock()
+			code, reason := client.closeCode, client.closeReason
+			client.mu.Unlock()
+			closeWith(code, reason)
+			return
+		case <-client.done:
+			closeWith(websocket.CloseNormalClosure, "")
+			return
+		case <-readDone:
 			return
 		}
 	}
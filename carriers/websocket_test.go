@@ -0,0 +1,310 @@
+package carriers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"encore.dev/beta/errs"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// testHandler records the messages its client sends, failing with err if set
+type testHandler struct {
+	id  string
+	err error
+
+	mu       sync.Mutex
+	messages []string
+}
+
+func newTestHandler() *testHandler {
+	return &testHandler{id: uuid.New().String()}
+}
+
+func (h *testHandler) OnMessage(_ context.Context, msg []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err != nil {
+		return h.err
+	}
+	h.messages = append(h.messages, string(msg))
+	return nil
+}
+
+func (h *testHandler) OnClose(context.Context) {}
+
+func (h *testHandler) ClientID() string { return h.id }
+
+// serveNode serves a node's socket and event stream endpoints
+func serveNode(t *testing.T, n *Node) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/ws/", n.ServeWebSocket)
+	mux.HandleFunc("/api/sse/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPost {
+			n.ServeSSEMessage(w, req)
+			return
+		}
+		n.ServeSSE(w, req)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testConn is a client socket whose messages are read in the background, as
+// a socket can't be read again after a read times out
+type testConn struct {
+	*websocket.Conn
+	messages chan string
+	err      chan error
+}
+
+// dial opens a client's socket, returning the close error if the node
+// refuses it
+func dial(t *testing.T, srv *httptest.Server, clientID, resumeToken string, lastSeq ...string) (*testConn, error) {
+	t.Helper()
+	q := url.Values{"last_seq": lastSeq}
+	if resumeToken != "" {
+		q.Set("resume_token", resumeToken)
+	}
+	u := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws/" + clientID + "?" + q.Encode()
+	c, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	tc := &testConn{Conn: c, messages: make(chan string, 16), err: make(chan error, 1)}
+	go func() {
+		for {
+			_, msg, err := c.ReadMessage()
+			if err != nil {
+				tc.err <- err
+				return
+			}
+			tc.messages <- string(msg)
+		}
+	}()
+
+	// A refused socket is closed straight away
+	select {
+	case err := <-tc.err:
+		return nil, err
+	case <-time.After(50 * time.Millisecond):
+		return tc, nil
+	}
+}
+
+// next returns the next message received on the socket
+func (c *testConn) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case msg := <-c.messages:
+		return msg
+	case err := <-c.err:
+		t.Fatalf("read: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("read: timed out")
+	}
+	return ""
+}
+
+func TestAttachRequiresResumeToken(t *testing.T) {
+	n := NewNode(NewMemoryBus())
+	srv := serveNode(t, n)
+
+	tests := []struct {
+		name    string
+		token   func(resp *WebSocketResponse) string
+		wantErr bool
+	}{
+		{"no token", func(*WebSocketResponse) string { return "" }, true},
+		{"wrong token", func(*WebSocketResponse) string { return "wrong" }, true},
+		{"resume token", func(resp *WebSocketResponse) string { return resp.ResumeToken }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"/websocket", func(t *testing.T) {
+			resp := n.NewWebSocket(newTestHandler())
+			_, err := dial(t, srv, resp.ClientID, tt.token(resp))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+		t.Run(tt.name+"/sse", func(t *testing.T) {
+			resp := n.NewWebSocket(newTestHandler())
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet,
+				srv.URL+"/api/sse/"+resp.ClientID+"?"+url.Values{"resume_token": {tt.token(resp)}}.Encode(), nil)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if got := res.StatusCode == http.StatusForbidden; got != tt.wantErr {
+				t.Errorf("status = %d, want error %v", res.StatusCode, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPostSSEMessage(t *testing.T) {
+	n := NewNode(NewMemoryBus())
+	srv := serveNode(t, n)
+
+	tests := []struct {
+		name       string
+		handlerErr error
+		token      func(resp *WebSocketResponse) string
+		query      bool // send the token in the query rather than the header
+		body       string
+		wantStatus int
+	}{
+		{"accepted", nil, func(r *WebSocketResponse) string { return r.ResumeToken }, false, "hello", http.StatusAccepted},
+		{"no token", nil, func(*WebSocketResponse) string { return "" }, false, "hello", http.StatusForbidden},
+		{"token in query", nil, func(r *WebSocketResponse) string { return r.ResumeToken }, true, "hello", http.StatusForbidden},
+		{"too large", nil, func(r *WebSocketResponse) string { return r.ResumeToken }, false, strings.Repeat("a", 65537), http.StatusRequestEntityTooLarge},
+		{"bad message", &errs.Error{Code: errs.InvalidArgument, Message: "bad"}, func(r *WebSocketResponse) string { return r.ResumeToken }, false, "hello", http.StatusBadRequest},
+		{"handler failure", errors.New("db down"), func(r *WebSocketResponse) string { return r.ResumeToken }, false, "hello", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler()
+			h.err = tt.handlerErr
+			resp := n.NewWebSocket(h)
+
+			u := srv.URL + "/api/sse/" + resp.ClientID
+			if tt.query {
+				u += "?" + url.Values{"resume_token": {tt.token(resp)}}.Encode()
+			}
+			req, _ := http.NewRequest(http.MethodPost, u, strings.NewReader(tt.body))
+			if !tt.query {
+				req.Header.Set("X-Resume-Token", tt.token(resp))
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// portableHandler is a testHandler that other nodes can restore
+type portableHandler struct {
+	*testHandler
+}
+
+func (h portableHandler) Kind() string { return "test" }
+
+func (h portableHandler) State() ([]byte, error) { return nil, nil }
+
+func init() {
+	RegisterHandler("test", func(clientID string, _ []byte) (WebSocketHandler, error) {
+		return portableHandler{&testHandler{id: clientID}}, nil
+	})
+}
+
+// waitFor polls until cond holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestCluster(t *testing.T) {
+	bus := NewMemoryBus()
+	n1, n2 := NewNode(bus), NewNode(bus)
+	srv1, srv2 := serveNode(t, n1), serveNode(t, n2)
+
+	// Registering on one node registers the client on every node
+	resp := n1.NewWebSocket(portableHandler{newTestHandler()})
+	remote, err := n2.getClient(resp.ClientID)
+	if err != nil {
+		t.Fatalf("client not registered on the other node: %v", err)
+	}
+	if remote.handler == nil {
+		t.Fatal("handler not restored on the other node")
+	}
+	if err := n2.Join(resp.ClientID, "ch"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Events published on either node reach the socket on the first
+	c1, err := dial(t, srv1, resp.ClientID, resp.ResumeToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "attach on the other node", func() bool {
+		remote.mu.Lock()
+		defer remote.mu.Unlock()
+		return remote.owner == n1.ID() && remote.open
+	})
+	steps := []struct {
+		name    string
+		publish func() error
+		want    string
+	}{
+		{"sequenced event", func() error { return n2.BroadcastSequencedEvent([]byte("event 1"), "ch", 1) }, "event 1"},
+		{"channel event", func() error { return n1.BroadcastEvent([]byte("event"), "ch") }, "event"},
+		{"direct message", func() error { return n2.Send(resp.ClientID, []byte("direct")) }, "direct"},
+		{"broadcast", func() error { return n2.Broadcast([]byte("everyone")) }, "everyone"},
+	}
+	for _, step := range steps {
+		if err := step.publish(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := c1.next(t); got != step.want {
+			t.Errorf("%s: got %q, want %q", step.name, got, step.want)
+		}
+	}
+
+	// Events published while detached are replayed when the client resumes
+	// on the other node, without duplicates from the first node's unread buffer
+	c1.Close()
+	waitFor(t, "detach", func() bool {
+		remote.mu.Lock()
+		defer remote.mu.Unlock()
+		return !remote.open
+	})
+	if err := n1.BroadcastSequencedEvent([]byte("event 2"), "ch", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := n1.BroadcastSequencedEvent([]byte("event 3"), "ch", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dial(t, srv2, resp.ClientID, "wrong", "ch:1"); err == nil {
+		t.Fatal("resumed with a wrong token")
+	}
+	c2, err := dial(t, srv2, resp.ClientID, resp.ResumeToken, "ch:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"event 2", "event 3"} {
+		if got := c2.next(t); got != want {
+			t.Errorf("resumed: got %q, want %q", got, want)
+		}
+	}
+	select {
+	case msg := <-c2.messages:
+		t.Errorf("resumed: unexpected duplicate %q", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
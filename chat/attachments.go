@@ -0,0 +1,426 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"encore.dev/rlog"
+	"encore.dev/storage/objects"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"encore.app/chat/types"
+	llmtypes "encore.app/llm/types"
+)
+
+// attachmentsBucket stores uploaded attachments and their thumbnails
+var attachmentsBucket = objects.NewBucket("chat-attachments", objects.BucketConfig{})
+
+// allowedContentTypes lists the content types accepted for upload
+var allowedContentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// attachmentURLTTL is how long signed attachment URLs handed to providers stay valid
+const attachmentURLTTL = time.Hour
+
+// attachmentColumns lists the columns selected by queries that read attachments
+const attachmentColumns = `id, message_id, user_id, filename, content_type,
+			size, width, height, thumbnail_key, created_at`
+
+// scanAttachment scans a row selected with attachmentColumns
+func scanAttachment(row rowScanner) (*types.Attachment, error) {
+	var a types.Attachment
+	var messageID, thumbnailKey sql.NullString
+	var width, height sql.NullInt64
+	err := row.Scan(
+		&a.ID, &messageID, &a.UserID, &a.Filename, &a.ContentType,
+		&a.Size, &width, &height, &thumbnailKey, &a.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	a.MessageID = messageID.String
+	a.Width = int(width.Int64)
+	a.Height = int(height.Int64)
+	a.URL = fmt.Sprintf("/api/chat/attachments/%s", a.ID)
+	if thumbnailKey.Valid {
+		a.ThumbnailURL = fmt.Sprintf("/api/chat/attachments/%s/thumbnail", a.ID)
+	}
+	return &a, nil
+}
+
+// loadAttachments retrieves the attachments of the given messages, keyed by message ID
+func (s *Service) loadAttachments(ctx context.Context, messageIDs []string) (map[string][]*types.Attachment, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM attachments
+		WHERE message_id = ANY($1)
+		ORDER BY created_at ASC
+	`, pq.Array(messageIDs))
+	if err != nil {
+		return nil, fmt.Errorf("query attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := make(map[string][]*types.Attachment)
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan attachment: %w", err)
+		}
+		attachments[a.MessageID] = append(attachments[a.MessageID], a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// attachToMessage links attachments uploaded by a user to their new message and returns the message type
+func attachToMessage(ctx context.Context, tx *sql.Tx, messageID, userID string, attachments []*types.Attachment) (string, error) {
+	msgType := "image"
+	for _, a := range attachments {
+		var contentType string
+		err := tx.QueryRowContext(ctx, `
+			UPDATE attachments SET message_id = $1
+			WHERE id = $2 AND user_id = $3 AND message_id IS NULL
+			RETURNING content_type
+		`, messageID, a.ID, userID).Scan(&contentType)
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("attachment not found or already attached: %s", a.ID)
+		} else if err != nil {
+			return "", fmt.Errorf("attach %s: %w", a.ID, err)
+		}
+		if !strings.HasPrefix(contentType, "image/") {
+			msgType = "file"
+		}
+	}
+	return msgType, nil
+}
+
+// imageInputs returns signed URLs for the image attachments of a message
+func imageInputs(ctx context.Context, msg *types.Message) ([]llmtypes.Image, error) {
+	var images []llmtypes.Image
+	for _, a := range msg.Attachments {
+		if !a.IsImage() {
+			continue
+		}
+		signed, err := attachmentsBucket.SignedDownloadURL(ctx, objectKey(a.ID), objects.WithTTL(attachmentURLTTL))
+		if err != nil {
+			return nil, fmt.Errorf("sign attachment url: %w", err)
+		}
+		images = append(images, llmtypes.Image{
+			URL:         signed.URL,
+			ContentType: a.ContentType,
+		})
+	}
+	return images, nil
+}
+
+// objectKey returns the bucket key of an attachment
+func objectKey(id string) string {
+	return id + "/original"
+}
+
+// thumbnailKey returns the bucket key of an attachment's thumbnail
+func thumbnailKey(id string) string {
+	return id + "/thumbnail"
+}
+
+// UploadAttachment accepts a multipart file upload and stores it for use in
+// one of the uploading user's messages
+//
+//encore:api auth raw method=POST path=/api/chat/attachments
+func (s *Service) UploadAttachment(w http.ResponseWriter, req *http.Request) {
+	userID, err := currentUser()
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	maxSize := int64(cfg.MaxFileSize)
+	if cfg.MaxImageSize > cfg.MaxFileSize {
+		maxSize = int64(cfg.MaxImageSize)
+	}
+
+	// Leave room for the multipart envelope around the file
+	req.Body = http.MaxBytesReader(w, req.Body, maxSize+1<<20)
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		if status := uploadErrorStatus(err); status == http.StatusRequestEntityTooLarge {
+			http.Error(w, "Upload too large", status)
+		} else {
+			http.Error(w, "Malformed upload", status)
+		}
+		return
+	}
+	defer req.MultipartForm.RemoveAll()
+
+	file, header, err := req.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+
+	// Detect the content type from the data rather than trusting the client
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if !allowedContentTypes[contentType] {
+		http.Error(w, fmt.Sprintf("Content type not allowed: %s", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	limit := int64(cfg.MaxFileSize)
+	if strings.HasPrefix(contentType, "image/") {
+		limit = int64(cfg.MaxImageSize)
+	}
+	if int64(len(data)) > limit {
+		http.Error(w, fmt.Sprintf("File exceeds %d bytes", limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if strings.HasPrefix(contentType, "image/") {
+		if err := checkImageSize(data, cfg.MaxImagePixels); errors.Is(err, errImageTooLarge) {
+			http.Error(w, fmt.Sprintf("Image exceeds %d pixels", cfg.MaxImagePixels), http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	attachment, err := s.storeAttachment(req.Context(), userID, filepath.Base(header.Filename), contentType, data)
+	if err != nil {
+		rlog.Error("Failed to store attachment", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(attachment); err != nil {
+		rlog.Error("Failed to encode response", "error", err)
+	}
+}
+
+// uploadErrorStatus returns the HTTP status for an error parsing an upload:
+// 413 if it exceeded the size limit, 400 if it was malformed
+func uploadErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// storeAttachment uploads an attachment, and a thumbnail for images, and records it
+func (s *Service) storeAttachment(ctx context.Context, userID, filename, contentType string, data []byte) (*types.Attachment, error) {
+	id := fmt.Sprintf("att_%s", uuid.New().String())
+
+	if err := uploadObject(ctx, objectKey(id), contentType, data); err != nil {
+		return nil, fmt.Errorf("upload attachment: %w", err)
+	}
+
+	var width, height sql.NullInt64
+	var thumbKey sql.NullString
+	if strings.HasPrefix(contentType, "image/") {
+		thumb, thumbType, bounds, err := makeThumbnail(data, cfg.ThumbnailSize, cfg.MaxImagePixels)
+		if err != nil {
+			// Formats the standard library cannot decode are stored without a thumbnail
+			rlog.Warn("could not create thumbnail", "attachment_id", id, "error", err)
+		} else {
+			if err := uploadObject(ctx, thumbnailKey(id), thumbType, thumb); err != nil {
+				return nil, fmt.Errorf("upload thumbnail: %w", err)
+			}
+			width = sql.NullInt64{Int64: int64(bounds.Dx()), Valid: true}
+			height = sql.NullInt64{Int64: int64(bounds.Dy()), Valid: true}
+			thumbKey = sql.NullString{String: thumbnailKey(id), Valid: true}
+		}
+	}
+
+	query := `
+		INSERT INTO attachments (
+			id, user_id, filename, content_type, size,
+			width, height, object_key, thumbnail_key, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING ` + attachmentColumns
+	return scanAttachment(s.DB.QueryRowContext(ctx, query,
+		id, userID, filename, contentType, len(data),
+		width, height, objectKey(id), thumbKey, time.Now(),
+	))
+}
+
+// uploadObject writes data to the attachments bucket
+func uploadObject(ctx context.Context, key, contentType string, data []byte) error {
+	w := attachmentsBucket.Upload(ctx, key, objects.WithUploadAttrs(objects.UploadAttrs{
+		ContentType: contentType,
+	}))
+	if _, err := w.Write(data); err != nil {
+		w.Abort(err)
+		return err
+	}
+	return w.Close()
+}
+
+// errImageTooLarge is returned for images with more pixels than allowed
+var errImageTooLarge = errors.New("image too large")
+
+// checkImageSize reads an image's dimensions from its header, without
+// decoding it, and rejects images over maxPixels. A small file can declare
+// dimensions that would exhaust memory once decoded.
+func checkImageSize(data []byte, maxPixels int) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode image config: %w", err)
+	}
+	if int64(config.Width)*int64(config.Height) > int64(maxPixels) {
+		return fmt.Errorf("%w: %dx%d", errImageTooLarge, config.Width, config.Height)
+	}
+	return nil
+}
+
+// makeThumbnail scales an image down to fit within size x size pixels,
+// refusing images over maxPixels. It returns the encoded thumbnail, its
+// content type and the original image bounds.
+func makeThumbnail(data []byte, size, maxPixels int) ([]byte, string, image.Rectangle, error) {
+	if err := checkImageSize(data, maxPixels); err != nil {
+		return nil, "", image.Rectangle{}, err
+	}
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", image.Rectangle{}, fmt.Errorf("decode image: %w", err)
+	}
+	bounds := src.Bounds()
+
+	// Preserve the aspect ratio, never scaling up
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/bounds.Dx())
+		} else {
+			w, h = max(1, w*size/bounds.Dy()), size
+		}
+	}
+
+	// Nearest-neighbour sampling keeps this dependency free
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := bounds.Min.Y + y*bounds.Dy()/h
+		for x := 0; x < w; x++ {
+			sx := bounds.Min.X + x*bounds.Dx()/w
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80})
+		return buf.Bytes(), "image/jpeg", bounds, err
+	}
+	err = png.Encode(&buf, dst)
+	return buf.Bytes(), "image/png", bounds, err
+}
+
+// ServeAttachment downloads an attachment or its thumbnail. Attachments are
+// visible to their uploader and to members of the conversation they were sent in.
+//
+//encore:api auth raw method=GET path=/api/chat/attachments/*path
+func (s *Service) ServeAttachment(w http.ResponseWriter, req *http.Request) {
+	userID, err := currentUser()
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(req.URL.Path, "/api/chat/attachments/"), "/")
+	id, variant, _ := strings.Cut(path, "/")
+	if id == "" || (variant != "" && variant != "thumbnail") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	var filename, contentType, uploader string
+	var key, thumbKey, conversationID sql.NullString
+	err = s.DB.QueryRowContext(req.Context(), `
+		SELECT a.filename, a.content_type, a.object_key, a.thumbnail_key,
+			a.user_id, m.conversation_id
+		FROM attachments a
+		LEFT JOIN messages m ON m.id = a.message_id
+		WHERE a.id = $1
+	`, id).Scan(&filename, &contentType, &key, &thumbKey, &uploader, &conversationID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		rlog.Error("Failed to get attachment", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if uploader != userID {
+		role := ""
+		if conversationID.Valid {
+			role, err = s.memberRole(req.Context(), conversationID.String, userID)
+			if err != nil {
+				rlog.Error("Failed to check attachment access", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if role == "" {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	if variant == "thumbnail" {
+		if !thumbKey.Valid {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		key = thumbKey
+		contentType = "application/octet-stream"
+	}
+
+	attrs, err := attachmentsBucket.Attrs(req.Context(), key.String)
+	if errors.Is(err, objects.ErrObjectNotFound) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		rlog.Error("Failed to get attachment attributes", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if attrs.ContentType != "" {
+		contentType = attrs.ContentType
+	}
+
+	r := attachmentsBucket.Download(req.Context(), key.String)
+	defer r.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, r); err != nil {
+		rlog.Error("Failed to stream attachment", "error", err)
+	}
+}
@@ -0,0 +1,106 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testPNG encodes a blank PNG, then rewrites its header to declare the given
+// dimensions, as a decompression bomb would
+func testPNG(t *testing.T, w, h, declaredW, declaredH int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// The IHDR chunk follows the 8 byte signature: length, type, data, CRC
+	ihdr := data[8+8 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(declaredW))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(declaredH))
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+	return data
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		maxPixels int
+		wantSize  image.Point
+		wantErr   error
+	}{
+		{"scales down wide", testPNG(t, 100, 50, 100, 50), 1 << 20, image.Pt(10, 5), nil},
+		{"scales down tall", testPNG(t, 50, 100, 50, 100), 1 << 20, image.Pt(5, 10), nil},
+		{"never scales up", testPNG(t, 4, 4, 4, 4), 1 << 20, image.Pt(4, 4), nil},
+		{"rejects too many pixels", testPNG(t, 100, 50, 100, 50), 4999, image.Point{}, errImageTooLarge},
+		{"rejects declared bomb", testPNG(t, 1, 1, 100000, 100000), 1 << 20, image.Point{}, errImageTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumb, contentType, _, err := makeThumbnail(tt.data, 10, tt.maxPixels)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if contentType != "image/png" {
+				t.Errorf("content type = %q, want image/png", contentType)
+			}
+			img, err := png.Decode(bytes.NewReader(thumb))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := img.Bounds().Size(); got != tt.wantSize {
+				t.Errorf("size = %v, want %v", got, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestUploadErrorStatus(t *testing.T) {
+	var large bytes.Buffer
+	mw := multipart.NewWriter(&large)
+	fw, _ := mw.CreateFormFile("file", "large.txt")
+	fw.Write(bytes.Repeat([]byte("a"), 4096))
+	mw.Close()
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        int
+	}{
+		{"too large", large.String(), mw.FormDataContentType(), http.StatusRequestEntityTooLarge},
+		{"not multipart", "file=x", "application/x-www-form-urlencoded", http.StatusBadRequest},
+		{"malformed multipart", "--nope\r\n", "multipart/form-data; boundary=b", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/chat/attachments", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Body = http.MaxBytesReader(w, req.Body, 1024)
+			err := req.ParseMultipartForm(512)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := uploadErrorStatus(err); got != tt.want {
+				t.Errorf("status = %d, want %d (error %v)", got, tt.want, err)
+			}
+		})
+	}
+}
@@ -31,7 +31,7 @@ type BroadcastRequest struct {
 
 // Broadcast sends a message to all connected WebSocket clients
 //
-//encore:api public method=POST path=/api/chat/broadcast
+//encore:api private method=POST path=/api/chat/broadcast
 func (s *Service) Broadcast(ctx context.Context, req *BroadcastRequest) error {
 	event := req.Event
 
@@ -39,6 +39,9 @@ func (s *Service) Broadcast(ctx context.Context, req *BroadcastRequest) error {
 	if event.EventID == "" {
 		event.EventID = fmt.Sprintf("evt_%s", uuid.New().String())
 	}
+	if event.Version == 0 {
+		event.Version = types.SchemaVersion
+	}
 	if event.Timestamp.IsZero() {
 		event.Timestamp = time.Now()
 	}
@@ -66,7 +69,7 @@ func initService() (*Service, error) {
 
 // CreateBot creates a new bot profile
 //
-//encore:api public method=POST path=/api/chat/bots
+//encore:api auth method=POST path=/api/chat/bots
 func (s *Service) CreateBot(ctx context.Context, bot *types.Bot) (*types.Bot, error) {
 	if bot.ID == "" {
 		bot.ID = fmt.Sprintf("bot_%s", uuid.New().String())
@@ -185,10 +188,18 @@ func (s *Service) ListBots(ctx context.Context) (*ListBotsResponse, error) {
 	return &ListBotsResponse{Bots: bots}, nil
 }
 
-// GetConversation retrieves a conversation by ID
+// GetConversation retrieves a conversation the user is a member of
 //
-//encore:api public method=GET path=/api/chat/conversations/:id
+//encore:api auth method=GET path=/api/chat/conversations/:id
 func (s *Service) GetConversation(ctx context.Context, id string) (*types.Conversation, error) {
+	if _, _, err := s.authorizeConversation(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.getConversation(ctx, id)
+}
+
+// getConversation retrieves a conversation without checking access
+func (s *Service) getConversation(ctx context.Context, id string) (*types.Conversation, error) {
 	query := `
 		SELECT id, channel_id, platform, bot_ids,
 			created_at, updated_at
@@ -213,17 +224,24 @@ type ListConversationsResponse struct {
 	Conversations []*types.Conversation `json:"conversations"`
 }
 
-// ListConversations retrieves all conversations
+// ListConversations retrieves the conversations the user is a member of
 //
-//encore:api public method=GET path=/api/chat/conversations
+//encore:api auth method=GET path=/api/chat/conversations
 func (s *Service) ListConversations(ctx context.Context) (*ListConversationsResponse, error) {
+	userID, err := currentUser()
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, channel_id, platform, bot_ids,
-			created_at, updated_at
-		FROM conversations
-		ORDER BY updated_at DESC
+		SELECT c.id, c.channel_id, c.platform, c.bot_ids,
+			c.created_at, c.updated_at
+		FROM conversations c
+		JOIN conversation_members cm ON cm.conversation_id = c.id
+		WHERE cm.user_id = $1
+		ORDER BY c.updated_at DESC
 	`
-	rows, err := s.DB.QueryContext(ctx, query)
+	rows, err := s.DB.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("list conversations: %w", err)
 	}
@@ -245,64 +263,128 @@ func (s *Service) ListConversations(ctx context.Context) (*ListConversationsResp
 	return &ListConversationsResponse{Conversations: conversations}, nil
 }
 
+// ListMessagesParams represents the parameters for listing conversation messages
+type ListMessagesParams struct {
+	Before string `query:"before"` // only messages created before this message
+	Limit  int    `query:"limit"`  // only the most recent messages, or all if 0
+}
+
 // ListMessagesResponse represents the response for listing conversation messages
 type ListMessagesResponse struct {
 	Messages []*types.Message `json:"messages"`
 }
 
-// ListConversationMessages retrieves messages for a conversation
+// ListConversationMessages retrieves messages for a conversation the user is a
+// member of, oldest first. Use before and limit to page back through history.
 //
-//encore:api public method=GET path=/api/chat/conversations/:id/messages
-func (s *Service) ListConversationMessages(ctx context.Context, id string) (*ListMessagesResponse, error) {
-	// First check if conversation exists
-	var exists bool
-	err := s.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM conversations WHERE id = $1)", id).Scan(&exists)
-	if err != nil {
-		return nil, fmt.Errorf("check conversation existence: %w", err)
+//encore:api auth method=GET path=/api/chat/conversations/:id/messages
+func (s *Service) ListConversationMessages(ctx context.Context, id string, params *ListMessagesParams) (*ListMessagesResponse, error) {
+	if _, _, err := s.authorizeConversation(ctx, id); err != nil {
+		return nil, err
 	}
-	if !exists {
-		return nil, fmt.Errorf("conversation not found: %s", id)
+	if params.Limit < 0 {
+		return nil, fmt.Errorf("invalid limit: %d", params.Limit)
+	}
+
+	args := []any{id}
+	where := "m.conversation_id = $1"
+	if params.Before != "" {
+		args = append(args, params.Before)
+		where += ` AND m.created_at < (
+			SELECT created_at FROM messages WHERE id = $2 AND conversation_id = $1
+		)`
+	}
+	limit := ""
+	if params.Limit > 0 {
+		args = append(args, params.Limit)
+		limit = fmt.Sprintf("LIMIT $%d", len(args))
 	}
 
+	// Take the most recent messages, then return them oldest first
 	query := `
-		SELECT m.id, m.conversation_id, c.channel_id, c.platform,
-			m.user_id, m.bot_id, m.content, m.type, m.created_at
-		FROM messages m
-		JOIN conversations c ON c.id = m.conversation_id
-		WHERE m.conversation_id = $1
-		ORDER BY m.created_at ASC
+		SELECT * FROM (
+			SELECT ` + messageColumns + `
+			FROM messages m
+			JOIN conversations c ON c.id = m.conversation_id
+			WHERE ` + where + `
+			ORDER BY m.created_at DESC
+			` + limit + `
+		) page
+		ORDER BY created_at ASC
 	`
-	rows, err := s.DB.QueryContext(ctx, query, id)
+	messages, err := s.queryMessages(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("list messages query error: %w", err)
+		return nil, fmt.Errorf("list messages: %w", err)
 	}
-	defer rows.Close()
 
-	var messages []*types.Message
-	for rows.Next() {
-		var msg types.Message
-		var botID sql.NullString // Use sql.NullString to handle NULL bot_id
-		err := rows.Scan(
-			&msg.ID, &msg.ConversationID, &msg.ChannelID,
-			&msg.Platform, &msg.UserID, &botID,
-			&msg.Content, &msg.Type, &msg.CreatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("scan message: %w", err)
-		}
-		if botID.Valid {
-			msg.BotID = botID.String
+	return &ListMessagesResponse{Messages: messages}, nil
+}
+
+// SendMessage sends a chat message as the authenticated user. Omitting the
+// conversation starts a new one owned by the user; otherwise the user must be
+// a member. Sends that set client_message_id are idempotent: a retry returns
+// the original message and triggers no new replies.
+//
+//encore:api auth method=POST path=/api/chat/messages
+func (s *Service) SendMessage(ctx context.Context, msg *types.Message) (*types.Message, error) {
+	userID, err := currentUser()
+	if err != nil {
+		return nil, err
+	}
+	if msg.ConversationID != "" {
+		if _, _, err := s.authorizeConversation(ctx, msg.ConversationID); err != nil {
+			return nil, err
 		}
-		messages = append(messages, &msg)
 	}
 
-	return &ListMessagesResponse{Messages: messages}, nil
+	// Users post as themselves, never as a bot
+	msg.UserID = userID
+	msg.BotID = ""
+	clearServerFields(msg)
+
+	return s.sendMessage(ctx, msg)
 }
 
-// SendMessage sends a chat message
+// IngestMessage sends a message received by a platform adapter on behalf of
+// a platform user, who becomes a member of the conversation
 //
-//encore:api public method=POST path=/api/chat/messages
-func (s *Service) SendMessage(ctx context.Context, msg *types.Message) (*types.Message, error) {
+//encore:api private method=POST path=/api/chat/ingest
+func (s *Service) IngestMessage(ctx context.Context, msg *types.Message) (*types.Message, error) {
+	if msg.UserID == "" {
+		return nil, fmt.Errorf("user_id is required")
+	}
+	msg.BotID = ""
+	clearServerFields(msg)
+
+	return s.sendMessage(ctx, msg)
+}
+
+// clearServerFields drops the fields of a user message that only the server
+// may set: its ID, and the bot request it would otherwise pose as the reply to
+func clearServerFields(msg *types.Message) {
+	msg.ID = ""
+	msg.RequestID = ""
+	msg.ReplyToID = ""
+}
+
+// sendMessage stores and publishes a user message
+func (s *Service) sendMessage(ctx context.Context, msg *types.Message) (*types.Message, error) {
+	msg, err := s.storeMessage(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Publish even for a retry, in case the first attempt failed to. The event
+	// ID is derived from the message, so consumers see the same event again.
+	if err := publishMessageEvent(ctx, types.EventMessage, msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// storeMessage stores a message, creating its conversation if needed
+func (s *Service) storeMessage(ctx context.Context, msg *types.Message) (*types.Message, error) {
 	if msg.ID == "" {
 		msg.ID = fmt.Sprintf("msg_%s", uuid.New().String())
 	}
@@ -314,6 +396,13 @@ func (s *Service) SendMessage(ctx context.Context, msg *types.Message) (*types.M
 	}
 	msg.CreatedAt = time.Now()
 
+	// A retried send returns the message stored by the first attempt
+	if dup, err := s.findDuplicate(ctx, msg); err != nil {
+		return nil, fmt.Errorf("check duplicate message: %w", err)
+	} else if dup != nil {
+		return dup, nil
+	}
+
 	// Start transaction
 	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
@@ -368,6 +457,13 @@ func (s *Service) SendMessage(ctx context.Context, msg *types.Message) (*types.M
 			return nil, fmt.Errorf("create conversation: %w", err)
 		}
 		msg.ConversationID = conv.ID
+
+		// The user who starts a conversation owns it
+		if msg.BotID == "" {
+			if err := addMember(ctx, tx, conv.ID, msg.UserID, types.RoleOwner); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Validate conversation exists
@@ -380,20 +476,69 @@ func (s *Service) SendMessage(ctx context.Context, msg *types.Message) (*types.M
 		return nil, fmt.Errorf("conversation not found: %s", msg.ConversationID)
 	}
 
-	// Store message
+	// Validate thread parent belongs to the same conversation
+	if msg.ParentID != "" {
+		err = tx.QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND conversation_id = $2 AND deleted_at IS NULL)",
+			msg.ParentID, msg.ConversationID,
+		).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("check parent message existence: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("parent message not found: %s", msg.ParentID)
+		}
+	}
+
+	// Store message, leaving it to a concurrent attempt with the same dedupe key
 	query := `
 		INSERT INTO messages (
-			id, conversation_id, user_id, bot_id,
-			content, type, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+			id, conversation_id, user_id, bot_id, parent_id, reply_to_id,
+			client_message_id, request_id, content, type, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT DO NOTHING
 	`
-	_, err = tx.ExecContext(ctx, query,
+	res, err := tx.ExecContext(ctx, query,
 		msg.ID, msg.ConversationID, msg.UserID,
-		nil, msg.Content, msg.Type, msg.CreatedAt, // Set bot_id to nil for user messages
+		nullString(msg.BotID), nullString(msg.ParentID), nullString(msg.ReplyToID),
+		nullString(msg.ClientMessageID), nullString(msg.RequestID),
+		msg.Content, msg.Type, msg.CreatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("store message: %w", err)
 	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("store message: %w", err)
+	} else if n == 0 {
+		tx.Rollback()
+		dup, err := s.findDuplicate(ctx, msg)
+		if err != nil {
+			return nil, fmt.Errorf("check duplicate message: %w", err)
+		}
+		if dup == nil {
+			return nil, fmt.Errorf("message already exists: %s", msg.ID)
+		}
+		return dup, nil
+	}
+
+	// Posting in a conversation makes the sender a member
+	if msg.BotID == "" {
+		if err := addMember(ctx, tx, msg.ConversationID, msg.UserID, types.RoleMember); err != nil {
+			return nil, err
+		}
+	}
+
+	// Link uploaded attachments, typing the message by what it carries
+	if len(msg.Attachments) > 0 {
+		msgType, err := attachToMessage(ctx, tx, msg.ID, msg.UserID, msg.Attachments)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE messages SET type = $1 WHERE id = $2", msgType, msg.ID)
+		if err != nil {
+			return nil, fmt.Errorf("update message type: %w", err)
+		}
+	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
@@ -401,42 +546,12 @@ func (s *Service) SendMessage(ctx context.Context, msg *types.Message) (*types.M
 	}
 
 	// Get the complete message details from database
-	query = `
-		SELECT m.id, m.conversation_id, c.channel_id, c.platform,
-			m.user_id, m.bot_id, m.content, m.type, m.created_at
-		FROM messages m
-		JOIN conversations c ON c.id = m.conversation_id
-		WHERE m.id = $1
-	`
-	var botID sql.NullString
-	err = s.DB.QueryRowContext(ctx, query, msg.ID).Scan(
-		&msg.ID, &msg.ConversationID, &msg.ChannelID,
-		&msg.Platform, &msg.UserID, &botID,
-		&msg.Content, &msg.Type, &msg.CreatedAt,
-	)
+	stored, err := s.getMessage(ctx, msg.ID)
 	if err != nil {
 		return nil, fmt.Errorf("get message details: %w", err)
 	}
-	if botID.Valid {
-		msg.BotID = botID.String
-	}
-
-	// Publish chat event
-	event := &types.ChatEvent{
-		EventID:   fmt.Sprintf("evt_%s", uuid.New().String()),
-		Type:      "message",
-		Platform:  msg.Platform,
-		ChannelID: msg.ChannelID,
-		Message:   msg,
-		Timestamp: msg.CreatedAt,
-	}
-
-	_, err = chatpubsub.ChatEvents.Publish(ctx, event)
-	if err != nil {
-		return nil, fmt.Errorf("publish chat event: %w", err)
-	}
 
-	return msg, nil
+	return stored, nil
 }
 
 // Initialize subscriptions
@@ -450,17 +565,31 @@ var _ = pubsub.NewSubscription(
 // processChatEvent handles incoming chat events
 func (s *Service) processChatEvent(ctx context.Context, event *types.ChatEvent) error {
 	// Only process user messages, not bot messages
-	if event.Type != "message" || event.Message == nil || event.Message.BotID != "" {
+	if event.Type != types.EventMessage || event.Message == nil || event.Message.BotID != "" {
 		return nil
 	}
 
+	// Skip redelivered events
+	if done, err := s.isProcessed(ctx, "process-chat-event", event.EventID); err != nil || done {
+		return err
+	}
+
+	if err := s.generateReplies(ctx, event.Message); err != nil {
+		return err
+	}
+
+	return s.markProcessed(ctx, "process-chat-event", event.EventID)
+}
+
+// generateReplies requests a reply to a user message from every bot in the conversation
+func (s *Service) generateReplies(ctx context.Context, msg *types.Message) error {
 	// Get conversation bots
 	query := `
 		SELECT bot_ids FROM conversations
 		WHERE id = $1
 	`
 	var botIDs []string
-	err := s.DB.QueryRowContext(ctx, query, event.Message.ConversationID).Scan(pq.Array(&botIDs))
+	err := s.DB.QueryRowContext(ctx, query, msg.ConversationID).Scan(pq.Array(&botIDs))
 	if err != nil {
 		return fmt.Errorf("get conversation bots: %w", err)
 	}
@@ -476,44 +605,52 @@ func (s *Service) processChatEvent(ctx context.Context, event *types.ChatEvent)
 		messages := []llmtypes.Message{
 			{
 				Role:    "system",
-				Content: fmt.Sprintf("You are %s. Respond in character.", bot.Persona),
+				Content: systemPrompt(bot),
 			},
 			{
 				Role:    "user",
-				Content: event.Message.Content,
+				Content: msg.Content,
 			},
 		}
 
+		// Pass images along to bots that can see them
+		if bot.Parameters != nil && bot.Parameters.Vision {
+			images, err := imageInputs(ctx, msg)
+			if err != nil {
+				return fmt.Errorf("prepare images: %w", err)
+			}
+			messages[1].Images = images
+		}
+
 		params := llmtypes.Parameters{
 			MaxTokens:   bot.Parameters.MaxTokens,
 			Temperature: bot.Parameters.Temperature,
 		}
 
+		// Reuse the bot's current generation for this message, if any, so a
+		// retry resends the same request instead of asking for a second reply
+		requestID, answered, err := s.currentGeneration(ctx, msg.ID, botID)
+		if err != nil {
+			return err
+		}
+		if answered {
+			continue
+		}
+
 		req := &llmtypes.LLMRequestEvent{
-			RequestID:      fmt.Sprintf("req_%s", uuid.New().String()),
+			RequestID:      requestID,
 			BotID:          botID,
-			ChannelID:      event.ChannelID,
-			ConversationID: event.Message.ConversationID,
+			ChannelID:      msg.ChannelID,
+			ConversationID: msg.ConversationID,
 			Provider:       bot.Provider,
 			Messages:       messages,
 			Parameters:     params,
 			Timestamp:      time.Now(),
 		}
 
-		// Send typing indicator
-		typingEvent := &types.ChatEvent{
-			EventID:   fmt.Sprintf("evt_%s", uuid.New().String()),
-			Type:      "typing",
-			Platform:  event.Platform,
-			ChannelID: event.ChannelID,
-			Message: &types.Message{
-				BotID: botID,
-			},
-			Timestamp: time.Now(),
-		}
 		// Send typing indicator to platform
-		if err := s.Broadcast(ctx, &BroadcastRequest{Event: *typingEvent}); err != nil {
-			return fmt.Errorf("broadcast typing event: %w", err)
+		if err := publishBotTyping(ctx, msg, botID, true); err != nil {
+			return err
 		}
 
 		// Send request to LLM service
@@ -525,6 +662,36 @@ func (s *Service) processChatEvent(ctx context.Context, event *types.ChatEvent)
 	return nil
 }
 
+// systemPrompt returns the system message that puts a bot in character
+func systemPrompt(bot *types.Bot) string {
+	return fmt.Sprintf("You are %s. Respond in character.", bot.Persona)
+}
+
+// currentGeneration returns the request ID of the bot's current generation for
+// a message, creating one if needed, and whether it has already been answered
+func (s *Service) currentGeneration(ctx context.Context, messageID, botID string) (string, bool, error) {
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO generations (request_id, message_id, bot_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (message_id, bot_id) WHERE superseded_at IS NULL DO NOTHING
+	`, fmt.Sprintf("req_%s", uuid.New().String()), messageID, botID, time.Now())
+	if err != nil {
+		return "", false, fmt.Errorf("store generation: %w", err)
+	}
+
+	var requestID string
+	var answered bool
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT g.request_id, EXISTS(SELECT 1 FROM messages r WHERE r.request_id = g.request_id AND r.bot_id = g.bot_id)
+		FROM generations g
+		WHERE g.message_id = $1 AND g.bot_id = $2 AND g.superseded_at IS NULL
+	`, messageID, botID).Scan(&requestID, &answered)
+	if err != nil {
+		return "", false, fmt.Errorf("get generation: %w", err)
+	}
+	return requestID, answered, nil
+}
+
 // Initialize subscription for LLM responses
 var _ = pubsub.NewSubscription(
 	llmpubsub.GenerationResponses, "handle-llm-response",
@@ -539,6 +706,11 @@ func (s *Service) handleLLMResponse(ctx context.Context, resp *llmtypes.LLMRespo
 		return fmt.Errorf("llm error: %s", resp.Error)
 	}
 
+	// Skip redelivered responses
+	if done, err := s.isProcessed(ctx, "handle-llm-response", resp.RequestID); err != nil || done {
+		return err
+	}
+
 	// Get conversation details
 	query := `
 		SELECT c.id, c.channel_id, c.platform
@@ -559,23 +731,53 @@ func (s *Service) handleLLMResponse(ctx context.Context, resp *llmtypes.LLMRespo
 		return fmt.Errorf("get conversation: %w", err)
 	}
 
-	// Create bot message
+	// Look up the user message this response answers, if it is still current
+	var replyToID, parentID sql.NullString
+	var superseded bool
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT m.id, m.parent_id, g.superseded_at IS NOT NULL
+		FROM generations g
+		JOIN messages m ON m.id = g.message_id
+		WHERE g.request_id = $1 AND m.deleted_at IS NULL
+	`, resp.RequestID).Scan(&replyToID, &parentID, &superseded)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("get generation: %w", err)
+	}
+
+	// The message was regenerated after this request was sent
+	if superseded {
+		return s.markProcessed(ctx, "handle-llm-response", resp.RequestID)
+	}
+
+	// Create bot message, keeping it in the thread of the message it answers
 	msg := &types.Message{
 		ID:             fmt.Sprintf("msg_%s", uuid.New().String()),
 		ConversationID: conv.id,
 		ChannelID:      conv.channelID,
 		Platform:       conv.platform,
 		BotID:          resp.BotID,
+		ParentID:       parentID.String,
+		ReplyToID:      replyToID.String,
 		Content:        resp.Content,
+		RequestID:      resp.RequestID,
 		Type:           "text",
 		CreatedAt:      resp.OccurredAt(),
 	}
 
-	// Send message
-	_, err = s.SendMessage(ctx, msg)
+	// Store message; a redelivered response finds the reply already stored
+	msg, err = s.storeMessage(ctx, msg)
 	if err != nil {
-		return fmt.Errorf("send bot message: %w", err)
+		return fmt.Errorf("store bot message: %w", err)
 	}
 
-	return nil
+	if err := publishResponse(ctx, types.EventMessage, msg, resp.RequestID); err != nil {
+		return err
+	}
+
+	// The bot has finished typing
+	if err := publishBotTyping(ctx, msg, resp.BotID, false); err != nil {
+		return err
+	}
+
+	return s.markProcessed(ctx, "handle-llm-response", resp.RequestID)
 }
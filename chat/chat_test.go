@@ -0,0 +1,107 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"encore.app/chat/types"
+)
+
+func TestSendMessageServerFields(t *testing.T) {
+	ctx := context.Background()
+
+	// A bot reply and a pending generation in another user's conversation
+	s, _, _, victim := newConversation(t)
+	reply := storeReply(t, s, victim)
+	pending := "req_" + uuid.New().String()
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO generations (request_id, message_id, bot_id, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, pending, reply.ID, reply.BotID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		msg  types.Message
+	}{
+		{"forged id", types.Message{ID: reply.ID}},
+		{"forged request id", types.Message{RequestID: reply.RequestID}},
+		{"pending request id", types.Message{RequestID: pending}},
+		{"forged reply to", types.Message{ReplyToID: victim.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, attacker, _, own := newConversation(t)
+			as(attacker)
+			msg := tt.msg
+			msg.ConversationID = own.ConversationID
+			msg.Content = "mine"
+
+			got, err := s.SendMessage(ctx, &msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID == reply.ID || got.ID == victim.ID || got.Content != "mine" {
+				t.Errorf("got %+v, want a new message", got)
+			}
+			if got.RequestID != "" || got.ReplyToID != "" {
+				t.Errorf("stored request id %q and reply to %q, want none", got.RequestID, got.ReplyToID)
+			}
+
+			// The pending generation still waits for the bot's reply
+			_, answered, err := s.currentGeneration(ctx, reply.ID, reply.BotID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if answered {
+				t.Error("pending generation reported answered")
+			}
+		})
+	}
+}
+
+func TestFindDuplicate(t *testing.T) {
+	ctx := context.Background()
+	s, owner, _, first := newConversation(t)
+	reply := storeReply(t, s, first)
+	as(owner)
+	sent, err := s.SendMessage(ctx, &types.Message{ConversationID: first.ConversationID, ClientMessageID: "c1", Content: "once"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, other := newConversation(t)
+
+	tests := []struct {
+		name   string
+		msg    *types.Message
+		wantID string
+	}{
+		{"retried send", &types.Message{UserID: owner, ConversationID: first.ConversationID, ClientMessageID: "c1"}, sent.ID},
+		{"retried send starting a conversation", &types.Message{UserID: owner, ClientMessageID: "c1"}, sent.ID},
+		{"client id in another conversation", &types.Message{UserID: owner, ConversationID: other.ConversationID, ClientMessageID: "c1"}, ""},
+		{"client id of another user", &types.Message{UserID: "someone", ConversationID: first.ConversationID, ClientMessageID: "c1"}, ""},
+		{"redelivered bot reply", &types.Message{BotID: reply.BotID, ConversationID: first.ConversationID, RequestID: reply.RequestID}, reply.ID},
+		{"request id from a user", &types.Message{UserID: owner, ConversationID: first.ConversationID, RequestID: reply.RequestID}, ""},
+		{"request id in another conversation", &types.Message{BotID: reply.BotID, ConversationID: other.ConversationID, RequestID: reply.RequestID}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dup, err := s.findDuplicate(ctx, tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if dup != nil {
+				got = dup.ID
+			}
+			if got != tt.wantID {
+				t.Errorf("duplicate = %q, want %q", got, tt.wantID)
+			}
+		})
+	}
+}
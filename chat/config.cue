@@ -0,0 +1,6 @@
+package chat
+
+MaxImageSize: int | *10485760 // 10 MiB
+MaxFileSize: int | *26214400 // 25 MiB
+ThumbnailSize: int | *256
+MaxImagePixels: int | *40000000 // 40 megapixels
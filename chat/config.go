@@ -0,0 +1,12 @@
+package chat
+
+import "encore.dev/config"
+
+type Config struct {
+	MaxImageSize   int // maximum size of an uploaded image, in bytes
+	MaxFileSize    int // maximum size of any other uploaded file, in bytes
+	ThumbnailSize  int // maximum width and height of image thumbnails, in pixels
+	MaxImagePixels int // maximum width times height of an uploaded image
+}
+
+var cfg = config.Load[*Config]()
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS generations;
+DROP TABLE IF EXISTS message_reactions;
+DROP TABLE IF EXISTS message_edits;
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS edited_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS reply_to_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,36 @@
+-- Add thread, edit and deletion tracking to messages
+ALTER TABLE messages ADD COLUMN parent_id VARCHAR(255) REFERENCES messages(id);
+ALTER TABLE messages ADD COLUMN reply_to_id VARCHAR(255) REFERENCES messages(id);
+ALTER TABLE messages ADD COLUMN edited_at TIMESTAMP;
+ALTER TABLE messages ADD COLUMN deleted_at TIMESTAMP;
+
+-- Create message_edits table to keep the edit history of a message
+CREATE TABLE message_edits (
+    id SERIAL PRIMARY KEY,
+    message_id VARCHAR(255) NOT NULL REFERENCES messages(id),
+    content TEXT NOT NULL,
+    edited_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create message_reactions table
+CREATE TABLE message_reactions (
+    message_id VARCHAR(255) NOT NULL REFERENCES messages(id),
+    user_id VARCHAR(255) NOT NULL,
+    emoji VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, user_id, emoji)
+);
+
+-- Create generations table linking LLM requests to the message they answer
+CREATE TABLE generations (
+    request_id VARCHAR(255) PRIMARY KEY,
+    message_id VARCHAR(255) NOT NULL REFERENCES messages(id),
+    bot_id VARCHAR(255) NOT NULL REFERENCES bots(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_messages_parent ON messages(parent_id);
+CREATE INDEX idx_messages_reply_to ON messages(reply_to_id);
+CREATE INDEX idx_message_edits_message ON message_edits(message_id);
+CREATE INDEX idx_generations_message ON generations(message_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS attachments;
+ALTER TABLE messages DROP CONSTRAINT valid_message_type;
+ALTER TABLE messages ADD CONSTRAINT valid_message_type CHECK (type IN ('text', 'image'));
@@ -0,0 +1,21 @@
+-- Allow messages that carry non-image files
+ALTER TABLE messages DROP CONSTRAINT valid_message_type;
+ALTER TABLE messages ADD CONSTRAINT valid_message_type CHECK (type IN ('text', 'image', 'file'));
+
+-- Create attachments table
+CREATE TABLE attachments (
+    id VARCHAR(255) PRIMARY KEY,
+    message_id VARCHAR(255) REFERENCES messages(id),
+    user_id VARCHAR(255) NOT NULL,
+    filename TEXT NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    width INTEGER,
+    height INTEGER,
+    object_key TEXT NOT NULL,
+    thumbnail_key TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_attachments_message ON attachments(message_id);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS processed_events;
+
+DROP INDEX IF EXISTS idx_generations_current;
+ALTER TABLE generations DROP COLUMN superseded_at;
+
+ALTER TABLE messages DROP CONSTRAINT unique_request;
+ALTER TABLE messages DROP CONSTRAINT unique_client_message;
+ALTER TABLE messages DROP COLUMN request_id;
+ALTER TABLE messages DROP COLUMN client_message_id;
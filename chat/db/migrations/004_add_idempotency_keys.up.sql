@@ -0,0 +1,23 @@
+-- Dedupe keys: client-supplied IDs for user messages, LLM request IDs for bot replies
+ALTER TABLE messages ADD COLUMN client_message_id VARCHAR(255);
+ALTER TABLE messages ADD COLUMN request_id VARCHAR(255);
+ALTER TABLE messages ADD CONSTRAINT unique_client_message UNIQUE (user_id, client_message_id);
+ALTER TABLE messages ADD CONSTRAINT unique_request UNIQUE (request_id);
+
+-- Regenerating replies supersedes earlier generations of a message
+ALTER TABLE generations ADD COLUMN superseded_at TIMESTAMP;
+UPDATE generations g SET superseded_at = NOW()
+WHERE EXISTS (
+    SELECT 1 FROM generations n
+    WHERE n.message_id = g.message_id AND n.bot_id = g.bot_id AND n.created_at > g.created_at
+);
+CREATE UNIQUE INDEX idx_generations_current ON generations(message_id, bot_id)
+    WHERE superseded_at IS NULL;
+
+-- Create processed_events table recording events each subscription has handled
+CREATE TABLE processed_events (
+    subscription VARCHAR(255) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (subscription, event_id)
+);
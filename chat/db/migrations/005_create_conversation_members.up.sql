@@ -0,0 +1,18 @@
+-- Create conversation_members table controlling who can read and post in a conversation
+CREATE TABLE conversation_members (
+    conversation_id VARCHAR(255) NOT NULL REFERENCES conversations(id),
+    user_id VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (conversation_id, user_id),
+    CONSTRAINT valid_member_role CHECK (role IN ('owner', 'member'))
+);
+
+CREATE INDEX idx_conversation_members_user ON conversation_members(user_id);
+
+-- Everyone who has posted in an existing conversation becomes a member
+INSERT INTO conversation_members (conversation_id, user_id, created_at)
+SELECT conversation_id, user_id, MIN(created_at)
+FROM messages
+WHERE bot_id IS NULL
+GROUP BY conversation_id, user_id;
@@ -0,0 +1,3 @@
+-- Sequence number of the last event published for each conversation, so
+-- clients can detect and replay missed events
+ALTER TABLE conversations ADD COLUMN event_seq BIGINT NOT NULL DEFAULT 0;
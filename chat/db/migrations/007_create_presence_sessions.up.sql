@@ -0,0 +1,14 @@
+-- Presence of each connected client in the conversations it has joined. A
+-- user's presence in a conversation is the most present of their sessions.
+CREATE TABLE presence_sessions (
+    client_id VARCHAR(255) NOT NULL,
+    conversation_id VARCHAR(255) NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('online', 'idle')),
+    expires_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (client_id, conversation_id)
+);
+
+CREATE INDEX idx_presence_sessions_user ON presence_sessions(conversation_id, user_id);
+CREATE INDEX idx_presence_sessions_expires_at ON presence_sessions(expires_at);
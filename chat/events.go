@@ -0,0 +1,179 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.dev/storage/sqldb"
+	"github.com/google/uuid"
+
+	chatpubsub "encore.app/chat/pubsub"
+	"encore.app/chat/types"
+)
+
+// newEventID generates a unique event ID
+func newEventID() string {
+	return fmt.Sprintf("evt_%s", uuid.New().String())
+}
+
+// messageEventID returns the event ID for an event about msg. New messages get
+// an ID derived from the message so that republishing after a retried send is
+// recognised as the same event.
+func messageEventID(eventType string, msg *types.Message) string {
+	if eventType == types.EventMessage {
+		return fmt.Sprintf("evt_%s", msg.ID)
+	}
+	return newEventID()
+}
+
+// isProcessed reports whether a subscription has already handled an event
+func (s *Service) isProcessed(ctx context.Context, subscription, eventID string) (bool, error) {
+	var done bool
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM processed_events WHERE subscription = $1 AND event_id = $2)
+	`, subscription, eventID).Scan(&done)
+	if err != nil {
+		return false, fmt.Errorf("check processed event: %w", err)
+	}
+	return done, nil
+}
+
+// markProcessed records that a subscription has handled an event
+func (s *Service) markProcessed(ctx context.Context, subscription, eventID string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO processed_events (subscription, event_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, subscription, eventID)
+	if err != nil {
+		return fmt.Errorf("mark event processed: %w", err)
+	}
+	return nil
+}
+
+// nextSequence allocates the next event sequence number of a conversation.
+// Sequence numbers order a conversation's events but are not consecutive: a
+// number is allocated before its event is published, outside the transaction
+// that wrote the change, so a failed publish or a retried send skips one.
+// Clients must order events by sequence number and not treat a gap as lost
+// events; a resumed socket is told to resync when events were actually lost.
+func nextSequence(ctx context.Context, conversationID string) (int64, error) {
+	var seq int64
+	err := db.QueryRow(ctx, `
+		UPDATE conversations SET event_seq = event_seq + 1
+		WHERE id = $1
+		RETURNING event_seq
+	`, conversationID).Scan(&seq)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("allocate event sequence: %w", err)
+	}
+	return seq, nil
+}
+
+// publishMessageEvent publishes an event about a message. Events about bot
+// messages are bot output and go to ChatResponses; all others go to ChatEvents.
+func publishMessageEvent(ctx context.Context, eventType string, msg *types.Message) error {
+	if msg.BotID != "" {
+		return publishResponse(ctx, eventType, msg, "")
+	}
+
+	seq, err := nextSequence(ctx, msg.ConversationID)
+	if err != nil {
+		return err
+	}
+
+	event := &types.ChatEvent{
+		EventID:   messageEventID(eventType, msg),
+		Version:   types.SchemaVersion,
+		Type:      eventType,
+		Platform:  msg.Platform,
+		ChannelID: msg.ChannelID,
+		Message:   msg,
+		Sequence:  seq,
+		Timestamp: time.Now(),
+	}
+	if _, err := chatpubsub.ChatEvents.Publish(ctx, event); err != nil {
+		return fmt.Errorf("publish %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// publishReactionEvent publishes a reaction change, which is always a user action
+func publishReactionEvent(ctx context.Context, eventType string, msg *types.Message, reaction *types.Reaction) error {
+	seq, err := nextSequence(ctx, msg.ConversationID)
+	if err != nil {
+		return err
+	}
+
+	event := &types.ChatEvent{
+		EventID:   newEventID(),
+		Version:   types.SchemaVersion,
+		Type:      eventType,
+		Platform:  msg.Platform,
+		ChannelID: msg.ChannelID,
+		Message:   msg,
+		Reaction:  reaction,
+		Sequence:  seq,
+		Timestamp: time.Now(),
+	}
+	if _, err := chatpubsub.ChatEvents.Publish(ctx, event); err != nil {
+		return fmt.Errorf("publish %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// publishResponse publishes an event about a bot message to ChatResponses
+func publishResponse(ctx context.Context, eventType string, msg *types.Message, requestID string) error {
+	seq, err := nextSequence(ctx, msg.ConversationID)
+	if err != nil {
+		return err
+	}
+
+	event := &types.ResponseEvent{
+		EventID:   messageEventID(eventType, msg),
+		Version:   types.SchemaVersion,
+		Type:      eventType,
+		Platform:  msg.Platform,
+		ChannelID: msg.ChannelID,
+		RequestID: requestID,
+		Message:   msg,
+		Sequence:  seq,
+		Timestamp: time.Now(),
+	}
+	if _, err := chatpubsub.ChatResponses.Publish(ctx, event); err != nil {
+		return fmt.Errorf("publish %s response: %w", eventType, err)
+	}
+	return nil
+}
+
+// publishBotTyping publishes a bot starting or stopping typing in reply to msg,
+// or, once the bot has replied, with msg its reply
+func publishBotTyping(ctx context.Context, msg *types.Message, botID string, active bool) error {
+	replyTo := msg.ID
+	if msg.BotID != "" {
+		replyTo = msg.ReplyToID
+	}
+	event := &types.PlatformEvent{
+		EventID:        newEventID(),
+		Version:        types.SchemaVersion,
+		Type:           types.EventTyping,
+		Platform:       msg.Platform,
+		ChannelID:      msg.ChannelID,
+		ConversationID: msg.ConversationID,
+		Typing: &types.Typing{
+			BotID:     botID,
+			ParentID:  msg.ParentID,
+			MessageID: replyTo,
+			Active:    active,
+		},
+		Timestamp: time.Now(),
+	}
+	if _, err := chatpubsub.PlatformEvents.Publish(ctx, event); err != nil {
+		return fmt.Errorf("publish typing event: %w", err)
+	}
+	return nil
+}
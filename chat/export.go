@@ -0,0 +1,634 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"encore.dev"
+	"encore.dev/rlog"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"encore.app/chat/types"
+)
+
+// Export formats
+const (
+	formatJSON     = "json"     // Export document, accepted by ImportConversations
+	formatMarkdown = "markdown" // human-readable transcripts
+	formatJSONL    = "jsonl"    // OpenAI-style chat fine-tuning examples
+)
+
+// exportVersion is the version of the JSON export format
+const exportVersion = 1
+
+// Limits on the number of conversations in one export
+const (
+	defaultExportLimit = 100
+	maxExportLimit     = 1000
+)
+
+// Export is a set of conversations in the JSON export format
+type Export struct {
+	Version       int                   `json:"version"`
+	ExportedAt    time.Time             `json:"exported_at"`
+	Bots          []*types.Bot          `json:"bots"`
+	Conversations []*ConversationExport `json:"conversations"`
+}
+
+// ConversationExport is a conversation with its messages, oldest first
+type ConversationExport struct {
+	Conversation *types.Conversation `json:"conversation"`
+	Messages     []*types.Message    `json:"messages"`
+}
+
+// exportFilter selects the conversations to export
+type exportFilter struct {
+	UserID         string // only conversations this user is a member of
+	ConversationID string
+	Platform       string
+	ChannelID      string
+	BotID          string
+	Since          time.Time // created at or after
+	Until          time.Time // created before
+	Limit          int
+}
+
+// parseExportFilter reads an export filter from query parameters
+func parseExportFilter(q url.Values) (*exportFilter, error) {
+	f := &exportFilter{
+		ConversationID: q.Get("conversation_id"),
+		Platform:       q.Get("platform"),
+		ChannelID:      q.Get("channel_id"),
+		BotID:          q.Get("bot_id"),
+		Limit:          defaultExportLimit,
+	}
+
+	for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %s", name, v)
+			}
+			*dst = t
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid limit: %s", v)
+		}
+		f.Limit = min(n, maxExportLimit)
+	}
+
+	return f, nil
+}
+
+// ExportConversation exports one of the user's conversations. The format
+// query parameter selects json (default), markdown or jsonl.
+//
+//encore:api auth raw method=GET path=/api/chat/conversations/:id/export
+func (s *Service) ExportConversation(w http.ResponseWriter, req *http.Request) {
+	userID, err := currentUser()
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	filter := &exportFilter{
+		UserID:         userID,
+		ConversationID: encore.CurrentRequest().PathParams.Get("id"),
+		Limit:          1,
+	}
+	s.serveExport(w, req, filter)
+}
+
+// ExportConversations exports the user's conversations matching the query
+// parameters conversation_id, platform, channel_id, bot_id, since, until and
+// limit, oldest first. The format query parameter selects json (default),
+// markdown or jsonl.
+//
+//encore:api auth raw method=GET path=/api/chat/export
+func (s *Service) ExportConversations(w http.ResponseWriter, req *http.Request) {
+	userID, err := currentUser()
+	if err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	filter, err := parseExportFilter(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.UserID = userID
+	s.serveExport(w, req, filter)
+}
+
+// serveExport writes the conversations matching filter in the requested format
+func (s *Service) serveExport(w http.ResponseWriter, req *http.Request, filter *exportFilter) {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = formatJSON
+	}
+
+	var contentType string
+	switch format {
+	case formatJSON:
+		contentType = "application/json"
+	case formatMarkdown:
+		contentType = "text/markdown; charset=utf-8"
+	case formatJSONL:
+		contentType = "application/jsonl"
+	default:
+		http.Error(w, "Unsupported format", http.StatusBadRequest)
+		return
+	}
+
+	exp, err := s.export(req.Context(), filter)
+	if err != nil {
+		rlog.Error("Failed to export conversations", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if filter.ConversationID != "" && len(exp.Conversations) == 0 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	ext := map[string]string{formatJSON: "json", formatMarkdown: "md", formatJSONL: "jsonl"}[format]
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="conversations.%s"`, ext))
+
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(exp)
+	case formatMarkdown:
+		err = writeMarkdown(w, exp)
+	case formatJSONL:
+		err = writeJSONL(w, exp)
+	}
+	if err != nil {
+		rlog.Error("Failed to write export", "format", format, "error", err)
+	}
+}
+
+// export loads the conversations matching filter with their messages and bots
+func (s *Service) export(ctx context.Context, filter *exportFilter) (*Export, error) {
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.UserID != "" {
+		add("EXISTS(SELECT 1 FROM conversation_members cm WHERE cm.conversation_id = id AND cm.user_id = $%d)", filter.UserID)
+	}
+	if filter.ConversationID != "" {
+		add("id = $%d", filter.ConversationID)
+	}
+	if filter.Platform != "" {
+		add("platform = $%d", filter.Platform)
+	}
+	if filter.ChannelID != "" {
+		add("channel_id = $%d", filter.ChannelID)
+	}
+	if filter.BotID != "" {
+		add("$%d = ANY(bot_ids)", filter.BotID)
+	}
+	if !filter.Since.IsZero() {
+		add("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("created_at < $%d", filter.Until)
+	}
+
+	query := `
+		SELECT id, channel_id, platform, bot_ids,
+			created_at, updated_at
+		FROM conversations
+	`
+	if len(where) > 0 {
+		query += "WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at ASC LIMIT $%d", len(args))
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list conversations: %w", err)
+	}
+	defer rows.Close()
+
+	exp := &Export{Version: exportVersion, ExportedAt: time.Now()}
+	byID := make(map[string]*ConversationExport)
+	var ids []string
+	for rows.Next() {
+		var conv types.Conversation
+		err := rows.Scan(
+			&conv.ID, &conv.ChannelID, &conv.Platform,
+			pq.Array(&conv.BotIDs), &conv.CreatedAt, &conv.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan conversation: %w", err)
+		}
+		ce := &ConversationExport{Conversation: &conv, Messages: []*types.Message{}}
+		exp.Conversations = append(exp.Conversations, ce)
+		byID[conv.ID] = ce
+		ids = append(ids, conv.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate conversations: %w", err)
+	}
+	if len(ids) == 0 {
+		return exp, nil
+	}
+
+	messages, err := s.queryMessages(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE m.conversation_id = ANY($1)
+		ORDER BY m.created_at ASC
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("list messages: %w", err)
+	}
+
+	botIDs := make(map[string]bool)
+	for _, msg := range messages {
+		byID[msg.ConversationID].Messages = append(byID[msg.ConversationID].Messages, msg)
+		if msg.BotID != "" {
+			botIDs[msg.BotID] = true
+		}
+	}
+	for _, ce := range exp.Conversations {
+		for _, id := range ce.Conversation.BotIDs {
+			botIDs[id] = true
+		}
+	}
+
+	bots, err := s.ListBots(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list bots: %w", err)
+	}
+	for _, bot := range bots.Bots {
+		if botIDs[bot.ID] {
+			exp.Bots = append(exp.Bots, bot)
+		}
+	}
+
+	return exp, nil
+}
+
+// writeMarkdown writes conversations as Markdown transcripts
+func writeMarkdown(w io.Writer, exp *Export) error {
+	bots := make(map[string]*types.Bot)
+	for _, bot := range exp.Bots {
+		bots[bot.ID] = bot
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, ce := range exp.Conversations {
+		conv := ce.Conversation
+		if i > 0 {
+			fmt.Fprint(bw, "\n---\n\n")
+		}
+		fmt.Fprintf(bw, "# Conversation %s\n\n", conv.ID)
+		fmt.Fprintf(bw, "- Platform: %s\n", conv.Platform)
+		fmt.Fprintf(bw, "- Channel: %s\n", conv.ChannelID)
+		fmt.Fprintf(bw, "- Started: %s\n", conv.CreatedAt.Format(time.RFC3339))
+		for _, id := range conv.BotIDs {
+			if bot, ok := bots[id]; ok {
+				fmt.Fprintf(bw, "- Bot: %s (%s)\n", bot.Name, bot.Persona)
+			}
+		}
+
+		for _, msg := range ce.Messages {
+			if msg.DeletedAt != nil {
+				continue
+			}
+			author := msg.UserID
+			if bot, ok := bots[msg.BotID]; ok {
+				author = bot.Name
+			}
+			fmt.Fprintf(bw, "\n**%s** · %s", author, msg.CreatedAt.Format(time.RFC3339))
+			if msg.EditedAt != nil {
+				fmt.Fprint(bw, " · edited")
+			}
+			fmt.Fprint(bw, "\n\n")
+
+			// Thread replies are quoted under their root message
+			prefix := ""
+			if msg.ParentID != "" {
+				prefix = "> "
+			}
+			for _, line := range strings.Split(msg.Content, "\n") {
+				fmt.Fprintf(bw, "%s%s\n", prefix, line)
+			}
+			for _, a := range msg.Attachments {
+				fmt.Fprintf(bw, "%s- [%s](%s)\n", prefix, a.Filename, a.URL)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// chatMessage is a message in an OpenAI-style chat fine-tuning example
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// writeJSONL writes one fine-tuning example per bot in each conversation.
+// The bot's persona is the system message and its replies are the assistant
+// turns; users and other bots are user turns. Examples end on the bot's last
+// reply, and conversations the bot never replied in are skipped.
+func writeJSONL(w io.Writer, exp *Export) error {
+	enc := json.NewEncoder(w)
+	for _, ce := range exp.Conversations {
+		for _, bot := range exp.Bots {
+			if !contains(ce.Conversation.BotIDs, bot.ID) {
+				continue
+			}
+
+			messages := []chatMessage{{Role: "system", Content: systemPrompt(bot)}}
+			last := 0
+			for _, msg := range ce.Messages {
+				if msg.DeletedAt != nil || msg.Content == "" {
+					continue
+				}
+				switch msg.BotID {
+				case bot.ID:
+					messages = append(messages, chatMessage{Role: "assistant", Content: msg.Content})
+					last = len(messages)
+				case "":
+					messages = append(messages, chatMessage{Role: "user", Content: msg.Content})
+				default:
+					name := msg.BotID
+					for _, other := range exp.Bots {
+						if other.ID == msg.BotID {
+							name = other.Name
+						}
+					}
+					messages = append(messages, chatMessage{Role: "user", Content: name + ": " + msg.Content})
+				}
+			}
+			if last == 0 {
+				continue
+			}
+
+			if err := enc.Encode(map[string][]chatMessage{"messages": messages[:last]}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// contains reports whether list contains s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportResponse represents the response for importing conversations
+type ImportResponse struct {
+	Conversations []*types.Conversation `json:"conversations"`
+}
+
+// ImportConversations seeds conversations from a JSON export, owned by the
+// importing user. Conversations and messages get new IDs, and deleted messages
+// and attachments are skipped. Bots are created unless they already exist; a
+// bot whose ID is taken by a different bot is created under a new ID.
+// Imported messages are stored as history only: they are not published and
+// get no bot replies.
+//
+//encore:api auth method=POST path=/api/chat/import
+func (s *Service) ImportConversations(ctx context.Context, exp *Export) (*ImportResponse, error) {
+	userID, err := currentUser()
+	if err != nil {
+		return nil, err
+	}
+	if exp.Version != exportVersion {
+		return nil, fmt.Errorf("unsupported export version: %d", exp.Version)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	botIDs, err := importBots(ctx, tx, exp.Bots)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ImportResponse{Conversations: []*types.Conversation{}}
+	for _, ce := range exp.Conversations {
+		if ce.Conversation == nil {
+			return nil, fmt.Errorf("conversation is required")
+		}
+		conv, err := importConversation(ctx, tx, ce, botIDs)
+		if err != nil {
+			return nil, err
+		}
+		if err := addMember(ctx, tx, conv.ID, userID, types.RoleOwner); err != nil {
+			return nil, err
+		}
+		resp.Conversations = append(resp.Conversations, conv)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return resp, nil
+}
+
+// importBots stores the bots of an export, returning the ID each exported bot
+// is stored under. A stored bot with the same ID is reused if it is the same
+// bot, and otherwise the exported bot is stored under a new ID, so that
+// imported conversations keep the personas they were held with.
+func importBots(ctx context.Context, tx *sql.Tx, bots []*types.Bot) (map[string]string, error) {
+	ids := make(map[string]string)
+	for _, bot := range bots {
+		var stored types.Bot
+		var paramsRaw []byte
+		err := tx.QueryRowContext(ctx, `
+			SELECT name, persona, provider, parameters FROM bots WHERE id = $1
+		`, bot.ID).Scan(&stored.Name, &stored.Persona, &stored.Provider, &paramsRaw)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("get bot %s: %w", bot.ID, err)
+		}
+		if len(paramsRaw) > 0 {
+			if err := json.Unmarshal(paramsRaw, &stored.Parameters); err != nil {
+				return nil, fmt.Errorf("unmarshal parameters: %w", err)
+			}
+		}
+
+		id := bot.ID
+		if err == nil {
+			if sameBot(&stored, bot) {
+				ids[bot.ID] = id
+				continue
+			}
+			id = fmt.Sprintf("bot_%s", uuid.New().String())
+			rlog.Info("importing conflicting bot under a new id", "bot_id", bot.ID, "new_id", id)
+		}
+
+		paramsJSON, err := json.Marshal(bot.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("marshal parameters: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO bots (id, name, persona, avatar, provider, parameters)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, id, bot.Name, bot.Persona, bot.Avatar, bot.Provider, paramsJSON)
+		if err != nil {
+			return nil, fmt.Errorf("import bot %s: %w", bot.ID, err)
+		}
+		ids[bot.ID] = id
+	}
+	return ids, nil
+}
+
+// sameBot reports whether two bots answer the same way: same name, persona,
+// provider and parameters. Avatars and timestamps may differ.
+func sameBot(a, b *types.Bot) bool {
+	if a.Name != b.Name || a.Persona != b.Persona || a.Provider != b.Provider {
+		return false
+	}
+	var pa, pb types.BotParameters
+	if a.Parameters != nil {
+		pa = *a.Parameters
+	}
+	if b.Parameters != nil {
+		pb = *b.Parameters
+	}
+	return pa == pb
+}
+
+// importMessageType returns the stored type of an exported message
+func importMessageType(msgType string) (string, error) {
+	switch msgType {
+	case "":
+		return "text", nil
+	case "text", "image", "file":
+		return msgType, nil
+	}
+	return "", fmt.Errorf("invalid message type: %s", msgType)
+}
+
+// importConversation stores an exported conversation under new IDs, with
+// its bots' IDs mapped to the ones they were imported under
+func importConversation(ctx context.Context, tx *sql.Tx, ce *ConversationExport, botIDs map[string]string) (*types.Conversation, error) {
+	now := time.Now()
+	conv := *ce.Conversation
+	conv.ID = fmt.Sprintf("conv_%s", uuid.New().String())
+	conv.BotIDs = make([]string, len(ce.Conversation.BotIDs))
+	for i, id := range ce.Conversation.BotIDs {
+		conv.BotIDs[i] = importedBotID(botIDs, id)
+	}
+	if conv.ChannelID == "" {
+		conv.ChannelID = "default"
+	}
+	if conv.Platform == "" {
+		conv.Platform = "local"
+	}
+	if conv.CreatedAt.IsZero() {
+		conv.CreatedAt = now
+	}
+	if conv.UpdatedAt.IsZero() {
+		conv.UpdatedAt = conv.CreatedAt
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO conversations (
+			id, channel_id, platform, bot_ids,
+			created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6)
+	`, conv.ID, conv.ChannelID, conv.Platform,
+		pq.Array(conv.BotIDs), conv.CreatedAt, conv.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("import conversation: %w", err)
+	}
+
+	// Map exported message IDs to new ones so threads and replies still link up
+	ids := make(map[string]string)
+	for _, msg := range ce.Messages {
+		if msg.DeletedAt != nil {
+			continue
+		}
+		id := fmt.Sprintf("msg_%s", uuid.New().String())
+		userID := msg.UserID
+		if userID == "" {
+			userID = "anonymous"
+		}
+		createdAt := msg.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		msgType, err := importMessageType(msg.Type)
+		if err != nil {
+			return nil, fmt.Errorf("import message %s: %w", msg.ID, err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO messages (
+				id, conversation_id, user_id, bot_id, parent_id, reply_to_id,
+				content, type, created_at, edited_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, id, conv.ID, userID,
+			nullString(importedBotID(botIDs, msg.BotID)), nullString(ids[msg.ParentID]), nullString(ids[msg.ReplyToID]),
+			msg.Content, msgType, createdAt, msg.EditedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("import message %s: %w", msg.ID, err)
+		}
+		ids[msg.ID] = id
+
+		for _, r := range msg.Reactions {
+			if r.CreatedAt.IsZero() {
+				r.CreatedAt = createdAt
+			}
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO message_reactions (message_id, user_id, emoji, created_at)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT DO NOTHING
+			`, id, r.UserID, r.Emoji, r.CreatedAt)
+			if err != nil {
+				return nil, fmt.Errorf("import reaction: %w", err)
+			}
+		}
+	}
+
+	return &conv, nil
+}
+
+// importedBotID returns the ID a bot was imported under. Bots missing from
+// the export keep their ID.
+func importedBotID(botIDs map[string]string, id string) string {
+	if imported, ok := botIDs[id]; ok {
+		return imported
+	}
+	return id
+}
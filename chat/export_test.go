@@ -0,0 +1,76 @@
+package chat
+
+import (
+	"testing"
+
+	"encore.app/chat/types"
+)
+
+func TestSameBot(t *testing.T) {
+	stored := &types.Bot{ID: "bot_1", Name: "Ada", Persona: "A helpful mathematician", Provider: "openai",
+		Parameters: &types.BotParameters{MaxTokens: 500, Temperature: 0.7}}
+	with := func(change func(b *types.Bot)) *types.Bot {
+		b := *stored
+		params := *stored.Parameters
+		b.Parameters = &params
+		change(&b)
+		return &b
+	}
+
+	tests := []struct {
+		name string
+		bot  *types.Bot
+		want bool
+	}{
+		{"identical", with(func(*types.Bot) {}), true},
+		{"other avatar", with(func(b *types.Bot) { b.Avatar = "https://example.com/ada.png" }), true},
+		{"other persona", with(func(b *types.Bot) { b.Persona = "A grumpy poet" }), false},
+		{"other name", with(func(b *types.Bot) { b.Name = "Grace" }), false},
+		{"other provider", with(func(b *types.Bot) { b.Provider = "anthropic" }), false},
+		{"other parameters", with(func(b *types.Bot) { b.Parameters.Temperature = 0.2 }), false},
+		{"no parameters", with(func(b *types.Bot) { b.Parameters = nil }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameBot(stored, tt.bot); got != tt.want {
+				t.Errorf("sameBot = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !sameBot(&types.Bot{Name: "Ada"}, &types.Bot{Name: "Ada", Parameters: &types.BotParameters{}}) {
+		t.Error("empty parameters should match no parameters")
+	}
+}
+
+func TestImportMessageType(t *testing.T) {
+	tests := []struct {
+		msgType string
+		want    string
+		wantErr bool
+	}{
+		{"", "text", false},
+		{"text", "text", false},
+		{"image", "image", false},
+		{"file", "file", false},
+		{"system", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.msgType, func(t *testing.T) {
+			got, err := importMessageType(tt.msgType)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("importMessageType(%q) = %q, %v, want %q", tt.msgType, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportedBotID(t *testing.T) {
+	botIDs := map[string]string{"bot_1": "bot_1", "bot_2": "bot_new"}
+	tests := map[string]string{"bot_1": "bot_1", "bot_2": "bot_new", "bot_3": "bot_3", "": ""}
+	for id, want := range tests {
+		if got := importedBotID(botIDs, id); got != want {
+			t.Errorf("importedBotID(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
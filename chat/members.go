@@ -0,0 +1,212 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+
+	"encore.app/chat/types"
+)
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// addMember adds a user to a conversation, keeping the role of existing members
+func addMember(ctx context.Context, db execer, conversationID, userID, role string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO conversation_members (conversation_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (conversation_id, user_id) DO NOTHING
+	`, conversationID, userID, role)
+	if err != nil {
+		return fmt.Errorf("add member: %w", err)
+	}
+	return nil
+}
+
+// memberRole returns a user's role in a conversation, or "" if they are not a member
+func (s *Service) memberRole(ctx context.Context, conversationID, userID string) (string, error) {
+	var role string
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT role FROM conversation_members
+		WHERE conversation_id = $1 AND user_id = $2
+	`, conversationID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("get member: %w", err)
+	}
+	return role, nil
+}
+
+// currentUser returns the ID of the authenticated user making the request
+func currentUser() (string, error) {
+	uid, ok := auth.UserID()
+	if !ok {
+		return "", &errs.Error{Code: errs.Unauthenticated, Message: "not authenticated"}
+	}
+	return string(uid), nil
+}
+
+// authorizeConversation checks that the authenticated user is a member of a
+// conversation and returns their user ID and role
+func (s *Service) authorizeConversation(ctx context.Context, conversationID string) (string, string, error) {
+	userID, err := currentUser()
+	if err != nil {
+		return "", "", err
+	}
+	role, err := s.memberRole(ctx, conversationID, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if role == "" {
+		return "", "", &errs.Error{Code: errs.PermissionDenied, Message: "not a member of this conversation"}
+	}
+	return userID, role, nil
+}
+
+// authorizeMessage retrieves a message whose conversation the authenticated
+// user is a member of, and returns it with the user's ID and role
+func (s *Service) authorizeMessage(ctx context.Context, id string) (*types.Message, string, string, error) {
+	msg, err := s.getMessage(ctx, id)
+	if err != nil {
+		return nil, "", "", err
+	}
+	userID, role, err := s.authorizeConversation(ctx, msg.ConversationID)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return msg, userID, role, nil
+}
+
+// ListMembersResponse represents the response for listing conversation members
+type ListMembersResponse struct {
+	Members []*types.Member `json:"members"`
+}
+
+// ListConversationMembers retrieves the members of a conversation
+//
+//encore:api auth method=GET path=/api/chat/conversations/:id/members
+func (s *Service) ListConversationMembers(ctx context.Context, id string) (*ListMembersResponse, error) {
+	if _, _, err := s.authorizeConversation(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT conversation_id, user_id, role, created_at
+		FROM conversation_members
+		WHERE conversation_id = $1
+		ORDER BY created_at ASC
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("list members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*types.Member
+	for rows.Next() {
+		var m types.Member
+		if err := rows.Scan(&m.ConversationID, &m.UserID, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan member: %w", err)
+		}
+		members = append(members, &m)
+	}
+
+	return &ListMembersResponse{Members: members}, nil
+}
+
+// AddMemberRequest represents the request for adding a conversation member
+type AddMemberRequest struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role,omitempty"` // owner or member (default)
+}
+
+// AddConversationMember adds a user to a conversation. Only owners may add members.
+//
+//encore:api auth method=POST path=/api/chat/conversations/:id/members
+func (s *Service) AddConversationMember(ctx context.Context, id string, req *AddMemberRequest) (*types.Member, error) {
+	_, role, err := s.authorizeConversation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if role != types.RoleOwner {
+		return nil, &errs.Error{Code: errs.PermissionDenied, Message: "only owners can add members"}
+	}
+
+	if req.UserID == "" {
+		return nil, fmt.Errorf("user_id is required")
+	}
+	if req.Role == "" {
+		req.Role = types.RoleMember
+	}
+	if req.Role != types.RoleOwner && req.Role != types.RoleMember {
+		return nil, fmt.Errorf("invalid role: %s", req.Role)
+	}
+
+	member := &types.Member{
+		ConversationID: id,
+		UserID:         req.UserID,
+		Role:           req.Role,
+		CreatedAt:      time.Now(),
+	}
+	err = s.DB.QueryRowContext(ctx, `
+		INSERT INTO conversation_members (conversation_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (conversation_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING created_at
+	`, member.ConversationID, member.UserID, member.Role, member.CreatedAt).Scan(&member.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("add member: %w", err)
+	}
+
+	return member, nil
+}
+
+// RemoveConversationMember removes a user from a conversation. Owners may
+// remove anyone; members may only remove themselves.
+//
+//encore:api auth method=DELETE path=/api/chat/conversations/:id/members/:userID
+func (s *Service) RemoveConversationMember(ctx context.Context, id string, userID string) error {
+	caller, role, err := s.authorizeConversation(ctx, id)
+	if err != nil {
+		return err
+	}
+	if role != types.RoleOwner && caller != userID {
+		return &errs.Error{Code: errs.PermissionDenied, Message: "only owners can remove other members"}
+	}
+
+	// Keep at least one owner
+	var otherOwners bool
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM conversation_members
+			WHERE conversation_id = $1 AND user_id <> $2 AND role = $3
+		)
+	`, id, userID, types.RoleOwner).Scan(&otherOwners)
+	if err != nil {
+		return fmt.Errorf("check owners: %w", err)
+	}
+	if !otherOwners {
+		if r, err := s.memberRole(ctx, id, userID); err != nil {
+			return err
+		} else if r == types.RoleOwner {
+			return fmt.Errorf("cannot remove the last owner of a conversation")
+		}
+	}
+
+	_, err = s.DB.ExecContext(ctx, `
+		DELETE FROM conversation_members
+		WHERE conversation_id = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		return fmt.Errorf("remove member: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,525 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"encore.dev/beta/errs"
+	"github.com/lib/pq"
+
+	"encore.app/chat/types"
+)
+
+// messageColumns lists the columns selected by queries that read full messages.
+// Queries must alias messages as m and conversations as c.
+const messageColumns = `m.id, m.conversation_id, c.channel_id, c.platform,
+			m.user_id, m.bot_id, m.parent_id, m.reply_to_id,
+			m.client_message_id, m.request_id, m.content, m.type, m.created_at, m.edited_at, m.deleted_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMessage scans a row selected with messageColumns
+func scanMessage(row rowScanner) (*types.Message, error) {
+	var msg types.Message
+	var botID, parentID, replyToID, clientID, requestID sql.NullString
+	var editedAt, deletedAt sql.NullTime
+	err := row.Scan(
+		&msg.ID, &msg.ConversationID, &msg.ChannelID,
+		&msg.Platform, &msg.UserID, &botID, &parentID, &replyToID,
+		&clientID, &requestID, &msg.Content, &msg.Type, &msg.CreatedAt, &editedAt, &deletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	msg.BotID = botID.String
+	msg.ParentID = parentID.String
+	msg.ReplyToID = replyToID.String
+	msg.ClientMessageID = clientID.String
+	msg.RequestID = requestID.String
+	if editedAt.Valid {
+		msg.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		msg.DeletedAt = &deletedAt.Time
+	}
+	return &msg, nil
+}
+
+// queryMessages runs a query selecting messageColumns and attaches reactions and attachments
+func (s *Service) queryMessages(ctx context.Context, query string, args ...any) ([]*types.Message, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*types.Message
+	byID := make(map[string]*types.Message)
+	var ids []string
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan message: %w", err)
+		}
+		messages = append(messages, msg)
+		byID[msg.ID] = msg
+		ids = append(ids, msg.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate messages: %w", err)
+	}
+	if len(ids) == 0 {
+		return messages, nil
+	}
+
+	reactions, err := s.DB.QueryContext(ctx, `
+		SELECT message_id, user_id, emoji, created_at
+		FROM message_reactions
+		WHERE message_id = ANY($1)
+		ORDER BY created_at ASC
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("query reactions: %w", err)
+	}
+	defer reactions.Close()
+
+	for reactions.Next() {
+		var r types.Reaction
+		if err := reactions.Scan(&r.MessageID, &r.UserID, &r.Emoji, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan reaction: %w", err)
+		}
+		if msg, ok := byID[r.MessageID]; ok {
+			msg.Reactions = append(msg.Reactions, &r)
+		}
+	}
+	if err := reactions.Err(); err != nil {
+		return nil, fmt.Errorf("iterate reactions: %w", err)
+	}
+
+	attachments, err := s.loadAttachments(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, list := range attachments {
+		if msg, ok := byID[id]; ok {
+			msg.Attachments = list
+		}
+	}
+
+	return messages, nil
+}
+
+// getMessage retrieves a single message with its reactions and attachments
+func (s *Service) getMessage(ctx context.Context, id string) (*types.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE m.id = $1
+	`
+	messages, err := s.queryMessages(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("message not found: %s", id)
+	}
+	return messages[0], nil
+}
+
+// findDuplicate returns the stored message sharing msg's dedupe key, or nil.
+// User messages are keyed by client message ID, bot replies by request ID,
+// both within msg's conversation. A user message starting a new conversation
+// has none yet, so its retry is matched among the user's own messages.
+func (s *Service) findDuplicate(ctx context.Context, msg *types.Message) (*types.Message, error) {
+	var where string
+	var args []any
+	switch {
+	case msg.BotID != "" && msg.RequestID != "":
+		where = "m.bot_id = $1 AND m.request_id = $2 AND m.conversation_id = $3"
+		args = []any{msg.BotID, msg.RequestID, msg.ConversationID}
+	case msg.BotID == "" && msg.ClientMessageID != "":
+		where = "m.user_id = $1 AND m.client_message_id = $2"
+		args = []any{msg.UserID, msg.ClientMessageID}
+		if msg.ConversationID != "" {
+			where += " AND m.conversation_id = $3"
+			args = append(args, msg.ConversationID)
+		}
+	default:
+		return nil, nil
+	}
+
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE ` + where
+	messages, err := s.queryMessages(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	return messages[0], nil
+}
+
+// nullString converts an empty string to a NULL column value
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// EditMessageRequest represents the request for editing a message
+type EditMessageRequest struct {
+	Content string `json:"content"`
+	// Regenerate replaces the bot replies to an edited user message
+	Regenerate bool `json:"regenerate,omitempty"`
+}
+
+// canModify reports whether a user with the given role may change a message.
+// Users may change their own messages; owners may also change bot replies.
+func canModify(msg *types.Message, userID, role string) bool {
+	if msg.BotID != "" {
+		return role == types.RoleOwner
+	}
+	return msg.UserID == userID
+}
+
+// EditMessage updates the content of a message and records the previous revision
+//
+//encore:api auth method=PATCH path=/api/chat/messages/:id
+func (s *Service) EditMessage(ctx context.Context, id string, req *EditMessageRequest) (*types.Message, error) {
+	if req.Content == "" {
+		return nil, fmt.Errorf("content is required")
+	}
+
+	current, userID, role, err := s.authorizeMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canModify(current, userID, role) {
+		return nil, &errs.Error{Code: errs.PermissionDenied, Message: "cannot edit this message"}
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous string
+	var deletedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT content, deleted_at FROM messages WHERE id = $1 FOR UPDATE", id,
+	).Scan(&previous, &deletedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("message not found: %s", id)
+	} else if err != nil {
+		return nil, fmt.Errorf("get message: %w", err)
+	}
+	if deletedAt.Valid {
+		return nil, fmt.Errorf("message has been deleted: %s", id)
+	}
+
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO message_edits (message_id, content, edited_at)
+		VALUES ($1, $2, $3)
+	`, id, previous, now)
+	if err != nil {
+		return nil, fmt.Errorf("store message edit: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE messages SET content = $1, edited_at = $2 WHERE id = $3",
+		req.Content, now, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("update message: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	msg, err := s.getMessage(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get message details: %w", err)
+	}
+
+	if err := publishMessageEvent(ctx, types.EventMessageEdited, msg); err != nil {
+		return nil, err
+	}
+
+	// Replace the bot replies to this message
+	if req.Regenerate && msg.BotID == "" {
+		if err := s.deleteReplies(ctx, msg.ID); err != nil {
+			return nil, fmt.Errorf("delete bot replies: %w", err)
+		}
+		if err := s.generateReplies(ctx, msg); err != nil {
+			return nil, fmt.Errorf("regenerate bot replies: %w", err)
+		}
+	}
+
+	return msg, nil
+}
+
+// DeleteMessage soft-deletes a message, keeping its edit history. Users may
+// delete their own messages; owners may delete any message.
+//
+//encore:api auth method=DELETE path=/api/chat/messages/:id
+func (s *Service) DeleteMessage(ctx context.Context, id string) (*types.Message, error) {
+	msg, userID, role, err := s.authorizeMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if role != types.RoleOwner && !canModify(msg, userID, role) {
+		return nil, &errs.Error{Code: errs.PermissionDenied, Message: "cannot delete this message"}
+	}
+
+	return s.deleteMessage(ctx, id)
+}
+
+// deleteMessage soft-deletes a message and publishes the deletion
+func (s *Service) deleteMessage(ctx context.Context, id string) (*types.Message, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var content string
+	var deletedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT content, deleted_at FROM messages WHERE id = $1 FOR UPDATE", id,
+	).Scan(&content, &deletedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("message not found: %s", id)
+	} else if err != nil {
+		return nil, fmt.Errorf("get message: %w", err)
+	}
+	if deletedAt.Valid {
+		return nil, fmt.Errorf("message has been deleted: %s", id)
+	}
+
+	// Keep the deleted content in the edit history
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO message_edits (message_id, content, edited_at)
+		VALUES ($1, $2, $3)
+	`, id, content, now)
+	if err != nil {
+		return nil, fmt.Errorf("store message edit: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE messages SET content = '', deleted_at = $1 WHERE id = $2",
+		now, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("delete message: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	msg, err := s.getMessage(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get message details: %w", err)
+	}
+
+	if err := publishMessageEvent(ctx, types.EventMessageDeleted, msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// deleteReplies deletes the bot replies generated for a message
+func (s *Service) deleteReplies(ctx context.Context, messageID string) error {
+	rows, err := s.DB.QueryContext(ctx,
+		"SELECT id FROM messages WHERE reply_to_id = $1 AND deleted_at IS NULL", messageID,
+	)
+	if err != nil {
+		return fmt.Errorf("query replies: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan reply: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate replies: %w", err)
+	}
+
+	for _, id := range ids {
+		if _, err := s.deleteMessage(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	// Drop responses still in flight for the old generations
+	_, err = s.DB.ExecContext(ctx, `
+		UPDATE generations SET superseded_at = NOW()
+		WHERE message_id = $1 AND superseded_at IS NULL
+	`, messageID)
+	if err != nil {
+		return fmt.Errorf("supersede generations: %w", err)
+	}
+	return nil
+}
+
+// ListMessageEditsResponse represents the response for listing a message's edit history
+type ListMessageEditsResponse struct {
+	Edits []*types.MessageEdit `json:"edits"`
+}
+
+// ListMessageEdits retrieves the previous revisions of a message, oldest first
+//
+//encore:api auth method=GET path=/api/chat/messages/:id/edits
+func (s *Service) ListMessageEdits(ctx context.Context, id string) (*ListMessageEditsResponse, error) {
+	if _, _, _, err := s.authorizeMessage(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT message_id, content, edited_at
+		FROM message_edits
+		WHERE message_id = $1
+		ORDER BY edited_at ASC, id ASC
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("list message edits: %w", err)
+	}
+	defer rows.Close()
+
+	var edits []*types.MessageEdit
+	for rows.Next() {
+		var edit types.MessageEdit
+		if err := rows.Scan(&edit.MessageID, &edit.Content, &edit.EditedAt); err != nil {
+			return nil, fmt.Errorf("scan message edit: %w", err)
+		}
+		edits = append(edits, &edit)
+	}
+
+	return &ListMessageEditsResponse{Edits: edits}, nil
+}
+
+// ListThreadReplies retrieves the replies in a message's thread
+//
+//encore:api auth method=GET path=/api/chat/messages/:id/replies
+func (s *Service) ListThreadReplies(ctx context.Context, id string) (*ListMessagesResponse, error) {
+	if _, _, _, err := s.authorizeMessage(ctx, id); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE m.parent_id = $1
+		ORDER BY m.created_at ASC
+	`
+	messages, err := s.queryMessages(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("list thread replies: %w", err)
+	}
+
+	return &ListMessagesResponse{Messages: messages}, nil
+}
+
+// ReactionRequest represents the request for adding a reaction
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// AddReaction adds the user's emoji reaction to a message
+//
+//encore:api auth method=POST path=/api/chat/messages/:id/reactions
+func (s *Service) AddReaction(ctx context.Context, id string, req *ReactionRequest) (*types.Message, error) {
+	if req.Emoji == "" {
+		return nil, fmt.Errorf("emoji is required")
+	}
+	_, userID, _, err := s.authorizeMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	reaction := &types.Reaction{
+		MessageID: id,
+		UserID:    userID,
+		Emoji:     req.Emoji,
+		CreatedAt: time.Now(),
+	}
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO message_reactions (message_id, user_id, emoji, created_at)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS(SELECT 1 FROM messages WHERE id = $1 AND deleted_at IS NULL)
+		ON CONFLICT (message_id, user_id, emoji) DO NOTHING
+	`, reaction.MessageID, reaction.UserID, reaction.Emoji, reaction.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("add reaction: %w", err)
+	}
+
+	msg, err := s.getMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if msg.DeletedAt != nil {
+		return nil, fmt.Errorf("message has been deleted: %s", id)
+	}
+
+	if err := publishReactionEvent(ctx, types.EventReactionAdded, msg, reaction); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// RemoveReaction removes the user's emoji reaction from a message
+//
+//encore:api auth method=DELETE path=/api/chat/messages/:id/reactions/:emoji
+func (s *Service) RemoveReaction(ctx context.Context, id string, emoji string) (*types.Message, error) {
+	_, userID, _, err := s.authorizeMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.DB.ExecContext(ctx, `
+		DELETE FROM message_reactions
+		WHERE message_id = $1 AND user_id = $2 AND emoji = $3
+	`, id, userID, emoji)
+	if err != nil {
+		return nil, fmt.Errorf("remove reaction: %w", err)
+	}
+
+	msg, err := s.getMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 {
+		reaction := &types.Reaction{
+			MessageID: id,
+			UserID:    userID,
+			Emoji:     emoji,
+			CreatedAt: time.Now(),
+		}
+		if err := publishReactionEvent(ctx, types.EventReactionRemoved, msg, reaction); err != nil {
+			return nil, err
+		}
+	}
+
+	return msg, nil
+}
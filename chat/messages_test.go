@@ -0,0 +1,375 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+	"encore.dev/et"
+	"github.com/google/uuid"
+
+	authsvc "encore.app/auth"
+	"encore.app/chat/types"
+)
+
+func TestCanModify(t *testing.T) {
+	tests := []struct {
+		name   string
+		msg    *types.Message
+		userID string
+		role   string
+		want   bool
+	}{
+		{"own message", &types.Message{UserID: "u1"}, "u1", types.RoleMember, true},
+		{"other user's message", &types.Message{UserID: "u2"}, "u1", types.RoleMember, false},
+		{"owner on other user's message", &types.Message{UserID: "u2"}, "u1", types.RoleOwner, false},
+		{"bot reply as member", &types.Message{UserID: "u1", BotID: "bot_1"}, "u1", types.RoleMember, false},
+		{"bot reply as owner", &types.Message{UserID: "u1", BotID: "bot_1"}, "u1", types.RoleOwner, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canModify(tt.msg, tt.userID, tt.role); got != tt.want {
+				t.Errorf("canModify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// as makes the rest of the test act as the given user
+func as(userID string) {
+	et.OverrideAuthInfo(auth.UID(userID), &authsvc.UserData{ID: userID})
+}
+
+// newConversation starts a conversation without bots, owned by a new user,
+// with a second user as a member. It returns the service, both user IDs and
+// the first message.
+func newConversation(t *testing.T) (s *Service, owner, member string, first *types.Message) {
+	t.Helper()
+	ctx := context.Background()
+	s = &Service{DB: db.Stdlib()}
+	owner, member = "owner_"+uuid.New().String(), "member_"+uuid.New().String()
+
+	as(owner)
+	first, err := s.SendMessage(ctx, &types.Message{Content: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.ExecContext(ctx, "UPDATE conversations SET bot_ids = '{}' WHERE id = $1", first.ConversationID); err != nil {
+		t.Fatal(err)
+	}
+	if err := addMember(ctx, s.DB, first.ConversationID, member, types.RoleMember); err != nil {
+		t.Fatal(err)
+	}
+	return s, owner, member, first
+}
+
+// storeReply stores a new bot's reply to msg with a current generation, as a
+// handled LLM response would
+func storeReply(t *testing.T, s *Service, msg *types.Message) *types.Message {
+	t.Helper()
+	ctx := context.Background()
+	bot, err := s.CreateBot(ctx, &types.Bot{Name: "Test", Persona: "a tester", Provider: "openai"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestID := "req_" + uuid.New().String()
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO generations (request_id, message_id, bot_id, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, requestID, msg.ID, bot.ID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := s.storeMessage(ctx, &types.Message{
+		ConversationID: msg.ConversationID,
+		ChannelID:      msg.ChannelID,
+		BotID:          bot.ID,
+		ReplyToID:      msg.ID,
+		RequestID:      requestID,
+		Content:        "reply",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func TestEditMessage(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		asMember bool
+		deleted  bool
+		botReply bool
+		content  string
+		wantErr  bool
+		wantCode errs.ErrCode
+	}{
+		{name: "own message", content: "edited"},
+		{name: "empty content", content: "", wantErr: true},
+		{name: "other user's message", asMember: true, content: "edited", wantErr: true, wantCode: errs.PermissionDenied},
+		{name: "deleted message", deleted: true, content: "edited", wantErr: true},
+		{name: "bot reply as owner", botReply: true, content: "edited"},
+		{name: "bot reply as member", botReply: true, asMember: true, content: "edited", wantErr: true, wantCode: errs.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, member, msg := newConversation(t)
+			if tt.botReply {
+				msg = storeReply(t, s, msg)
+			}
+			original := msg.Content
+			if tt.deleted {
+				if _, err := s.DeleteMessage(ctx, msg.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.asMember {
+				as(member)
+			}
+
+			got, err := s.EditMessage(ctx, msg.ID, &EditMessageRequest{Content: tt.content})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantCode != errs.OK && errs.Code(err) != tt.wantCode {
+				t.Errorf("code = %v, want %v", errs.Code(err), tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if got.Content != tt.content || got.EditedAt == nil {
+				t.Errorf("message = %+v, want edited content %q", got, tt.content)
+			}
+			edits, err := s.ListMessageEdits(ctx, msg.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(edits.Edits) != 1 || edits.Edits[0].Content != original {
+				t.Errorf("edits = %+v, want the original content %q", edits.Edits, original)
+			}
+		})
+	}
+}
+
+func TestDeleteMessage(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		author   string // owner or member
+		deleter  string
+		twice    bool
+		wantErr  bool
+		wantCode errs.ErrCode
+	}{
+		{name: "own message", author: "member", deleter: "member"},
+		{name: "owner deletes member's message", author: "member", deleter: "owner"},
+		{name: "member deletes owner's message", author: "owner", deleter: "member", wantErr: true, wantCode: errs.PermissionDenied},
+		{name: "already deleted", author: "owner", deleter: "owner", twice: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, owner, member, msg := newConversation(t)
+			users := map[string]string{"owner": owner, "member": member}
+			if tt.author == "member" {
+				as(member)
+				var err error
+				msg, err = s.SendMessage(ctx, &types.Message{ConversationID: msg.ConversationID, Content: "secret"})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			as(users[tt.deleter])
+			if tt.twice {
+				if _, err := s.DeleteMessage(ctx, msg.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := s.DeleteMessage(ctx, msg.ID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantCode != errs.OK && errs.Code(err) != tt.wantCode {
+				t.Errorf("code = %v, want %v", errs.Code(err), tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if got.DeletedAt == nil || got.Content != "" {
+				t.Errorf("message = %+v, want soft-deleted", got)
+			}
+			// The deleted content stays in the edit history
+			edits, err := s.ListMessageEdits(ctx, msg.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(edits.Edits) != 1 || edits.Edits[0].Content != msg.Content {
+				t.Errorf("edits = %+v, want the deleted content %q", edits.Edits, msg.Content)
+			}
+		})
+	}
+}
+
+func TestThreadReplies(t *testing.T) {
+	ctx := context.Background()
+	s, _, member, root := newConversation(t)
+	_, _, _, foreign := newConversation(t)
+
+	tests := []struct {
+		name     string
+		parentID string
+		wantErr  bool
+	}{
+		{"reply to root", root.ID, false},
+		{"second reply", root.ID, false},
+		{"parent in another conversation", foreign.ID, true},
+		{"missing parent", "msg_missing", true},
+	}
+	var want []string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			as(member)
+			reply, err := s.SendMessage(ctx, &types.Message{
+				ConversationID: root.ConversationID,
+				ParentID:       tt.parentID,
+				Content:        tt.name,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				want = append(want, reply.ID)
+			}
+		})
+	}
+
+	as(member)
+	replies, err := s.ListThreadReplies(ctx, root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range replies.Messages {
+		got = append(got, m.ID)
+	}
+	if len(got) != len(want) || len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("replies = %v, want %v in order", got, want)
+	}
+
+	// Deleted thread roots take no new replies
+	as(root.UserID)
+	if _, err := s.DeleteMessage(ctx, root.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendMessage(ctx, &types.Message{ConversationID: root.ConversationID, ParentID: root.ID, Content: "late"}); err == nil {
+		t.Error("replied to a deleted message")
+	}
+}
+
+func TestReactions(t *testing.T) {
+	ctx := context.Background()
+	s, owner, member, msg := newConversation(t)
+
+	// Each step runs against the state the previous steps left
+	steps := []struct {
+		name    string
+		user    string
+		add     string // emoji to add
+		remove  string // emoji to remove
+		want    []string
+		wantErr bool
+	}{
+		{name: "add", user: owner, add: "👍", want: []string{owner + "👍"}},
+		{name: "add twice", user: owner, add: "👍", want: []string{owner + "👍"}},
+		{name: "another user", user: member, add: "👍", want: []string{owner + "👍", member + "👍"}},
+		{name: "another emoji", user: member, add: "🎉", want: []string{owner + "👍", member + "👍", member + "🎉"}},
+		{name: "empty emoji", user: member, add: "", wantErr: true},
+		{name: "remove", user: owner, remove: "👍", want: []string{member + "👍", member + "🎉"}},
+		{name: "remove missing", user: owner, remove: "👍", want: []string{member + "👍", member + "🎉"}},
+		{name: "only removes own", user: owner, remove: "🎉", want: []string{member + "👍", member + "🎉"}},
+		{name: "non-member", user: "stranger", add: "👍", wantErr: true},
+	}
+	for _, step := range steps {
+		as(step.user)
+		var got *types.Message
+		var err error
+		if step.remove != "" {
+			got, err = s.RemoveReaction(ctx, msg.ID, step.remove)
+		} else {
+			got, err = s.AddReaction(ctx, msg.ID, &ReactionRequest{Emoji: step.add})
+		}
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: error = %v, want error %v", step.name, err, step.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		var reactions []string
+		for _, r := range got.Reactions {
+			reactions = append(reactions, r.UserID+r.Emoji)
+		}
+		if len(reactions) != len(step.want) {
+			t.Fatalf("%s: reactions = %v, want %v", step.name, reactions, step.want)
+		}
+		for i := range reactions {
+			if reactions[i] != step.want[i] {
+				t.Errorf("%s: reactions = %v, want %v", step.name, reactions, step.want)
+				break
+			}
+		}
+	}
+
+	// Deleted messages take no reactions
+	as(owner)
+	if _, err := s.DeleteMessage(ctx, msg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddReaction(ctx, msg.ID, &ReactionRequest{Emoji: "👍"}); err == nil {
+		t.Error("reacted to a deleted message")
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name           string
+		regenerate     bool
+		wantSuperseded bool
+	}{
+		{"edit keeps replies", false, false},
+		{"regenerate replaces replies", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, owner, _, msg := newConversation(t)
+			reply := storeReply(t, s, msg)
+
+			as(owner)
+			if _, err := s.EditMessage(ctx, msg.ID, &EditMessageRequest{Content: "edited", Regenerate: tt.regenerate}); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := s.getMessage(ctx, reply.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted := got.DeletedAt != nil; deleted != tt.wantSuperseded {
+				t.Errorf("reply deleted = %v, want %v", deleted, tt.wantSuperseded)
+			}
+			var current int
+			err = s.DB.QueryRowContext(ctx, `
+				SELECT COUNT(*) FROM generations
+				WHERE message_id = $1 AND superseded_at IS NULL
+			`, msg.ID).Scan(&current)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if superseded := current == 0; superseded != tt.wantSuperseded {
+				t.Errorf("generation superseded = %v, want %v", superseded, tt.wantSuperseded)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"encore.dev/cron"
+	"encore.dev/rlog"
+
+	chatpubsub "encore.app/chat/pubsub"
+	"encore.app/chat/types"
+)
+
+// presenceTTL is how long a reported presence lasts. Clients must report
+// again within it, or they are considered offline.
+const presenceTTL = 90 * time.Second
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// PresenceRequest represents the request for reporting a client's presence
+type PresenceRequest struct {
+	ClientID string `json:"client_id"`
+	Status   string `json:"status"` // online, idle, offline
+}
+
+// SetPresence reports the presence of one of the authenticated user's clients
+// in a conversation. The user's presence is that of their most present client,
+// and changes to it are published on PlatformEvents. Online and idle reports
+// expire unless repeated within 90 seconds.
+//
+//encore:api auth method=PUT path=/api/chat/conversations/:id/presence
+func (s *Service) SetPresence(ctx context.Context, id string, req *PresenceRequest) error {
+	userID, _, err := s.authorizeConversation(ctx, id)
+	if err != nil {
+		return err
+	}
+	if req.ClientID == "" {
+		return fmt.Errorf("client_id is required")
+	}
+	switch req.Status {
+	case types.PresenceOnline, types.PresenceIdle, types.PresenceOffline:
+	default:
+		return fmt.Errorf("invalid status: %s", req.Status)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialize changes to a user's presence so each change is published once
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1 || ':' || $2))`, id, userID)
+	if err != nil {
+		return fmt.Errorf("lock presence: %w", err)
+	}
+
+	before, err := userPresence(ctx, tx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if req.Status == types.PresenceOffline {
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM presence_sessions
+			WHERE client_id = $1 AND conversation_id = $2 AND user_id = $3
+		`, req.ClientID, id, userID)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO presence_sessions (client_id, conversation_id, user_id, status, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (client_id, conversation_id) DO UPDATE
+			SET status = EXCLUDED.status, expires_at = EXCLUDED.expires_at, updated_at = NOW()
+			WHERE presence_sessions.user_id = EXCLUDED.user_id
+		`, req.ClientID, id, userID, req.Status, time.Now().Add(presenceTTL))
+	}
+	if err != nil {
+		return fmt.Errorf("update presence: %w", err)
+	}
+
+	after, err := userPresence(ctx, tx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	if after == before {
+		return nil
+	}
+	conv, err := s.getConversation(ctx, id)
+	if err != nil {
+		return err
+	}
+	return publishPresence(ctx, conv, userID, after)
+}
+
+// userPresence returns a user's presence in a conversation: online if any of
+// their clients is, else idle if any is connected, else offline
+func userPresence(ctx context.Context, q queryer, conversationID, userID string) (string, error) {
+	var status string
+	err := q.QueryRowContext(ctx, `
+		SELECT status FROM presence_sessions
+		WHERE conversation_id = $1 AND user_id = $2 AND expires_at > NOW()
+		ORDER BY status = 'online' DESC
+		LIMIT 1
+	`, conversationID, userID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return types.PresenceOffline, nil
+	} else if err != nil {
+		return "", fmt.Errorf("get presence: %w", err)
+	}
+	return status, nil
+}
+
+// PresenceResponse represents the response for getting a conversation's presence
+type PresenceResponse struct {
+	Presence []*types.Presence `json:"presence"`
+}
+
+// GetPresence retrieves the presence of the members of a conversation
+//
+//encore:api auth method=GET path=/api/chat/conversations/:id/presence
+func (s *Service) GetPresence(ctx context.Context, id string) (*PresenceResponse, error) {
+	if _, _, err := s.authorizeConversation(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT m.user_id, COALESCE((
+			SELECT p.status FROM presence_sessions p
+			WHERE p.conversation_id = m.conversation_id AND p.user_id = m.user_id
+				AND p.expires_at > NOW()
+			ORDER BY p.status = 'online' DESC
+			LIMIT 1
+		), $2)
+		FROM conversation_members m
+		WHERE m.conversation_id = $1
+		ORDER BY m.created_at ASC
+	`, id, types.PresenceOffline)
+	if err != nil {
+		return nil, fmt.Errorf("list presence: %w", err)
+	}
+	defer rows.Close()
+
+	var presence []*types.Presence
+	for rows.Next() {
+		var p types.Presence
+		if err := rows.Scan(&p.UserID, &p.Status); err != nil {
+			return nil, fmt.Errorf("scan presence: %w", err)
+		}
+		presence = append(presence, &p)
+	}
+
+	return &PresenceResponse{Presence: presence}, nil
+}
+
+// Clients that stop reporting, such as those of a crashed instance, go offline
+var _ = cron.NewJob("expire-presence", cron.JobConfig{
+	Title:    "Expire stale presence",
+	Every:    1 * cron.Minute,
+	Endpoint: ExpirePresence,
+})
+
+// ExpirePresence removes expired presence reports and publishes the
+// resulting changes
+//
+//encore:api private
+func (s *Service) ExpirePresence(ctx context.Context) error {
+	rows, err := s.DB.QueryContext(ctx, `
+		DELETE FROM presence_sessions WHERE expires_at <= NOW()
+		RETURNING conversation_id, user_id, status
+	`)
+	if err != nil {
+		return fmt.Errorf("expire presence: %w", err)
+	}
+	defer rows.Close()
+
+	// The most present status each affected user had among the expired reports
+	type key struct{ conversationID, userID string }
+	expired := make(map[key]string)
+	for rows.Next() {
+		var k key
+		var status string
+		if err := rows.Scan(&k.conversationID, &k.userID, &status); err != nil {
+			return fmt.Errorf("scan expired presence: %w", err)
+		}
+		if expired[k] != types.PresenceOnline {
+			expired[k] = status
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("expire presence: %w", err)
+	}
+
+	for k, was := range expired {
+		status, err := userPresence(ctx, s.DB, k.conversationID, k.userID)
+		if err != nil {
+			return err
+		}
+		if status == was {
+			continue
+		}
+		conv, err := s.getConversation(ctx, k.conversationID)
+		if err != nil {
+			rlog.Error("failed to get conversation", "conversation_id", k.conversationID, "error", err)
+			continue
+		}
+		if err := publishPresence(ctx, conv, k.userID, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishPresence publishes a change to a user's presence in a conversation
+func publishPresence(ctx context.Context, conv *types.Conversation, userID, status string) error {
+	event := &types.PlatformEvent{
+		EventID:        newEventID(),
+		Version:        types.SchemaVersion,
+		Type:           types.EventPresence,
+		Platform:       conv.Platform,
+		ChannelID:      conv.ChannelID,
+		ConversationID: conv.ID,
+		Presence: &types.Presence{
+			UserID: userID,
+			Status: status,
+		},
+		Timestamp: time.Now(),
+	}
+	if _, err := chatpubsub.PlatformEvents.Publish(ctx, event); err != nil {
+		return fmt.Errorf("publish presence event: %w", err)
+	}
+	return nil
+}
@@ -5,17 +5,17 @@ import (
 	"encore.dev/pubsub"
 )
 
-// ChatEvents is the topic for all chat-related events
+// ChatEvents is the topic for user-originated chat events (messages, edits, reactions)
 var ChatEvents = pubsub.NewTopic[*types.ChatEvent]("chat-events", pubsub.TopicConfig{
 	DeliveryGuarantee: pubsub.AtLeastOnce,
 })
 
 // ChatResponses is the topic for bot responses
-var ChatResponses = pubsub.NewTopic[*types.ChatEvent]("chat-responses", pubsub.TopicConfig{
+var ChatResponses = pubsub.NewTopic[*types.ResponseEvent]("chat-responses", pubsub.TopicConfig{
 	DeliveryGuarantee: pubsub.AtLeastOnce,
 })
 
 // PlatformEvents is the topic for platform-specific events (typing indicators, presence updates, etc)
-var PlatformEvents = pubsub.NewTopic[*types.ChatEvent]("platform-events", pubsub.TopicConfig{
+var PlatformEvents = pubsub.NewTopic[*types.PlatformEvent]("platform-events", pubsub.TopicConfig{
 	DeliveryGuarantee: pubsub.AtLeastOnce,
 })
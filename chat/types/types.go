@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Bot represents a chat bot profile
 type Bot struct {
@@ -18,6 +21,7 @@ type Bot struct {
 type BotParameters struct {
 	MaxTokens   int     `json:"max_tokens,omitempty"`
 	Temperature float64 `json:"temperature,omitempty"`
+	Vision      bool    `json:"vision,omitempty"` // pass image attachments to the provider
 }
 
 // Conversation represents a chat conversation
@@ -32,23 +36,146 @@ type Conversation struct {
 
 // Message represents a chat message
 type Message struct {
-	ID             string    `json:"id"`
+	ID              string        `json:"id"`
+	ConversationID  string        `json:"conversation_id"`
+	ChannelID       string        `json:"channel_id"`
+	Platform        string        `json:"platform"`
+	UserID          string        `json:"user_id"`
+	BotID           string        `json:"bot_id,omitempty"`
+	ParentID        string        `json:"parent_id,omitempty"`         // thread root this message replies in
+	ReplyToID       string        `json:"reply_to_id,omitempty"`       // user message a bot reply was generated for
+	ClientMessageID string        `json:"client_message_id,omitempty"` // client-supplied dedupe key, unique per user
+	RequestID       string        `json:"request_id,omitempty"`        // LLM request a bot reply answers
+	Content         string        `json:"content"`
+	Type            string        `json:"type"` // text, image, file
+	Attachments     []*Attachment `json:"attachments,omitempty"`
+	Reactions       []*Reaction   `json:"reactions,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	EditedAt        *time.Time    `json:"edited_at,omitempty"`
+	DeletedAt       *time.Time    `json:"deleted_at,omitempty"`
+}
+
+// MessageEdit represents a previous revision of an edited message
+type MessageEdit struct {
+	MessageID string    `json:"message_id"`
+	Content   string    `json:"content"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// Attachment represents an uploaded file attached to a message
+type Attachment struct {
+	ID           string    `json:"id"`
+	MessageID    string    `json:"message_id,omitempty"`
+	UserID       string    `json:"user_id"`
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width,omitempty"`
+	Height       int       `json:"height,omitempty"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// IsImage reports whether the attachment is an image
+func (a *Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
+
+// Reaction represents an emoji reaction to a message
+type Reaction struct {
+	MessageID string    `json:"message_id"`
+	UserID    string    `json:"user_id"`
+	Emoji     string    `json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Conversation member roles
+const (
+	RoleOwner  = "owner"  // may manage members and delete any message
+	RoleMember = "member" // may read and post
+)
+
+// Member represents a user's membership in a conversation
+type Member struct {
 	ConversationID string    `json:"conversation_id"`
-	ChannelID      string    `json:"channel_id"`
-	Platform       string    `json:"platform"`
 	UserID         string    `json:"user_id"`
-	BotID          string    `json:"bot_id,omitempty"`
-	Content        string    `json:"content"`
-	Type           string    `json:"type"` // text, image, etc
+	Role           string    `json:"role"`
 	CreatedAt      time.Time `json:"created_at"`
 }
 
-// ChatEvent represents a chat event for pub/sub
+// SchemaVersion is the version of the event payloads published on the chat topics.
+// Bump it when making a breaking change to any event type below.
+const SchemaVersion = 1
+
+// Chat event types
+const (
+	EventMessage         = "message"
+	EventMessageEdited   = "message_edited"
+	EventMessageDeleted  = "message_deleted"
+	EventReactionAdded   = "reaction_added"
+	EventReactionRemoved = "reaction_removed"
+	EventTyping          = "typing"
+	EventPresence        = "presence"
+)
+
+// ChatEvent represents a user-originated chat event, published on ChatEvents
 type ChatEvent struct {
 	EventID   string    `json:"event_id"`
-	Type      string    `json:"type"` // message, typing, etc
+	Version   int       `json:"version"`
+	Type      string    `json:"type"` // message, message_edited, message_deleted, reaction_added, reaction_removed
 	Platform  string    `json:"platform"`
 	ChannelID string    `json:"channel_id"`
 	Message   *Message  `json:"message,omitempty"`
+	Reaction  *Reaction `json:"reaction,omitempty"`
+	Sequence  int64     `json:"sequence,omitempty"` // position among the events of the message's conversation, may skip numbers
 	Timestamp time.Time `json:"timestamp"`
 }
+
+// ResponseEvent represents a bot output, published on ChatResponses
+type ResponseEvent struct {
+	EventID   string    `json:"event_id"`
+	Version   int       `json:"version"`
+	Type      string    `json:"type"` // message, message_deleted
+	Platform  string    `json:"platform"`
+	ChannelID string    `json:"channel_id"`
+	RequestID string    `json:"request_id,omitempty"` // LLM request that produced the message
+	Message   *Message  `json:"message"`
+	Sequence  int64     `json:"sequence,omitempty"` // position among the events of the message's conversation, may skip numbers
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// PlatformEvent represents a transient signal such as typing, published on PlatformEvents
+type PlatformEvent struct {
+	EventID        string    `json:"event_id"`
+	Version        int       `json:"version"`
+	Type           string    `json:"type"` // typing, presence
+	Platform       string    `json:"platform"`
+	ChannelID      string    `json:"channel_id"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Typing         *Typing   `json:"typing,omitempty"`
+	Presence       *Presence `json:"presence,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// Typing represents a user or bot starting or stopping typing
+type Typing struct {
+	UserID    string `json:"user_id,omitempty"`
+	BotID     string `json:"bot_id,omitempty"`
+	ParentID  string `json:"parent_id,omitempty"`  // thread the reply is being written in
+	MessageID string `json:"message_id,omitempty"` // user message a bot is replying to
+	Active    bool   `json:"active"`
+}
+
+// Presence statuses
+const (
+	PresenceOnline  = "online"  // connected and active
+	PresenceIdle    = "idle"    // connected but inactive
+	PresenceOffline = "offline" // not connected
+)
+
+// Presence represents a user's presence in a conversation
+type Presence struct {
+	UserID string `json:"user_id"`
+	Status string `json:"status"` // online, idle, offline
+}
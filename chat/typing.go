@@ -0,0 +1,54 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	chatpubsub "encore.app/chat/pubsub"
+	"encore.app/chat/types"
+)
+
+// TypingRequest represents the request for signalling that a user is typing
+type TypingRequest struct {
+	ParentID string `json:"parent_id,omitempty"` // thread the user is writing in
+	Active   bool   `json:"active"`
+}
+
+// SetTyping signals that the authenticated user started or stopped typing in
+// a conversation they are a member of
+//
+//encore:api auth method=POST path=/api/chat/conversations/:id/typing
+func (s *Service) SetTyping(ctx context.Context, id string, req *TypingRequest) error {
+	userID, _, err := s.authorizeConversation(ctx, id)
+	if err != nil {
+		return err
+	}
+	conv, err := s.getConversation(ctx, id)
+	if err != nil {
+		return err
+	}
+	return publishUserTyping(ctx, conv, userID, req.ParentID, req.Active)
+}
+
+// publishUserTyping publishes a user starting or stopping typing in a conversation
+func publishUserTyping(ctx context.Context, conv *types.Conversation, userID, parentID string, active bool) error {
+	event := &types.PlatformEvent{
+		EventID:        newEventID(),
+		Version:        types.SchemaVersion,
+		Type:           types.EventTyping,
+		Platform:       conv.Platform,
+		ChannelID:      conv.ChannelID,
+		ConversationID: conv.ID,
+		Typing: &types.Typing{
+			UserID:   userID,
+			ParentID: parentID,
+			Active:   active,
+		},
+		Timestamp: time.Now(),
+	}
+	if _, err := chatpubsub.PlatformEvents.Publish(ctx, event); err != nil {
+		return fmt.Errorf("publish typing event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxMessageLength is the maximum length of a Discord message, in characters
+const maxMessageLength = 2000
+
+// HTTPClient sends HTTP requests; *http.Client satisfies it and tests may substitute a fake
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client is a minimal Discord REST API client
+type Client struct {
+	baseURL string
+	token   string
+	http    HTTPClient
+}
+
+// NewClient creates a Discord REST API client. Pass a nil httpClient to use the default client.
+func NewClient(baseURL, token string, httpClient HTTPClient) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    httpClient,
+	}
+}
+
+// CreateMessageParams represents the parameters for creating a channel message
+type CreateMessageParams struct {
+	Content string `json:"content"`
+}
+
+// CreateMessage posts a message to a channel, splitting content that exceeds Discord's limit
+func (c *Client) CreateMessage(ctx context.Context, channelID, content string) error {
+	for _, chunk := range splitMessage(content, maxMessageLength) {
+		path := fmt.Sprintf("/channels/%s/messages", channelID)
+		if err := c.call(ctx, http.MethodPost, path, &CreateMessageParams{Content: chunk}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TriggerTyping shows the bot as typing in a channel for a few seconds
+func (c *Client) TriggerTyping(ctx context.Context, channelID string) error {
+	return c.call(ctx, http.MethodPost, fmt.Sprintf("/channels/%s/typing", channelID), nil)
+}
+
+// call sends a request to the REST API
+func (c *Client) call(ctx context.Context, method, path string, params interface{}) error {
+	var body io.Reader
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("marshal %s request: %w", path, err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("create %s request: %w", path, err)
+	}
+	req.Header.Set("Authorization", "Bot "+c.token)
+	if params != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("send %s request: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s returned status %d: %s", path, resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// splitMessage splits content into chunks of at most limit characters
+func splitMessage(content string, limit int) []string {
+	runes := []rune(content)
+	if len(runes) <= limit {
+		return []string{content}
+	}
+
+	var chunks []string
+	for len(runes) > 0 {
+		n := min(limit, len(runes))
+		chunks = append(chunks, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return chunks
+}
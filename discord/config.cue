@@ -0,0 +1,5 @@
+package discord
+
+APIBaseURL: string | *"https://discord.com/api/v10"
+GatewayURL: string | *"wss://gateway.discord.gg/?v=10&encoding=json"
+EnableGateway: bool | *false
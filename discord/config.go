@@ -0,0 +1,11 @@
+package discord
+
+import "encore.dev/config"
+
+type Config struct {
+	APIBaseURL    string // Discord REST API base URL, overridable for fakes
+	GatewayURL    string // Discord Gateway URL used to receive channel messages
+	EnableGateway bool   // connect to the Gateway, from one instance at a time
+}
+
+var cfg = config.Load[*Config]()
@@ -0,0 +1,400 @@
+// Package discord connects Discord servers to the chat service through
+// interactions, Gateway messages and the REST API for bot replies.
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"encore.dev/pubsub"
+	"encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+
+	"encore.app/chat"
+	chatpubsub "encore.app/chat/pubsub"
+	"encore.app/chat/types"
+)
+
+// Platform is the chat platform name used for Discord conversations
+const Platform = "discord"
+
+// Interaction types and callback types
+const (
+	interactionPing               = 1
+	interactionApplicationCommand = 2
+
+	callbackPong                     = 1
+	callbackChannelMessageWithSource = 4
+)
+
+var secrets struct {
+	DiscordPublicKey string
+	DiscordBotToken  string
+}
+
+// Define database
+var db = sqldb.NewDatabase("discord", sqldb.DatabaseConfig{
+	Migrations: "./migrations",
+})
+
+//encore:service
+type Service struct {
+	client *Client
+	cancel context.CancelFunc
+
+	// ingest sends a Discord user's message to the chat service
+	ingest func(ctx context.Context, msg *types.Message) (*types.Message, error)
+}
+
+// initService is automatically called by Encore when the service starts up.
+func initService() (*Service, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		client: NewClient(cfg.APIBaseURL, secrets.DiscordBotToken, nil),
+		cancel: cancel,
+		ingest: chat.IngestMessage,
+	}
+
+	// Plain channel messages are only delivered over the Gateway, which a
+	// single instance connects to so each message is received once
+	if cfg.EnableGateway && secrets.DiscordBotToken != "" {
+		g := &gateway{
+			url:       cfg.GatewayURL,
+			token:     secrets.DiscordBotToken,
+			onMessage: s.handleGatewayMessage,
+		}
+		go runAsLeader(ctx, gatewayLock, g.run)
+	}
+
+	return s, nil
+}
+
+func (s *Service) Shutdown(force context.Context) {
+	s.cancel()
+}
+
+// interaction represents an inbound Discord interaction
+type interaction struct {
+	Type      int    `json:"type"`
+	ID        string `json:"id"`
+	GuildID   string `json:"guild_id,omitempty"`
+	ChannelID string `json:"channel_id,omitempty"`
+	Member    *struct {
+		User discordUser `json:"user"`
+	} `json:"member,omitempty"`
+	User *discordUser `json:"user,omitempty"`
+	Data *struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string          `json:"name"`
+			Value json.RawMessage `json:"value"`
+		} `json:"options,omitempty"`
+	} `json:"data,omitempty"`
+}
+
+// discordUser represents a Discord user
+type discordUser struct {
+	ID string `json:"id"`
+}
+
+// interactionResponse represents the response to an interaction
+type interactionResponse struct {
+	Type int                      `json:"type"`
+	Data *interactionResponseData `json:"data,omitempty"`
+}
+
+// interactionResponseData represents the message sent in response to an interaction
+type interactionResponseData struct {
+	Content string `json:"content"`
+}
+
+// userID returns the ID of the user who triggered the interaction
+func (i *interaction) userID() string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// messageOption returns the text of the command's "message" option
+func (i *interaction) messageOption() string {
+	if i.Data == nil {
+		return ""
+	}
+	for _, opt := range i.Data.Options {
+		if opt.Name != "message" {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(opt.Value, &value); err == nil {
+			return value
+		}
+	}
+	return ""
+}
+
+// ServeInteractions receives Discord interactions, such as slash commands
+//
+//encore:api public raw method=POST path=/api/discord/interactions
+func (s *Service) ServeInteractions(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := VerifyRequest(secrets.DiscordPublicKey, req.Header, body); err != nil {
+		rlog.Warn("rejected discord request", "error", err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var in interaction
+	if err := json.Unmarshal(body, &in); err != nil {
+		http.Error(w, "Failed to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	var resp interactionResponse
+	switch in.Type {
+	case interactionPing:
+		resp = interactionResponse{Type: callbackPong}
+
+	case interactionApplicationCommand:
+		content := in.messageOption()
+		if content == "" {
+			http.Error(w, "Missing message option", http.StatusBadRequest)
+			return
+		}
+		if err := s.forward(req.Context(), in.GuildID, in.ChannelID, in.userID(), in.ID, content); err != nil {
+			rlog.Error("Failed to handle discord interaction", "interaction_id", in.ID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		// Echo the prompt so the channel shows what the bots are answering
+		resp = interactionResponse{
+			Type: callbackChannelMessageWithSource,
+			Data: &interactionResponseData{Content: "> " + content},
+		}
+
+	default:
+		http.Error(w, "Unsupported interaction type", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		rlog.Error("Failed to encode response", "error", err)
+	}
+}
+
+// handleGatewayMessage forwards a channel message received over the Gateway
+func (s *Service) handleGatewayMessage(ctx context.Context, msg *messageCreate) error {
+	// Ignore bot posts, including our own replies
+	if msg.Author.Bot || msg.Content == "" {
+		return nil
+	}
+	return s.forward(ctx, msg.GuildID, msg.ChannelID, msg.Author.ID, msg.ID, msg.Content)
+}
+
+// forward sends a user message from Discord to the chat service. The
+// Discord message or interaction ID dedupes redelivered messages.
+func (s *Service) forward(ctx context.Context, guildID, discordChannelID, userID, messageID, content string) error {
+	channelID := ChannelID(guildID, discordChannelID)
+
+	conversationID, err := getConversation(ctx, db, channelID)
+	if err != nil {
+		return err
+	}
+	if conversationID != "" {
+		_, err := s.ingestMessage(ctx, conversationID, channelID, userID, messageID, content)
+		return err
+	}
+
+	// The channel's first message creates its conversation. Lock the channel
+	// so that concurrent first messages don't each create one.
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, channelID); err != nil {
+		return fmt.Errorf("lock channel: %w", err)
+	}
+	// Another message may have created the conversation while we waited
+	conversationID, err = getConversation(ctx, tx, channelID)
+	if err != nil {
+		return err
+	}
+
+	msg, err := s.ingestMessage(ctx, conversationID, channelID, userID, messageID, content)
+	if err != nil {
+		return err
+	}
+
+	if conversationID == "" {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO channels (channel_id, guild_id, discord_channel_id, conversation_id)
+			VALUES ($1, $2, $3, $4)
+		`, channelID, guildID, discordChannelID, msg.ConversationID)
+		if err != nil {
+			return fmt.Errorf("store channel: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// querier is a database or transaction
+type querier interface {
+	QueryRow(ctx context.Context, query string, args ...interface{}) *sqldb.Row
+}
+
+// getConversation returns the conversation of a channel, or "" if it has none
+func getConversation(ctx context.Context, q querier, channelID string) (string, error) {
+	var conversationID string
+	err := q.QueryRow(ctx, `
+		SELECT conversation_id FROM channels WHERE channel_id = $1
+	`, channelID).Scan(&conversationID)
+	if err != nil && !errors.Is(err, sqldb.ErrNoRows) {
+		return "", fmt.Errorf("get channel: %w", err)
+	}
+	return conversationID, nil
+}
+
+// ingestMessage sends a message to a conversation, or to a new one if conversationID is ""
+func (s *Service) ingestMessage(ctx context.Context, conversationID, channelID, userID, messageID, content string) (*types.Message, error) {
+	msg, err := s.ingest(ctx, &types.Message{
+		ConversationID:  conversationID,
+		ChannelID:       channelID,
+		Platform:        Platform,
+		UserID:          userID,
+		Content:         content,
+		Type:            "text",
+		ClientMessageID: messageID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("send message: %w", err)
+	}
+	return msg, nil
+}
+
+// ChannelID maps a Discord guild and channel to a chat channel ID.
+// Direct messages have no guild and use "@me", as Discord's own URLs do.
+func ChannelID(guildID, channelID string) string {
+	if guildID == "" {
+		guildID = "@me"
+	}
+	return guildID + ":" + channelID
+}
+
+// discordChannel extracts the Discord channel ID from a chat channel ID
+func discordChannel(channelID string) (string, error) {
+	_, id, ok := strings.Cut(channelID, ":")
+	if !ok || id == "" {
+		return "", fmt.Errorf("invalid discord channel id: %s", channelID)
+	}
+	return id, nil
+}
+
+// Initialize subscriptions for bot responses and typing indicators
+var _ = pubsub.NewSubscription(
+	chatpubsub.ChatResponses, "handle-discord-responses",
+	pubsub.SubscriptionConfig[*types.ResponseEvent]{
+		Handler: pubsub.MethodHandler((*Service).handleResponse),
+	},
+)
+
+var _ = pubsub.NewSubscription(
+	chatpubsub.PlatformEvents, "handle-discord-typing",
+	pubsub.SubscriptionConfig[*types.PlatformEvent]{
+		Handler: pubsub.MethodHandler((*Service).handlePlatformEvent),
+	},
+)
+
+// handleResponse posts bot replies back to Discord. Redelivered events are
+// skipped, so a reply is posted once.
+func (s *Service) handleResponse(ctx context.Context, event *types.ResponseEvent) error {
+	// Only new Discord bot messages are posted
+	if event.Platform != Platform || event.Type != types.EventMessage || event.Message == nil {
+		return nil
+	}
+	if done, err := isProcessed(ctx, "handle-discord-responses", event.EventID); err != nil || done {
+		return err
+	}
+
+	channelID, err := discordChannel(event.ChannelID)
+	if err != nil {
+		rlog.Error("dropping discord response", "event_id", event.EventID, "error", err)
+		return nil
+	}
+
+	// Bots share one Discord user, so name the persona in the message
+	content := event.Message.Content
+	if bot, err := chat.GetBot(ctx, event.Message.BotID); err == nil {
+		content = fmt.Sprintf("**%s**: %s", bot.Name, content)
+	}
+	if err := s.client.CreateMessage(ctx, channelID, content); err != nil {
+		return fmt.Errorf("create message: %w", err)
+	}
+
+	return markProcessed(ctx, "handle-discord-responses", event.EventID)
+}
+
+// isProcessed reports whether a subscription has already handled an event
+func isProcessed(ctx context.Context, subscription, eventID string) (bool, error) {
+	var done bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM processed_events WHERE subscription = $1 AND event_id = $2)
+	`, subscription, eventID).Scan(&done)
+	if err != nil {
+		return false, fmt.Errorf("check processed event: %w", err)
+	}
+	return done, nil
+}
+
+// markProcessed records that a subscription has handled an event
+func markProcessed(ctx context.Context, subscription, eventID string) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO processed_events (subscription, event_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, subscription, eventID)
+	if err != nil {
+		return fmt.Errorf("mark event processed: %w", err)
+	}
+	return nil
+}
+
+// handlePlatformEvent shows bot typing on Discord
+func (s *Service) handlePlatformEvent(ctx context.Context, event *types.PlatformEvent) error {
+	// Discord clears typing by itself once a message is sent. Only bots type
+	// through the API; Discord users are shown typing by Discord itself.
+	if event.Platform != Platform || event.Type != types.EventTyping || event.Typing == nil ||
+		event.Typing.BotID == "" || !event.Typing.Active {
+		return nil
+	}
+
+	channelID, err := discordChannel(event.ChannelID)
+	if err != nil {
+		rlog.Error("dropping discord event", "event_id", event.EventID, "error", err)
+		return nil
+	}
+
+	if err := s.client.TriggerTyping(ctx, channelID); err != nil {
+		return fmt.Errorf("trigger typing: %w", err)
+	}
+	return nil
+}
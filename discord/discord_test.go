@@ -0,0 +1,227 @@
+package discord
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"encore.app/chat/types"
+)
+
+// fakeChat stands in for the chat service, starting a conversation for
+// messages sent without one
+type fakeChat struct {
+	mu       sync.Mutex
+	messages []*types.Message
+}
+
+func (f *fakeChat) ingest(_ context.Context, msg *types.Message) (*types.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if msg.ConversationID == "" {
+		msg.ConversationID = fmt.Sprintf("conv_%s", uuid.New().String())
+	}
+	f.messages = append(f.messages, msg)
+	return msg, nil
+}
+
+// conversations returns the conversations messages were sent to
+func (f *fakeChat) conversations() map[string]bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	convs := make(map[string]bool)
+	for _, m := range f.messages {
+		convs[m.ConversationID] = true
+	}
+	return convs
+}
+
+func newTestService() (*Service, *fakeChat) {
+	chat := &fakeChat{}
+	return &Service{ingest: chat.ingest}, chat
+}
+
+func TestServeInteractions(t *testing.T) {
+	secrets.DiscordPublicKey = hex.EncodeToString(testPublicKey)
+
+	command := func(message string) string {
+		return fmt.Sprintf(`{"type":2,"id":"%s","guild_id":"g1","channel_id":"%s","member":{"user":{"id":"u1"}},
+			"data":{"name":"ask","options":[{"name":"message","value":%q}]}}`, uuid.New().String(), uuid.New().String(), message)
+	}
+	tests := []struct {
+		name        string
+		body        string
+		unsigned    bool
+		wantStatus  int
+		wantType    int
+		wantContent string
+		wantSent    int
+	}{
+		{name: "ping", body: `{"type":1,"id":"1"}`, wantStatus: http.StatusOK, wantType: callbackPong},
+		{name: "unsigned", body: `{"type":1,"id":"1"}`, unsigned: true, wantStatus: http.StatusUnauthorized},
+		{name: "malformed", body: `{"type":`, wantStatus: http.StatusBadRequest},
+		{name: "unsupported type", body: `{"type":3,"id":"1"}`, wantStatus: http.StatusBadRequest},
+		{name: "command without message", body: `{"type":2,"id":"1","data":{"name":"ask"}}`, wantStatus: http.StatusBadRequest},
+		{name: "command", body: command("hello bots"), wantStatus: http.StatusOK,
+			wantType: callbackChannelMessageWithSource, wantContent: "> hello bots", wantSent: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, chat := newTestService()
+			req := httptest.NewRequest(http.MethodPost, "/api/discord/interactions", strings.NewReader(tt.body))
+			if !tt.unsigned {
+				for k, v := range signHeader("1700000000", tt.body) {
+					req.Header[k] = v
+				}
+			}
+			w := httptest.NewRecorder()
+			s.ServeInteractions(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if len(chat.messages) != tt.wantSent {
+				t.Errorf("sent %d messages, want %d", len(chat.messages), tt.wantSent)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp interactionResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Type != tt.wantType {
+				t.Errorf("callback type = %d, want %d", resp.Type, tt.wantType)
+			}
+			if tt.wantContent != "" && (resp.Data == nil || resp.Data.Content != tt.wantContent) {
+				t.Errorf("callback data = %+v, want content %q", resp.Data, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestForward(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("messages share the channel's conversation", func(t *testing.T) {
+		s, chat := newTestService()
+		channel := uuid.New().String()
+		for i, id := range []string{"m1", "m2", "m3"} {
+			if err := s.forward(ctx, "g1", channel, "u1", id, fmt.Sprintf("message %d", i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := len(chat.conversations()); got != 1 {
+			t.Errorf("messages went to %d conversations, want 1", got)
+		}
+		m := chat.messages[1]
+		if m.ChannelID != ChannelID("g1", channel) || m.Platform != Platform || m.UserID != "u1" ||
+			m.Content != "message 1" || m.Type != "text" || m.ClientMessageID != "m2" {
+			t.Errorf("message = %+v", m)
+		}
+	})
+
+	t.Run("concurrent first messages", func(t *testing.T) {
+		s, chat := newTestService()
+		channel := uuid.New().String()
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- s.forward(ctx, "", channel, "u1", fmt.Sprintf("m%d", i), "hi")
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := len(chat.conversations()); got != 1 {
+			t.Errorf("messages went to %d conversations, want 1", got)
+		}
+		conversationID, err := getConversation(ctx, db, ChannelID("", channel))
+		if err != nil || !chat.conversations()[conversationID] {
+			t.Errorf("stored conversation = %q, %v", conversationID, err)
+		}
+	})
+}
+
+// fakeHTTPClient records Discord REST API requests
+type fakeHTTPClient struct {
+	requests []*http.Request
+	bodies   []string
+	status   int
+}
+
+func (f *fakeHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	f.requests = append(f.requests, req)
+	f.bodies = append(f.bodies, string(body))
+	status := f.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+func TestClientCreateMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		status    int
+		wantParts int
+		wantErr   bool
+	}{
+		{"short", "hello", 0, 1, false},
+		{"exactly the limit", strings.Repeat("é", maxMessageLength), 0, 1, false},
+		{"split", strings.Repeat("a", maxMessageLength*2+1), 0, 3, false},
+		{"rejected", "hello", http.StatusForbidden, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeHTTPClient{status: tt.status}
+			client := NewClient("https://discord.test/api/v10/", "token", fake)
+			err := client.CreateMessage(context.Background(), "c1", tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(fake.requests) != tt.wantParts {
+				t.Fatalf("sent %d requests, want %d", len(fake.requests), tt.wantParts)
+			}
+
+			var sent strings.Builder
+			for i, req := range fake.requests {
+				if req.URL.String() != "https://discord.test/api/v10/channels/c1/messages" {
+					t.Errorf("url = %s", req.URL)
+				}
+				if req.Header.Get("Authorization") != "Bot token" {
+					t.Errorf("authorization = %q", req.Header.Get("Authorization"))
+				}
+				var params CreateMessageParams
+				if err := json.Unmarshal([]byte(fake.bodies[i]), &params); err != nil {
+					t.Fatal(err)
+				}
+				if n := len([]rune(params.Content)); n > maxMessageLength {
+					t.Errorf("part %d has %d characters", i, n)
+				}
+				sent.WriteString(params.Content)
+			}
+			if !tt.wantErr && sent.String() != tt.content {
+				t.Error("parts don't add up to the content")
+			}
+		})
+	}
+}
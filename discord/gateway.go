@@ -0,0 +1,292 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"encore.dev/rlog"
+	"github.com/gorilla/websocket"
+)
+
+// Gateway opcodes
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opResume         = 6
+	opReconnect      = 7
+	opInvalidSession = 9
+	opHello          = 10
+	opHeartbeatAck   = 11
+)
+
+// Gateway intents needed to receive message content
+const (
+	intentGuildMessages  = 1 << 9
+	intentDirectMessages = 1 << 12
+	intentMessageContent = 1 << 15
+)
+
+// gatewayPayload represents a Gateway frame
+type gatewayPayload struct {
+	Op       int             `json:"op"`
+	Data     json.RawMessage `json:"d,omitempty"`
+	Sequence *int64          `json:"s,omitempty"`
+	Type     string          `json:"t,omitempty"`
+}
+
+// ready represents a READY dispatch, which starts a resumable session
+type ready struct {
+	SessionID        string `json:"session_id"`
+	ResumeGatewayURL string `json:"resume_gateway_url"`
+}
+
+// messageCreate represents a MESSAGE_CREATE dispatch
+type messageCreate struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id,omitempty"`
+	Content   string `json:"content"`
+	Author    struct {
+		ID  string `json:"id"`
+		Bot bool   `json:"bot,omitempty"`
+	} `json:"author"`
+}
+
+// errNoHeartbeatAck ends a session whose heartbeat went unacknowledged, as
+// the connection has stopped delivering events without closing
+var errNoHeartbeatAck = errors.New("heartbeat not acknowledged")
+
+// gateway keeps a Gateway connection open and hands MESSAGE_CREATE events to
+// onMessage. When a connection drops, it resumes the session so that the
+// Gateway replays the events missed in between.
+type gateway struct {
+	url       string
+	token     string
+	onMessage func(ctx context.Context, msg *messageCreate) error
+
+	// Session to resume, set by READY; only used by the run loop
+	sessionID string
+	resumeURL string
+
+	writeMu sync.Mutex
+	seqMu   sync.Mutex
+	seq     *int64
+	acked   atomic.Bool // the last heartbeat was acknowledged
+}
+
+// run connects to the Gateway and reconnects until ctx is cancelled
+func (g *gateway) run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		err := g.connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		rlog.Warn("discord gateway disconnected", "error", err, "retry_in", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// connect runs a single Gateway connection until it ends, resuming the
+// previous session if there is one
+func (g *gateway) connect(ctx context.Context) error {
+	c, _, err := websocket.DefaultDialer.DialContext(ctx, g.dialURL(), nil)
+	if err != nil {
+		return fmt.Errorf("dial gateway: %w", err)
+	}
+	defer c.Close()
+
+	// Close the connection when the service shuts down
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-sessionCtx.Done()
+		c.Close()
+	}()
+
+	var hello gatewayPayload
+	if err := c.ReadJSON(&hello); err != nil {
+		return fmt.Errorf("read hello: %w", err)
+	}
+	if hello.Op != opHello {
+		return fmt.Errorf("expected hello, got op %d", hello.Op)
+	}
+	var helloData struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	if err := json.Unmarshal(hello.Data, &helloData); err != nil {
+		return fmt.Errorf("decode hello: %w", err)
+	}
+
+	if g.sessionID != "" {
+		resume := map[string]interface{}{
+			"token":      g.token,
+			"session_id": g.sessionID,
+			"seq":        g.sequence(),
+		}
+		if err := g.send(c, opResume, resume); err != nil {
+			return fmt.Errorf("resume: %w", err)
+		}
+	} else {
+		identify := map[string]interface{}{
+			"token":   g.token,
+			"intents": intentGuildMessages | intentDirectMessages | intentMessageContent,
+			"properties": map[string]string{
+				"os":      "linux",
+				"browser": "genagent",
+				"device":  "genagent",
+			},
+		}
+		if err := g.send(c, opIdentify, identify); err != nil {
+			return fmt.Errorf("identify: %w", err)
+		}
+	}
+
+	g.acked.Store(true)
+	heartbeatErr := make(chan error, 1)
+	go func() {
+		heartbeatErr <- g.heartbeat(sessionCtx, c, time.Duration(helloData.HeartbeatInterval)*time.Millisecond)
+	}()
+
+	for {
+		var p gatewayPayload
+		if err := c.ReadJSON(&p); err != nil {
+			// A failed heartbeat closes the connection, failing the read
+			cancel()
+			if hbErr := <-heartbeatErr; hbErr != nil {
+				return hbErr
+			}
+			return fmt.Errorf("read payload: %w", err)
+		}
+		if p.Sequence != nil {
+			g.seqMu.Lock()
+			g.seq = p.Sequence
+			g.seqMu.Unlock()
+		}
+
+		switch p.Op {
+		case opDispatch:
+			if p.Type == "READY" {
+				var r ready
+				if err := json.Unmarshal(p.Data, &r); err != nil {
+					return fmt.Errorf("decode ready: %w", err)
+				}
+				g.sessionID, g.resumeURL = r.SessionID, r.ResumeGatewayURL
+				continue
+			}
+			if p.Type == "RESUMED" {
+				rlog.Info("discord gateway session resumed")
+				continue
+			}
+			if p.Type != "MESSAGE_CREATE" {
+				continue
+			}
+			var msg messageCreate
+			if err := json.Unmarshal(p.Data, &msg); err != nil {
+				rlog.Error("failed to decode discord message", "error", err)
+				continue
+			}
+			if err := g.onMessage(sessionCtx, &msg); err != nil {
+				rlog.Error("failed to handle discord message", "message_id", msg.ID, "error", err)
+			}
+		case opHeartbeat:
+			if err := g.send(c, opHeartbeat, g.sequence()); err != nil {
+				return fmt.Errorf("heartbeat: %w", err)
+			}
+		case opReconnect:
+			return fmt.Errorf("gateway requested reconnect")
+		case opInvalidSession:
+			// The data tells whether the session can still be resumed
+			var resumable bool
+			json.Unmarshal(p.Data, &resumable)
+			if !resumable {
+				g.resetSession()
+			}
+			return fmt.Errorf("gateway invalidated session (resumable: %t)", resumable)
+		case opHeartbeatAck:
+			g.acked.Store(true)
+		}
+	}
+}
+
+// dialURL returns the URL to connect to: the session's resume URL, with the
+// configured URL's query, when there is a session to resume
+func (g *gateway) dialURL() string {
+	if g.sessionID == "" || g.resumeURL == "" {
+		return g.url
+	}
+	base, err := url.Parse(g.url)
+	if err != nil {
+		return g.url
+	}
+	resume, err := url.Parse(g.resumeURL)
+	if err != nil {
+		return g.url
+	}
+	resume.RawQuery = base.RawQuery
+	return resume.String()
+}
+
+// resetSession forgets the session, so the next connection identifies afresh
+func (g *gateway) resetSession() {
+	g.sessionID, g.resumeURL = "", ""
+	g.seqMu.Lock()
+	g.seq = nil
+	g.seqMu.Unlock()
+}
+
+// heartbeat sends heartbeats at the interval requested by the Gateway. If a
+// heartbeat is still unacknowledged when the next is due, it closes the
+// connection and returns errNoHeartbeatAck.
+func (g *gateway) heartbeat(ctx context.Context, c *websocket.Conn, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !g.acked.Swap(false) {
+				c.Close()
+				return errNoHeartbeatAck
+			}
+			if err := g.send(c, opHeartbeat, g.sequence()); err != nil {
+				c.Close()
+				return fmt.Errorf("heartbeat: %w", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// sequence returns the last sequence number received
+func (g *gateway) sequence() *int64 {
+	g.seqMu.Lock()
+	defer g.seqMu.Unlock()
+	return g.seq
+}
+
+// send writes a frame, serializing writes from the read loop and heartbeat
+func (g *gateway) send(c *websocket.Conn, op int, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	return c.WriteJSON(gatewayPayload{Op: op, Data: raw})
+}
@@ -0,0 +1,235 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeGateway serves each Gateway connection with the next of its scripts
+type fakeGateway struct {
+	t       *testing.T
+	scripts []func(c *websocket.Conn, r *http.Request)
+
+	mu    sync.Mutex
+	conns int
+}
+
+func startFakeGateway(t *testing.T, scripts ...func(c *websocket.Conn, r *http.Request)) (*fakeGateway, string) {
+	f := &fakeGateway{t: t, scripts: scripts}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func (f *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	n := f.conns
+	f.conns++
+	f.mu.Unlock()
+	if n >= len(f.scripts) {
+		http.Error(w, "unexpected connection", http.StatusServiceUnavailable)
+		return
+	}
+
+	c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+	f.scripts[n](c, r)
+}
+
+// sendFrame writes a Gateway frame from the server
+func sendFrame(c *websocket.Conn, op int, seq int64, typ string, data interface{}) {
+	raw, _ := json.Marshal(data)
+	p := gatewayPayload{Op: op, Data: raw, Type: typ}
+	if seq > 0 {
+		p.Sequence = &seq
+	}
+	c.WriteJSON(p)
+}
+
+// hello starts a connection, returning the client's identify or resume frame
+func hello(t *testing.T, c *websocket.Conn, interval time.Duration) gatewayPayload {
+	sendFrame(c, opHello, 0, "", map[string]int64{"heartbeat_interval": interval.Milliseconds()})
+	return readFrame(t, c)
+}
+
+// readFrame reads the next frame that isn't a heartbeat
+func readFrame(t *testing.T, c *websocket.Conn) gatewayPayload {
+	for {
+		var p gatewayPayload
+		if err := c.ReadJSON(&p); err != nil {
+			t.Errorf("read client frame: %v", err)
+			return p
+		}
+		if p.Op != opHeartbeat {
+			return p
+		}
+	}
+}
+
+// drain reads from the client until it disconnects, acknowledging heartbeats
+func drain(c *websocket.Conn) {
+	for {
+		var p gatewayPayload
+		if err := c.ReadJSON(&p); err != nil {
+			return
+		}
+		if p.Op == opHeartbeat {
+			sendFrame(c, opHeartbeatAck, 0, "", nil)
+		}
+	}
+}
+
+func newTestGateway(url string) (*gateway, chan string) {
+	received := make(chan string, 10)
+	return &gateway{
+		url:   url + "/?v=10&encoding=json",
+		token: "token",
+		onMessage: func(_ context.Context, msg *messageCreate) error {
+			received <- msg.ID
+			return nil
+		},
+	}, received
+}
+
+func TestGatewayResume(t *testing.T) {
+	var resumeURL string
+	_, url := startFakeGateway(t,
+		func(c *websocket.Conn, _ *http.Request) {
+			if p := hello(t, c, time.Minute); p.Op != opIdentify {
+				t.Errorf("first connection sent op %d, want identify", p.Op)
+			}
+			sendFrame(c, opDispatch, 1, "READY", ready{SessionID: "session", ResumeGatewayURL: resumeURL})
+			sendFrame(c, opDispatch, 2, "MESSAGE_CREATE", messageCreate{ID: "m1", Content: "hi"})
+			sendFrame(c, opReconnect, 0, "", nil)
+			drain(c)
+		},
+		func(c *websocket.Conn, r *http.Request) {
+			if r.URL.Path != "/resume" || r.URL.Query().Get("v") != "10" {
+				t.Errorf("resumed on %s, want the resume URL with the configured query", r.URL)
+			}
+			p := hello(t, c, time.Minute)
+			var resume struct {
+				Token     string `json:"token"`
+				SessionID string `json:"session_id"`
+				Seq       int64  `json:"seq"`
+			}
+			json.Unmarshal(p.Data, &resume)
+			if p.Op != opResume || resume.SessionID != "session" || resume.Seq != 2 || resume.Token != "token" {
+				t.Errorf("second connection sent op %d %+v, want resume of session at 2", p.Op, resume)
+			}
+			sendFrame(c, opDispatch, 3, "RESUMED", nil)
+			sendFrame(c, opDispatch, 4, "MESSAGE_CREATE", messageCreate{ID: "m2", Content: "missed"})
+			drain(c)
+		},
+	)
+	resumeURL = url + "/resume"
+
+	g, received := newTestGateway(url)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := g.connect(ctx); err == nil {
+		t.Fatal("connection ended without an error")
+	}
+	if got := <-received; got != "m1" {
+		t.Errorf("received %s, want m1", got)
+	}
+
+	done := make(chan error)
+	go func() { done <- g.connect(ctx) }()
+	select {
+	case got := <-received:
+		if got != "m2" {
+			t.Errorf("received %s, want m2", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no message after resuming")
+	}
+	cancel()
+	<-done
+}
+
+func TestGatewayInvalidSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		resumable   bool
+		wantSession string
+	}{
+		{"resumable", true, "session"},
+		{"not resumable", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, url := startFakeGateway(t, func(c *websocket.Conn, _ *http.Request) {
+				hello(t, c, time.Minute)
+				sendFrame(c, opDispatch, 1, "READY", ready{SessionID: "session", ResumeGatewayURL: "wss://resume.test"})
+				sendFrame(c, opInvalidSession, 0, "", tt.resumable)
+				drain(c)
+			})
+			g, _ := newTestGateway(url)
+			if err := g.connect(context.Background()); err == nil {
+				t.Fatal("connection ended without an error")
+			}
+			if g.sessionID != tt.wantSession {
+				t.Errorf("session = %q, want %q", g.sessionID, tt.wantSession)
+			}
+			if tt.wantSession == "" && (g.sequence() != nil || g.dialURL() != g.url) {
+				t.Error("session not forgotten")
+			}
+		})
+	}
+}
+
+func TestGatewayHeartbeatAck(t *testing.T) {
+	tests := []struct {
+		name     string
+		ack      bool
+		wantErr  error
+		deadline time.Duration
+	}{
+		{"acknowledged", true, nil, 200 * time.Millisecond},
+		{"zombie connection", false, errNoHeartbeatAck, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, url := startFakeGateway(t, func(c *websocket.Conn, _ *http.Request) {
+				hello(t, c, 20*time.Millisecond)
+				if tt.ack {
+					drain(c)
+					return
+				}
+				for {
+					if _, _, err := c.ReadMessage(); err != nil {
+						return
+					}
+				}
+			})
+			g, _ := newTestGateway(url)
+			ctx, cancel := context.WithTimeout(context.Background(), tt.deadline)
+			defer cancel()
+
+			err := g.connect(ctx)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if errors.Is(err, errNoHeartbeatAck) || ctx.Err() == nil {
+				t.Errorf("connection ended before it was cancelled: %v", err)
+			}
+		})
+	}
+}
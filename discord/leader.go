@@ -0,0 +1,98 @@
+package discord
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"encore.dev/rlog"
+)
+
+// gatewayLock names the advisory lock held by the instance running the Gateway
+const gatewayLock = "discord-gateway"
+
+// How often a follower tries to take the lock, and the leader checks it still holds it
+const (
+	leaderRetryInterval = 30 * time.Second
+	leaderCheckInterval = 10 * time.Second
+)
+
+// runAsLeader runs fn on one instance at a time. Each instance tries to take a
+// Postgres session advisory lock; the one holding it runs fn, and the others
+// retry so one of them takes over if the leader stops or loses its database
+// connection, which releases the lock. fn must return once its ctx is done.
+func runAsLeader(ctx context.Context, lock string, fn func(ctx context.Context)) {
+	for {
+		err := leadOnce(ctx, lock, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			rlog.Warn("failed to take leader lock", "lock", lock, "error", err)
+		}
+
+		select {
+		case <-time.After(leaderRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// leadOnce takes the lock if it is free and runs fn until ctx is done or the
+// lock's connection fails
+func leadOnce(ctx context.Context, lock string, fn func(ctx context.Context)) error {
+	conn, err := db.Stdlib().Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	var acquired bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, lock).Scan(&acquired)
+	if err != nil {
+		return fmt.Errorf("try lock: %w", err)
+	}
+	if !acquired {
+		return nil
+	}
+	defer unlock(conn, lock)
+	rlog.Info("took leader lock", "lock", lock)
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(leaderCtx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The lock is lost with its session, so stop leading if the connection fails
+	ticker := time.NewTicker(leaderCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := conn.PingContext(ctx); err != nil {
+				return fmt.Errorf("lost leader lock: %w", err)
+			}
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// unlock releases the lock, so another instance can take over straight away
+func unlock(conn *sql.Conn, lock string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, lock); err != nil {
+		rlog.Warn("failed to release leader lock", "lock", lock, "error", err)
+	}
+}
@@ -0,0 +1,9 @@
+-- Create channels table mapping Discord channels to chat conversations
+CREATE TABLE channels (
+    channel_id TEXT PRIMARY KEY, -- guild_id:channel_id
+    guild_id TEXT NOT NULL,
+    discord_channel_id TEXT NOT NULL,
+    conversation_id TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
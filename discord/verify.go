@@ -0,0 +1,38 @@
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// Discord interaction signing headers
+const (
+	signatureHeader = "X-Signature-Ed25519"
+	timestampHeader = "X-Signature-Timestamp"
+)
+
+// VerifyRequest checks the ed25519 signature of an inbound interaction request
+func VerifyRequest(publicKey string, header http.Header, body []byte) error {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("public key is not configured")
+	}
+
+	sig, err := hex.DecodeString(header.Get(signatureHeader))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid request signature")
+	}
+
+	timestamp := header.Get(timestampHeader)
+	if timestamp == "" {
+		return fmt.Errorf("missing request timestamp")
+	}
+
+	msg := append([]byte(timestamp), body...)
+	if !ed25519.Verify(ed25519.PublicKey(key), msg, sig) {
+		return fmt.Errorf("invalid request signature")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+// testPublicKey and testPrivateKey sign test interactions
+var testPublicKey, testPrivateKey, _ = ed25519.GenerateKey(nil)
+
+// signHeader returns the signing headers Discord sends with a body
+func signHeader(timestamp, body string) http.Header {
+	sig := ed25519.Sign(testPrivateKey, []byte(timestamp+body))
+	h := http.Header{}
+	h.Set(signatureHeader, hex.EncodeToString(sig))
+	h.Set(timestampHeader, timestamp)
+	return h
+}
+
+func TestVerifyRequest(t *testing.T) {
+	const body = `{"type":1}`
+	publicKey := hex.EncodeToString(testPublicKey)
+	otherKey, _, _ := ed25519.GenerateKey(nil)
+
+	tests := []struct {
+		name      string
+		publicKey string
+		header    http.Header
+		body      string
+		wantErr   bool
+	}{
+		{"valid", publicKey, signHeader("1700000000", body), body, false},
+		{"no public key", "", signHeader("1700000000", body), body, true},
+		{"other public key", hex.EncodeToString(otherKey), signHeader("1700000000", body), body, true},
+		{"no signature", publicKey, http.Header{timestampHeader: {"1700000000"}}, body, true},
+		{"malformed signature", publicKey, http.Header{signatureHeader: {"zz"}, timestampHeader: {"1700000000"}}, body, true},
+		{"no timestamp", publicKey, http.Header{signatureHeader: signHeader("", body)[signatureHeader]}, body, true},
+		{"tampered body", publicKey, signHeader("1700000000", body), `{"type":2}`, true},
+		{"other timestamp", publicKey, func() http.Header {
+			h := signHeader("1700000000", body)
+			h.Set(timestampHeader, "1700000001")
+			return h
+		}(), body, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyRequest(tt.publicKey, tt.header, []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
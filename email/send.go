@@ -30,7 +30,7 @@ func (s *Service) Send(ctx context.Context, params *SendParams) (*SendResponse,
 		return nil, &errs.Error{Code: errs.InvalidArgument, Message: fmt.Sprintf("invalid template ID: %v", err)}
 	}
 
-	if err := s.sendMail(tmpl.html, tmpl.text, params); err != nil {
+	if err := s.sendMail(tmpl, params); err != nil {
 		return nil, &errs.Error{Code: errs.Internal, Message: fmt.Sprintf("failed to send email: %v", err)}
 	}
 
@@ -3,6 +3,7 @@ package email
 import (
 	"bytes"
 	"fmt"
+	htmltemplate "html/template"
 	"os"
 	"path/filepath"
 	"strings"
@@ -42,11 +43,17 @@ type Service struct {
 	templates map[string]*templatePair
 }
 
+// templatePair holds an email's HTML and text bodies. HTML templates are
+// parsed with html/template, so values such as a candidate's name can't
+// inject markup into the email.
 type templatePair struct {
-	html *template.Template
+	html *htmltemplate.Template
 	text *template.Template
 }
 
+// templatesDir is the directory templates are loaded from, relative to the app root
+const templatesDir = "email/templates"
+
 func initService() (*Service, error) {
 	// Use default config for now
 	cfg := Config{
@@ -61,7 +68,7 @@ func initService() (*Service, error) {
 	}
 
 	// Load templates
-	if err := svc.loadTemplates(); err != nil {
+	if err := svc.loadTemplates(templatesDir); err != nil {
 		return nil, fmt.Errorf("failed to load templates: %w", err)
 	}
 
@@ -69,9 +76,8 @@ func initService() (*Service, error) {
 }
 
 // loadTemplates loads all templates from the templates directory
-func (s *Service) loadTemplates() error {
-	templatesDir := "email/templates"
-	htmlTemplates := make(map[string]*template.Template)
+func (s *Service) loadTemplates(templatesDir string) error {
+	htmlTemplates := make(map[string]*htmltemplate.Template)
 	textTemplates := make(map[string]*template.Template)
 
 	// Walk through templates directory
@@ -102,7 +108,7 @@ func (s *Service) loadTemplates() error {
 		// Parse template based on file suffix
 		rlog.Info("checking file", "path", path)
 		if strings.HasSuffix(path, ".go.html") {
-			tmpl, err := template.ParseFiles(path)
+			tmpl, err := htmltemplate.ParseFiles(path)
 			if err != nil {
 				rlog.Error("failed to parse HTML template", "path", path, "error", err)
 				return fmt.Errorf("failed to parse HTML template %s: %w", path, err)
@@ -156,7 +162,7 @@ func (s *Service) getTemplate(templateID string) (*templatePair, error) {
 	return tmpl, nil
 }
 
-func (s *Service) sendMail(htmlTmpl *template.Template, textTmpl *template.Template, params *SendParams) error {
+func (s *Service) sendMail(tmpl *templatePair, params *SendParams) error {
 	if s.cfg.Stub {
 		rlog.Info("SMTP stub mode - not sending email",
 			"from", params.From,
@@ -167,6 +173,49 @@ func (s *Service) sendMail(htmlTmpl *template.Template, textTmpl *template.Templ
 		return nil
 	}
 
+	htmlBody, textBody, err := renderMail(tmpl, params)
+	if err != nil {
+		return err
+	}
+
+	// Create SMTP client
+	server := mail.NewSMTPClient()
+	server.Host = s.cfg.Host
+	server.Port = s.cfg.Port
+	server.KeepAlive = false
+	server.ConnectTimeout = 10 * time.Second
+	server.SendTimeout = 10 * time.Second
+
+	smtpClient, err := server.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+
+	// Create email
+	email := mail.NewMSG()
+	email.SetFrom(params.From).
+		AddTo(params.To).
+		SetSubject(params.Subject).
+		SetBody(mail.TextPlain, textBody).
+		AddAlternative(mail.TextHTML, htmlBody)
+
+	// Send email
+	if err := email.Send(smtpClient); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	rlog.Info("Email sent successfully",
+		"from", params.From,
+		"to", params.To,
+		"subject", params.Subject,
+		"template", params.TemplateID,
+	)
+	return nil
+}
+
+// renderMail renders an email's HTML and text bodies, filling in defaults
+// and checking the fields its template requires
+func renderMail(tmpl *templatePair, params *SendParams) (string, string, error) {
 	// Initialize template data with defaults
 	templateData := map[string]interface{}{
 		"LogoURL":    "https://www.dietzgen.com/wp-content/uploads/2020/07/Check-PNG-Transparent-Image.png",
@@ -199,7 +248,7 @@ func (s *Service) sendMail(htmlTmpl *template.Template, textTmpl *template.Templ
 		}
 		for _, field := range required {
 			if _, ok := templateData[field]; !ok {
-				return fmt.Errorf("missing required field for reminder template: %s", field)
+				return "", "", fmt.Errorf("missing required field for reminder template: %s", field)
 			}
 		}
 
@@ -223,7 +272,7 @@ func (s *Service) sendMail(htmlTmpl *template.Template, textTmpl *template.Templ
 		}
 		for _, field := range required {
 			if _, ok := templateData[field]; !ok {
-				return fmt.Errorf("missing required field for consent template: %s", field)
+				return "", "", fmt.Errorf("missing required field for consent template: %s", field)
 			}
 		}
 
@@ -246,54 +295,22 @@ func (s *Service) sendMail(htmlTmpl *template.Template, textTmpl *template.Templ
 		}
 		for _, field := range required {
 			if _, ok := templateData[field]; !ok {
-				return fmt.Errorf("missing required field for verification template: %s", field)
+				return "", "", fmt.Errorf("missing required field for verification template: %s", field)
 			}
 		}
 	}
 
 	// Create HTML body
 	var htmlBody bytes.Buffer
-	if err := htmlTmpl.Execute(&htmlBody, templateData); err != nil {
-		return fmt.Errorf("failed to execute HTML template: %w", err)
+	if err := tmpl.html.Execute(&htmlBody, templateData); err != nil {
+		return "", "", fmt.Errorf("failed to execute HTML template: %w", err)
 	}
 
 	// Create text body
 	var textBody bytes.Buffer
-	if err := textTmpl.Execute(&textBody, templateData); err != nil {
-		return fmt.Errorf("failed to execute text template: %w", err)
-	}
-
-	// Create SMTP client
-	server := mail.NewSMTPClient()
-	server.Host = s.cfg.Host
-	server.Port = s.cfg.Port
-	server.KeepAlive = false
-	server.ConnectTimeout = 10 * time.Second
-	server.SendTimeout = 10 * time.Second
-
-	smtpClient, err := server.Connect()
-	if err != nil {
-		return fmt.Errorf("failed to connect to SMTP server: %w", err)
-	}
-
-	// Create email
-	email := mail.NewMSG()
-	email.SetFrom(params.From).
-		AddTo(params.To).
-		SetSubject(params.Subject).
-		SetBody(mail.TextPlain, textBody.String()).
-		AddAlternative(mail.TextHTML, htmlBody.String())
-
-	// Send email
-	if err := email.Send(smtpClient); err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+	if err := tmpl.text.Execute(&textBody, templateData); err != nil {
+		return "", "", fmt.Errorf("failed to execute text template: %w", err)
 	}
 
-	rlog.Info("Email sent successfully",
-		"from", params.From,
-		"to", params.To,
-		"subject", params.Subject,
-		"template", params.TemplateID,
-	)
-	return nil
+	return htmlBody.String(), textBody.String(), nil
 }
@@ -0,0 +1,95 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func loadTestTemplates(t *testing.T) *Service {
+	t.Helper()
+	s := &Service{templates: make(map[string]*templatePair)}
+	if err := s.loadTemplates("templates"); err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	return s
+}
+
+func TestRenderMail(t *testing.T) {
+	s := loadTestTemplates(t)
+
+	data := map[string]string{
+		"Token":   "token",
+		"Email":   "candidate@example.com",
+		"Path":    "consent",
+		"Name":    "Jane Doe",
+		"Company": "Acme",
+	}
+	for id, tmpl := range s.templates {
+		t.Run(id, func(t *testing.T) {
+			html, text, err := renderMail(tmpl, &SendParams{TemplateID: id, TemplateData: data})
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if html == "" || text == "" {
+				t.Errorf("empty body: html %d bytes, text %d bytes", len(html), len(text))
+			}
+		})
+	}
+}
+
+func TestRenderMailEscapesHTML(t *testing.T) {
+	s := loadTestTemplates(t)
+
+	const name = `<a href="https://evil.example">Jane</a>`
+	tests := []string{
+		"screening-research-request-v1",
+		"screening-verification-request-v1",
+	}
+	for _, id := range tests {
+		t.Run(id, func(t *testing.T) {
+			tmpl, err := s.getTemplate(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			html, text, err := renderMail(tmpl, &SendParams{
+				TemplateID:   id,
+				TemplateData: map[string]string{"Token": "token", "Email": "hr@example.com", "Name": name},
+			})
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if strings.Contains(html, name) {
+				t.Errorf("HTML body contains the unescaped name")
+			}
+			if !strings.Contains(html, "&lt;a href=") {
+				t.Errorf("HTML body does not contain the escaped name")
+			}
+			if !strings.Contains(text, name) {
+				t.Errorf("text body does not contain the name as given")
+			}
+		})
+	}
+}
+
+func TestRenderMailRequiredFields(t *testing.T) {
+	s := loadTestTemplates(t)
+
+	tests := []struct {
+		id   string
+		data map[string]string
+	}{
+		{"screening-reminder-notify-v1", map[string]string{"Token": "token", "Email": "a@example.com"}},
+		{"screening-verification-request-v1", map[string]string{"Token": "token"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			tmpl, err := s.getTemplate(tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := renderMail(tmpl, &SendParams{TemplateID: tt.id, TemplateData: tt.data}); err == nil {
+				t.Error("expected a missing field error")
+			}
+		})
+	}
+}
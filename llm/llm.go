@@ -86,8 +86,14 @@ func (s *Service) processGeneration(ctx context.Context, req *types.LLMRequestEv
 		return s.storeResponse(ctx, respEvent)
 	}
 
+	// Drop images the provider cannot handle
+	messages := req.Messages
+	if !types.SupportsVision(p) {
+		messages = withoutImages(messages)
+	}
+
 	// Generate response
-	response, err := p.GenerateResponse(ctx, req.Messages, req.Parameters)
+	response, err := p.GenerateResponse(ctx, messages, req.Parameters)
 	if err != nil {
 		// Publish error response
 		respEvent := types.NewLLMResponseEvent(req.RequestID, req.BotID, req.ConversationID, "", err)
@@ -107,6 +113,16 @@ func (s *Service) processGeneration(ctx context.Context, req *types.LLMRequestEv
 	return s.storeResponse(ctx, respEvent)
 }
 
+// withoutImages returns a copy of messages with all images removed
+func withoutImages(messages []types.Message) []types.Message {
+	stripped := make([]types.Message, len(messages))
+	for i, msg := range messages {
+		msg.Images = nil
+		stripped[i] = msg
+	}
+	return stripped
+}
+
 // storeResponse stores the response in the database
 func (s *Service) storeResponse(ctx context.Context, resp *types.LLMResponseEvent) error {
 	query := `
@@ -161,7 +177,8 @@ func (s *Service) GenerateProviderResponse(ctx context.Context, messages []types
 //
 //encore:api public method=POST path=/api/llm/process
 func (s *Service) ProcessRequest(ctx context.Context, req *types.LLMRequestEvent) error {
-	// Store request in database first
+	// Store request in database first. Chat resends a request it is unsure was
+	// delivered under the same ID, so a known request is published again.
 	messagesJSON, err := json.Marshal(req.Messages)
 	if err != nil {
 		return fmt.Errorf("marshal messages: %w", err)
@@ -177,6 +194,7 @@ func (s *Service) ProcessRequest(ctx context.Context, req *types.LLMRequestEvent
 			request_id, bot_id, channel_id, conversation_id,
 			provider, messages, parameters, created_at
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (request_id) DO NOTHING
 	`
 	_, err = s.DB.ExecContext(ctx, query,
 		req.RequestID,
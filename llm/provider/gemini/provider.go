@@ -3,15 +3,27 @@ package gemini
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
 
 	"encore.app/llm/types"
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
 
+// Limits on fetching images to send inline
+const (
+	maxImageSize      = 10 << 20 // the chat service's default image upload cap
+	imageFetchTimeout = 30 * time.Second
+)
+
 type Provider struct {
-	client *genai.Client
-	model  *genai.GenerativeModel
+	client      *genai.Client
+	model       *genai.GenerativeModel
+	visionModel *genai.GenerativeModel
+	http        *http.Client
 }
 
 type Factory struct{}
@@ -28,9 +40,12 @@ func (f *Factory) Create(apiKey string) (types.Provider, error) {
 	}
 
 	model := client.GenerativeModel("gemini-pro")
+	visionModel := client.GenerativeModel("gemini-1.5-flash")
 	return &Provider{
-		client: client,
-		model:  model,
+		client:      client,
+		model:       model,
+		visionModel: visionModel,
+		http:        &http.Client{Timeout: imageFetchTimeout},
 	}, nil
 }
 
@@ -38,21 +53,34 @@ func (p *Provider) Name() string {
 	return "gemini"
 }
 
+func (p *Provider) SupportsVision() bool {
+	return true
+}
+
 func (p *Provider) GenerateResponse(ctx context.Context, messages []types.Message, params types.Parameters) (string, error) {
 	// Convert messages to Gemini format
+	model := p.model
 	var prompt []genai.Part
 	for _, msg := range messages {
 		prompt = append(prompt, genai.Text(msg.Content))
+		for _, img := range msg.Images {
+			blob, err := p.fetchImage(ctx, img)
+			if err != nil {
+				return "", fmt.Errorf("fetch image: %w", err)
+			}
+			prompt = append(prompt, blob)
+			model = p.visionModel
+		}
 	}
 
 	// Configure generation parameters
-	p.model.SetTemperature(float32(params.Temperature))
+	model.SetTemperature(float32(params.Temperature))
 	if params.MaxTokens > 0 {
-		p.model.SetMaxOutputTokens(int32(params.MaxTokens))
+		model.SetMaxOutputTokens(int32(params.MaxTokens))
 	}
 
 	// Generate response
-	resp, err := p.model.GenerateContent(ctx, prompt...)
+	resp, err := model.GenerateContent(ctx, prompt...)
 	if err != nil {
 		return "", fmt.Errorf("gemini generate: %w", err)
 	}
@@ -63,3 +91,35 @@ func (p *Provider) GenerateResponse(ctx context.Context, messages []types.Messag
 
 	return fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0]), nil
 }
+
+// fetchImage downloads an image so it can be sent inline, as Gemini does not fetch URLs
+func (p *Provider) fetchImage(ctx context.Context, img types.Image) (genai.Blob, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, img.URL, nil)
+	if err != nil {
+		return genai.Blob{}, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return genai.Blob{}, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return genai.Blob{}, fmt.Errorf("image returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return genai.Blob{}, fmt.Errorf("read image: %w", err)
+	}
+	if len(data) > maxImageSize {
+		return genai.Blob{}, fmt.Errorf("image larger than %d bytes", maxImageSize)
+	}
+
+	contentType := img.ContentType
+	if contentType == "" {
+		contentType = resp.Header.Get("Content-Type")
+	}
+	return genai.ImageData(strings.TrimPrefix(contentType, "image/"), data), nil
+}
@@ -0,0 +1,44 @@
+package gemini
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"encore.app/llm/types"
+)
+
+func TestFetchImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		size    int
+		wantErr bool
+	}{
+		{"image", http.StatusOK, 1024, false},
+		{"at the limit", http.StatusOK, maxImageSize, false},
+		{"too large", http.StatusOK, maxImageSize + 1, true},
+		{"not found", http.StatusNotFound, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(strings.Repeat("a", tt.size)))
+			}))
+			defer srv.Close()
+
+			p := &Provider{http: &http.Client{Timeout: imageFetchTimeout}}
+			blob, err := p.fetchImage(context.Background(), types.Image{URL: srv.URL})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (len(blob.Data) != tt.size || blob.MIMEType != "image/png") {
+				t.Errorf("blob = %s with %d bytes, want image/png with %d", blob.MIMEType, len(blob.Data), tt.size)
+			}
+		})
+	}
+}
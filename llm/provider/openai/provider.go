@@ -29,20 +29,48 @@ func (p *Provider) Name() string {
 	return "openai"
 }
 
+func (p *Provider) SupportsVision() bool {
+	return true
+}
+
 func (p *Provider) GenerateResponse(ctx context.Context, messages []types.Message, params types.Parameters) (string, error) {
 	// Convert messages to OpenAI format
+	model := openai.GPT3Dot5Turbo
 	openaiMessages := make([]openai.ChatCompletionMessage, len(messages))
 	for i, msg := range messages {
+		if len(msg.Images) == 0 {
+			openaiMessages[i] = openai.ChatCompletionMessage{
+				Role:    msg.Role,
+				Content: msg.Content,
+			}
+			continue
+		}
+
+		// Images require multi-part content and a vision-capable model
+		model = openai.GPT4oMini
+		parts := []openai.ChatMessagePart{}
+		if msg.Content != "" {
+			parts = append(parts, openai.ChatMessagePart{
+				Type: openai.ChatMessagePartTypeText,
+				Text: msg.Content,
+			})
+		}
+		for _, img := range msg.Images {
+			parts = append(parts, openai.ChatMessagePart{
+				Type:     openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{URL: img.URL},
+			})
+		}
 		openaiMessages[i] = openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
+			Role:         msg.Role,
+			MultiContent: parts,
 		}
 	}
 
 	resp, err := p.client.CreateChatCompletion(
 		ctx,
 		openai.ChatCompletionRequest{
-			Model:       openai.GPT3Dot5Turbo,
+			Model:       model,
 			Messages:    openaiMessages,
 			MaxTokens:   params.MaxTokens,
 			Temperature: float32(params.Temperature),
@@ -7,8 +7,15 @@ import (
 
 // Message represents a chat message
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string  `json:"role"`
+	Content string  `json:"content"`
+	Images  []Image `json:"images,omitempty"`
+}
+
+// Image represents an image attached to a message
+type Image struct {
+	URL         string `json:"url"` // fetchable URL, typically signed and short-lived
+	ContentType string `json:"content_type,omitempty"`
 }
 
 // Parameters holds LLM generation parameters
@@ -23,6 +30,17 @@ type Provider interface {
 	GenerateResponse(ctx context.Context, messages []Message, params Parameters) (string, error)
 }
 
+// VisionProvider is implemented by providers that accept images in messages
+type VisionProvider interface {
+	SupportsVision() bool
+}
+
+// SupportsVision reports whether the provider accepts images in messages
+func SupportsVision(p Provider) bool {
+	v, ok := p.(VisionProvider)
+	return ok && v.SupportsVision()
+}
+
 // ProviderFactory creates Provider instances
 type ProviderFactory interface {
 	Create(apiKey string) (Provider, error)
@@ -0,0 +1,6 @@
+package local
+
+IdleTimeout: int | *300
+PresenceHeartbeat: int | *30
+TypingDebounce: int | *3
+TypingTimeout: int | *6 // must exceed TypingDebounce
@@ -0,0 +1,21 @@
+package local
+
+import (
+	"time"
+
+	"encore.dev/config"
+)
+
+type Config struct {
+	IdleTimeout       int // seconds without activity before a connected user is idle
+	PresenceHeartbeat int // seconds between presence reports; must be well under the chat presence TTL (90s)
+	TypingDebounce    int // seconds within which repeated typing signals are relayed once
+	TypingTimeout     int // seconds after the last typing signal before the user stops typing
+}
+
+var cfg = config.Load[*Config]()
+
+// seconds converts a config value in seconds to a duration
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
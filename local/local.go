@@ -4,13 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
+	"encore.dev/beta/auth"
 	"encore.dev/beta/errs"
 	"encore.dev/pubsub"
 	"encore.dev/rlog"
 	"github.com/google/uuid"
 
+	authsvc "encore.app/auth"
 	"encore.app/carriers"
 	"encore.app/chat"
 	chatpubsub "encore.app/chat/pubsub"
@@ -27,23 +30,27 @@ type Client struct {
 	JoinedAt time.Time `json:"joined_at"`
 }
 
-// Message represents a chat message from the client
-type Message struct {
-	Content        string `json:"content"`
-	ConversationID string `json:"conversation_id"`
-}
-
-// Connect establishes a WebSocket connection for the chat
+// Connect registers a WebSocket client for the authenticated user. The
+// returned client ID and resume token are then used to open the socket at
+// /api/ws/:client_id?resume_token=..., and messages sent over it are posted
+// as that user.
 //
-//encore:api public method=GET path=/api/local/connect
+//encore:api auth method=GET path=/api/local/connect
 func (s *Service) Connect(ctx context.Context) (*carriers.WebSocketResponse, error) {
+	uid, ok := auth.UserID()
+	if !ok {
+		return nil, &errs.Error{
+			Code:    errs.Unauthenticated,
+			Message: "not authenticated",
+		}
+	}
 	clientID := fmt.Sprintf("client_%s", uuid.New().String())
-	userID := fmt.Sprintf("user_%s", uuid.New().String())
 
 	// Create WebSocket handler
 	handler := &wsHandler{
 		clientID: clientID,
-		userID:   userID,
+		userID:   uid,
+		userData: auth.Data(),
 	}
 
 	return carriers.NewWebSocket(handler), nil
@@ -52,38 +59,261 @@ func (s *Service) Connect(ctx context.Context) (*carriers.WebSocketResponse, err
 // wsHandler implements the WebSocket message handler
 type wsHandler struct {
 	clientID string
-	userID   string
+	userID   auth.UID
+	userData any
+
+	mu         sync.Mutex
+	connected  bool                    // whether the client's connection is open
+	status     string                  // online or idle while connected
+	lastActive time.Time               // last command from the client
+	typing     map[string]*typingState // conversations the user is typing in
 }
 
-// OnMessage handles incoming WebSocket messages
+// OnMessage handles a command from the client and replies to it on the same
+// connection. Failures are replied as typed errors rather than returned.
 func (h *wsHandler) OnMessage(ctx context.Context, msg []byte) error {
-	// Parse message
-	var chatMsg Message
-	if err := json.Unmarshal(msg, &chatMsg); err != nil {
-		return &errs.Error{
-			Code:    errs.InvalidArgument,
-			Message: "invalid message format",
+	h.active()
+
+	var cmd Command
+	if err := json.Unmarshal(msg, &cmd); err != nil {
+		return h.reply(&Reply{Type: ReplyError, Error: newError(invalidArgument("invalid command format"))})
+	}
+
+	// The socket itself is unauthenticated, so call the chat service as the
+	// user who registered the client
+	ctx = auth.WithContext(ctx, h.userID, h.userData)
+
+	result, err := h.handle(ctx, &cmd)
+	reply := &Reply{Type: ReplyResult, Command: cmd.Type, RequestID: cmd.RequestID, Result: result}
+	if err != nil {
+		reply = &Reply{Type: ReplyError, Command: cmd.Type, RequestID: cmd.RequestID, Error: newError(err)}
+	}
+	return h.reply(reply)
+}
+
+// handle runs a command and returns its result
+func (h *wsHandler) handle(ctx context.Context, cmd *Command) (any, error) {
+	switch cmd.Type {
+	case CommandSend:
+		var p SendPayload
+		if err := decodePayload(cmd, &p); err != nil {
+			return nil, err
+		}
+		return h.send(ctx, &p)
+	case CommandTyping:
+		var p TypingPayload
+		if err := decodePayload(cmd, &p); err != nil {
+			return nil, err
+		}
+		return nil, h.setTyping(ctx, &p)
+	case CommandJoin:
+		var p ConversationPayload
+		if err := decodePayload(cmd, &p); err != nil {
+			return nil, err
+		}
+		return h.join(ctx, p.ConversationID)
+	case CommandLeave:
+		var p ConversationPayload
+		if err := decodePayload(cmd, &p); err != nil {
+			return nil, err
+		}
+		if err := carriers.Leave(h.clientID, p.ConversationID); err != nil {
+			return nil, err
+		}
+		h.left(p.ConversationID)
+		return &ConversationResult{ConversationID: p.ConversationID}, nil
+	case CommandEdit:
+		var p EditPayload
+		if err := decodePayload(cmd, &p); err != nil {
+			return nil, err
+		}
+		return h.edit(ctx, &p)
+	case CommandAck:
+		var p AckPayload
+		if err := decodePayload(cmd, &p); err != nil {
+			return nil, err
+		}
+		if p.ConversationID == "" {
+			return nil, invalidArgument("conversation_id is required")
+		}
+		return nil, carriers.Ack(h.clientID, p.ConversationID, p.Sequence)
+	case CommandListHistory:
+		var p ListHistoryPayload
+		if err := decodePayload(cmd, &p); err != nil {
+			return nil, err
+		}
+		return h.listHistory(ctx, &p)
+	default:
+		return nil, invalidArgument(fmt.Sprintf("unknown command type: %q", cmd.Type))
+	}
+}
+
+// decodePayload parses a command's payload
+func decodePayload(cmd *Command, payload any) error {
+	if len(cmd.Payload) == 0 {
+		return invalidArgument("payload is required")
+	}
+	if err := json.Unmarshal(cmd.Payload, payload); err != nil {
+		return invalidArgument(fmt.Sprintf("invalid %s payload: %v", cmd.Type, err))
+	}
+	return nil
+}
+
+// send posts a chat message, joining its conversation so the client receives the replies
+func (h *wsHandler) send(ctx context.Context, p *SendPayload) (*MessageResult, error) {
+	if p.Content == "" {
+		return nil, invalidArgument("content is required")
+	}
+
+	// Join before sending so that no event about the message is missed
+	if p.ConversationID != "" {
+		if _, err := h.join(ctx, p.ConversationID); err != nil {
+			return nil, err
 		}
 	}
 
 	// Create chat message
 	message := &types.Message{
-		ID:             fmt.Sprintf("msg_%s", uuid.New().String()),
-		ConversationID: chatMsg.ConversationID,
-		Platform:       "local",
-		ChannelID:      "local",
-		UserID:         h.userID,
-		Content:        chatMsg.Content,
-		Type:           "text",
-		CreatedAt:      time.Now(),
+		ID:              fmt.Sprintf("msg_%s", uuid.New().String()),
+		ConversationID:  p.ConversationID,
+		Platform:        "local",
+		ChannelID:       "local",
+		UserID:          string(h.userID),
+		ParentID:        p.ParentID,
+		ClientMessageID: p.ClientMessageID,
+		Content:         p.Content,
+		Type:            "text",
+		CreatedAt:       time.Now(),
 	}
 
 	// Send message through chat service
-	if _, err := chat.SendMessage(ctx, message); err != nil {
-		return fmt.Errorf("send message: %w", err)
+	sent, err := chat.SendMessage(ctx, message)
+	if err != nil {
+		return nil, fmt.Errorf("send message: %w", err)
 	}
 
-	return nil
+	// A new conversation can only be joined once it exists
+	if p.ConversationID == "" {
+		if err := carriers.Join(h.clientID, sent.ConversationID); err != nil {
+			return nil, err
+		}
+		h.joined(sent.ConversationID)
+	}
+
+	// The message ends the user's typing
+	h.stopTyping(sent.ConversationID)
+
+	return &MessageResult{Message: sent}, nil
+}
+
+// setTyping relays that the user started or stopped typing. Clients should
+// repeat active typing signals while the user types; they are debounced.
+func (h *wsHandler) setTyping(ctx context.Context, p *TypingPayload) error {
+	if p.ConversationID == "" {
+		return invalidArgument("conversation_id is required")
+	}
+	// Only members can type in a conversation
+	if _, err := chat.GetConversation(ctx, p.ConversationID); err != nil {
+		return err
+	}
+	if !p.Active {
+		return h.stopTyping(p.ConversationID)
+	}
+	return h.startTyping(p.ConversationID, p.ParentID)
+}
+
+// join subscribes the client to a conversation the user is a member of
+func (h *wsHandler) join(ctx context.Context, conversationID string) (*ConversationResult, error) {
+	if conversationID == "" {
+		return nil, invalidArgument("conversation_id is required")
+	}
+	// Only members can read a conversation
+	if _, err := chat.GetConversation(ctx, conversationID); err != nil {
+		return nil, err
+	}
+	if err := carriers.Join(h.clientID, conversationID); err != nil {
+		return nil, err
+	}
+	h.joined(conversationID)
+	return &ConversationResult{ConversationID: conversationID}, nil
+}
+
+// edit changes the content of one of the user's messages
+func (h *wsHandler) edit(ctx context.Context, p *EditPayload) (*MessageResult, error) {
+	if p.MessageID == "" {
+		return nil, invalidArgument("message_id is required")
+	}
+	if p.Content == "" {
+		return nil, invalidArgument("content is required")
+	}
+	edited, err := chat.EditMessage(ctx, p.MessageID, &chat.EditMessageRequest{Content: p.Content})
+	if err != nil {
+		return nil, fmt.Errorf("edit message: %w", err)
+	}
+	return &MessageResult{Message: edited}, nil
+}
+
+// listHistory fetches a page of a conversation's messages
+func (h *wsHandler) listHistory(ctx context.Context, p *ListHistoryPayload) (*HistoryResult, error) {
+	if p.ConversationID == "" {
+		return nil, invalidArgument("conversation_id is required")
+	}
+	resp, err := chat.ListConversationMessages(ctx, p.ConversationID, &chat.ListMessagesParams{
+		Before: p.Before,
+		Limit:  p.Limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list history: %w", err)
+	}
+	return &HistoryResult{ConversationID: p.ConversationID, Messages: resp.Messages}, nil
+}
+
+// reply sends a reply to this client only
+func (h *wsHandler) reply(reply *Reply) error {
+	msg, err := json.Marshal(reply)
+	if err != nil {
+		return fmt.Errorf("marshal reply: %w", err)
+	}
+	return carriers.Send(h.clientID, msg)
+}
+
+// handlerKind names the local handler for restoring it on other instances
+const handlerKind = "local"
+
+// handlerState is the state a handler is restored from on another instance
+type handlerState struct {
+	UserID   auth.UID          `json:"user_id"`
+	UserData *authsvc.UserData `json:"user_data,omitempty"`
+}
+
+func init() {
+	carriers.RegisterHandler(handlerKind, restoreHandler)
+}
+
+// restoreHandler recreates a client's handler on another instance, so that
+// its socket can be opened on any of them
+func restoreHandler(clientID string, state []byte) (carriers.WebSocketHandler, error) {
+	var s handlerState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return nil, fmt.Errorf("unmarshal handler state: %w", err)
+	}
+	h := &wsHandler{clientID: clientID, userID: s.UserID}
+	if s.UserData != nil {
+		h.userData = s.UserData
+	}
+	return h, nil
+}
+
+// Kind returns the name the handler's restore function is registered under
+func (h *wsHandler) Kind() string {
+	return handlerKind
+}
+
+// State returns the state the handler is restored from
+func (h *wsHandler) State() ([]byte, error) {
+	s := handlerState{UserID: h.userID}
+	s.UserData, _ = h.userData.(*authsvc.UserData)
+	return json.Marshal(s)
 }
 
 // OnClose handles WebSocket connection closure
@@ -96,7 +326,7 @@ func (h *wsHandler) ClientID() string {
 	return h.clientID
 }
 
-// Initialize subscription for chat events
+// Initialize subscriptions for chat events, bot responses and typing and presence
 var _ = pubsub.NewSubscription(
 	chatpubsub.ChatEvents, "handle-local-chat",
 	pubsub.SubscriptionConfig[*types.ChatEvent]{
@@ -104,6 +334,20 @@ var _ = pubsub.NewSubscription(
 	},
 )
 
+var _ = pubsub.NewSubscription(
+	chatpubsub.ChatResponses, "handle-local-responses",
+	pubsub.SubscriptionConfig[*types.ResponseEvent]{
+		Handler: pubsub.MethodHandler((*Service).handleResponse),
+	},
+)
+
+var _ = pubsub.NewSubscription(
+	chatpubsub.PlatformEvents, "handle-local-platform",
+	pubsub.SubscriptionConfig[*types.PlatformEvent]{
+		Handler: pubsub.MethodHandler((*Service).handlePlatformEvent),
+	},
+)
+
 // handleChatEvent processes chat events and broadcasts them to local clients
 func (s *Service) handleChatEvent(ctx context.Context, event *types.ChatEvent) error {
 	// Only process local platform events
@@ -115,17 +359,56 @@ func (s *Service) handleChatEvent(ctx context.Context, event *types.ChatEvent) e
 	rlog.Info("handling chat event",
 		"event_type", event.Type,
 		"channel_id", event.ChannelID,
-		"message_id", event.Message.ID,
-		"conversation_id", event.Message.ConversationID,
 	)
 
-	// Broadcast message to all connected clients
+	if event.Message == nil {
+		return nil
+	}
+	return broadcast(event, event.Message.ConversationID, event.Sequence)
+}
+
+// handleResponse broadcasts bot responses to local clients
+func (s *Service) handleResponse(ctx context.Context, event *types.ResponseEvent) error {
+	if event.Platform != "local" {
+		return nil
+	}
+
+	rlog.Info("handling chat response",
+		"event_type", event.Type,
+		"channel_id", event.ChannelID,
+		"request_id", event.RequestID,
+	)
+
+	if event.Message == nil {
+		return nil
+	}
+	return broadcast(event, event.Message.ConversationID, event.Sequence)
+}
+
+// handlePlatformEvent broadcasts typing and presence changes to local clients
+func (s *Service) handlePlatformEvent(ctx context.Context, event *types.PlatformEvent) error {
+	if event.Platform != "local" {
+		return nil
+	}
+
+	// Typing and presence are transient, so not sequenced for replay
+	return broadcast(event, event.ConversationID, 0)
+}
+
+// broadcast sends an event to the clients that have joined its conversation.
+// Sequenced events are kept so that resuming clients can replay them.
+func broadcast(event interface{}, conversationID string, seq int64) error {
 	msg, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("marshal event: %w", err)
 	}
 
-	if err := carriers.Broadcast(msg); err != nil {
+	if seq > 0 {
+		err = carriers.BroadcastSequencedEvent(msg, conversationID, seq)
+	} else {
+		err = carriers.BroadcastEvent(msg, conversationID)
+	}
+	if err != nil {
 		return fmt.Errorf("broadcast message: %w", err)
 	}
 
@@ -0,0 +1,195 @@
+package local
+
+import (
+	"context"
+	"time"
+
+	"encore.dev/beta/auth"
+	"encore.dev/rlog"
+
+	"encore.app/carriers"
+	"encore.app/chat"
+	"encore.app/chat/types"
+)
+
+// typingState is the user's typing in one conversation, as relayed to others
+type typingState struct {
+	parentID string
+	sentAt   time.Time   // when typing was last relayed
+	stop     *time.Timer // relays that typing stopped once the client stops renewing it
+}
+
+// userContext returns a context for calling the chat service as the client's
+// user outside of a request
+func (h *wsHandler) userContext() context.Context {
+	return auth.WithContext(context.Background(), h.userID, h.userData)
+}
+
+// OnConnect marks the user online in the conversations the client has
+// joined, and keeps reporting their presence until the connection ends
+func (h *wsHandler) OnConnect(ctx context.Context) {
+	h.mu.Lock()
+	h.connected = true
+	h.status = types.PresenceOnline
+	h.lastActive = time.Now()
+	h.mu.Unlock()
+
+	h.reportAll(types.PresenceOnline)
+	go h.heartbeat(ctx)
+}
+
+// OnDisconnect marks the user offline in the conversations the client has joined
+func (h *wsHandler) OnDisconnect(ctx context.Context) {
+	h.mu.Lock()
+	h.connected = false
+	typing := h.typing
+	h.typing = nil
+	h.mu.Unlock()
+
+	for conversationID, t := range typing {
+		t.stop.Stop()
+		h.relayTyping(conversationID, t.parentID, false)
+	}
+	h.reportAll(types.PresenceOffline)
+}
+
+// heartbeat renews the client's presence and marks the user idle once they
+// have been inactive for the idle timeout
+func (h *wsHandler) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(seconds(cfg.PresenceHeartbeat))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.mu.Lock()
+			if h.status == types.PresenceOnline && time.Since(h.lastActive) >= seconds(cfg.IdleTimeout) {
+				h.status = types.PresenceIdle
+			}
+			status := h.status
+			h.mu.Unlock()
+
+			h.reportAll(status)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// active records activity by the user, bringing them back online if idle
+func (h *wsHandler) active() {
+	h.mu.Lock()
+	h.lastActive = time.Now()
+	wasIdle := h.status == types.PresenceIdle
+	if wasIdle {
+		h.status = types.PresenceOnline
+	}
+	h.mu.Unlock()
+
+	if wasIdle {
+		h.reportAll(types.PresenceOnline)
+	}
+}
+
+// joined reports the user's presence in a conversation the client joined
+func (h *wsHandler) joined(conversationID string) {
+	h.mu.Lock()
+	status := h.status
+	connected := h.connected
+	h.mu.Unlock()
+
+	if connected {
+		h.report(conversationID, status)
+	}
+}
+
+// left marks the user offline in a conversation the client left
+func (h *wsHandler) left(conversationID string) {
+	h.stopTyping(conversationID)
+	h.report(conversationID, types.PresenceOffline)
+}
+
+// reportAll reports the client's presence in every conversation it has joined
+func (h *wsHandler) reportAll(status string) {
+	conversations, err := carriers.Channels(h.clientID)
+	if err != nil {
+		// The client has expired
+		return
+	}
+	for _, conversationID := range conversations {
+		h.report(conversationID, status)
+	}
+}
+
+// report reports the client's presence in a conversation
+func (h *wsHandler) report(conversationID, status string) {
+	err := chat.SetPresence(h.userContext(), conversationID, &chat.PresenceRequest{
+		ClientID: h.clientID,
+		Status:   status,
+	})
+	if err != nil {
+		rlog.Error("failed to report presence",
+			"client_id", h.clientID,
+			"conversation_id", conversationID,
+			"error", err,
+		)
+	}
+}
+
+// startTyping relays that the user is typing, at most once per debounce
+// interval. Typing stops by itself unless renewed within the typing timeout.
+func (h *wsHandler) startTyping(conversationID, parentID string) error {
+	h.mu.Lock()
+	if h.typing == nil {
+		h.typing = make(map[string]*typingState)
+	}
+	t, ok := h.typing[conversationID]
+	if ok {
+		t.stop.Reset(seconds(cfg.TypingTimeout))
+		if t.parentID == parentID && time.Since(t.sentAt) < seconds(cfg.TypingDebounce) {
+			h.mu.Unlock()
+			return nil
+		}
+	} else {
+		t = &typingState{}
+		t.stop = time.AfterFunc(seconds(cfg.TypingTimeout), func() {
+			h.stopTyping(conversationID)
+		})
+		h.typing[conversationID] = t
+	}
+	t.parentID = parentID
+	t.sentAt = time.Now()
+	h.mu.Unlock()
+
+	return h.relayTyping(conversationID, parentID, true)
+}
+
+// stopTyping relays that the user stopped typing, if they were
+func (h *wsHandler) stopTyping(conversationID string) error {
+	h.mu.Lock()
+	t, ok := h.typing[conversationID]
+	delete(h.typing, conversationID)
+	h.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	t.stop.Stop()
+	return h.relayTyping(conversationID, t.parentID, false)
+}
+
+// relayTyping publishes the user's typing to the conversation
+func (h *wsHandler) relayTyping(conversationID, parentID string, active bool) error {
+	err := chat.SetTyping(h.userContext(), conversationID, &chat.TypingRequest{
+		ParentID: parentID,
+		Active:   active,
+	})
+	if err != nil {
+		rlog.Error("failed to relay typing",
+			"client_id", h.clientID,
+			"conversation_id", conversationID,
+			"error", err,
+		)
+	}
+	return err
+}
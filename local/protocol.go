@@ -0,0 +1,131 @@
+package local
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"encore.dev/beta/errs"
+
+	"encore.app/chat/types"
+)
+
+// Command types sent by clients
+const (
+	CommandSend        = "send"         // post a chat message
+	CommandTyping      = "typing"       // start or stop typing in a conversation
+	CommandJoin        = "join"         // receive the events of a conversation
+	CommandLeave       = "leave"        // stop receiving the events of a conversation
+	CommandEdit        = "edit"         // change the content of a message
+	CommandAck         = "ack"          // acknowledge the events of a conversation up to a sequence number
+	CommandListHistory = "list_history" // fetch a page of a conversation's messages
+)
+
+// Command is the envelope of every message a client sends
+type Command struct {
+	Type      string          `json:"type"`
+	RequestID string          `json:"request_id,omitempty"` // echoed on the reply
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// SendPayload is the payload of a send command
+type SendPayload struct {
+	ConversationID  string `json:"conversation_id,omitempty"` // omit to start a new conversation
+	Content         string `json:"content"`
+	ParentID        string `json:"parent_id,omitempty"`         // thread to reply in
+	ClientMessageID string `json:"client_message_id,omitempty"` // makes retries idempotent
+}
+
+// TypingPayload is the payload of a typing command
+type TypingPayload struct {
+	ConversationID string `json:"conversation_id"`
+	ParentID       string `json:"parent_id,omitempty"`
+	Active         bool   `json:"active"`
+}
+
+// ConversationPayload is the payload of join and leave commands
+type ConversationPayload struct {
+	ConversationID string `json:"conversation_id"`
+}
+
+// EditPayload is the payload of an edit command
+type EditPayload struct {
+	MessageID string `json:"message_id"`
+	Content   string `json:"content"`
+}
+
+// AckPayload is the payload of an ack command
+type AckPayload struct {
+	ConversationID string `json:"conversation_id"`
+	Sequence       int64  `json:"sequence"` // last event sequence number processed
+}
+
+// ListHistoryPayload is the payload of a list_history command
+type ListHistoryPayload struct {
+	ConversationID string `json:"conversation_id"`
+	Before         string `json:"before,omitempty"` // only messages created before this message
+	Limit          int    `json:"limit,omitempty"`  // only the most recent messages, or all if 0
+}
+
+// Reply types
+const (
+	ReplyResult = "result" // the command succeeded
+	ReplyError  = "error"  // the command failed
+)
+
+// Reply is the server's reply to a command, sent to the client that issued it
+type Reply struct {
+	Type      string `json:"type"` // result or error
+	Command   string `json:"command,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Result    any    `json:"result,omitempty"`
+	Error     *Error `json:"error,omitempty"`
+}
+
+// Error describes why a command failed
+type Error struct {
+	Code    errs.ErrCode `json:"code"` // e.g. invalid_argument, permission_denied, not_found
+	Message string       `json:"message"`
+}
+
+// MessageResult is the result of send and edit commands
+type MessageResult struct {
+	Message *types.Message `json:"message"`
+}
+
+// ConversationResult is the result of join and leave commands
+type ConversationResult struct {
+	ConversationID string `json:"conversation_id"`
+}
+
+// HistoryResult is the result of a list_history command
+type HistoryResult struct {
+	ConversationID string           `json:"conversation_id"`
+	Messages       []*types.Message `json:"messages"`
+}
+
+// invalidArgument returns an error for a malformed command
+func invalidArgument(msg string) error {
+	return &errs.Error{Code: errs.InvalidArgument, Message: msg}
+}
+
+// newError describes a failed command to the client
+func newError(err error) *Error {
+	code := errs.Code(err)
+	if code == errs.OK {
+		code = errs.Unknown
+	}
+	return &Error{Code: code, Message: err.Error()}
+}
+
+//go:embed protocol.schema.json
+var protocolSchema []byte
+
+// ProtocolSchema serves the JSON Schema of the commands clients send and the
+// replies they receive
+//
+//encore:api public raw method=GET path=/api/local/protocol/schema
+func (s *Service) ProtocolSchema(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(protocolSchema)
+}
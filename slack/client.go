@@ -0,0 +1,128 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Client is a minimal Slack Web API client
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient creates a Slack Web API client. Pass a nil httpClient to use the default client.
+func NewClient(baseURL, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    httpClient,
+	}
+}
+
+// APIError is returned when the Slack Web API reports a failure
+type APIError struct {
+	Method string
+	Code   string // e.g. already_reacted, channel_not_found
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Method, e.Code)
+}
+
+// PostMessageParams represents the parameters for chat.postMessage
+type PostMessageParams struct {
+	Channel  string `json:"channel"`
+	Text     string `json:"text"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+	Username string `json:"username,omitempty"`
+	IconURL  string `json:"icon_url,omitempty"`
+}
+
+// PostMessageResponse represents the response from chat.postMessage
+type PostMessageResponse struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// ReactionParams represents the parameters for reactions.add and reactions.remove
+type ReactionParams struct {
+	Channel   string `json:"channel"`
+	Timestamp string `json:"timestamp"`
+	Name      string `json:"name"`
+}
+
+// PostMessage posts a message to a channel or thread
+func (c *Client) PostMessage(ctx context.Context, params *PostMessageParams) (*PostMessageResponse, error) {
+	var resp PostMessageResponse
+	if err := c.call(ctx, "chat.postMessage", params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AddReaction adds a reaction to a message
+func (c *Client) AddReaction(ctx context.Context, params *ReactionParams) error {
+	return c.call(ctx, "reactions.add", params, nil)
+}
+
+// RemoveReaction removes a reaction from a message
+func (c *Client) RemoveReaction(ctx context.Context, params *ReactionParams) error {
+	return c.call(ctx, "reactions.remove", params, nil)
+}
+
+// call invokes a Web API method and decodes its response into out
+func (c *Client) call(ctx context.Context, method string, params, out interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("marshal %s request: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create %s request: %w", method, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("send %s request: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", method, resp.StatusCode)
+	}
+
+	// Slack reports API errors in the body with a 200 status
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("decode %s response: %w", method, err)
+	}
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return fmt.Errorf("decode %s response: %w", method, err)
+	}
+	if !result.OK {
+		return &APIError{Method: method, Code: result.Error}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("decode %s response: %w", method, err)
+		}
+	}
+	return nil
+}
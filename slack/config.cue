@@ -0,0 +1,5 @@
+package slack
+
+APIBaseURL: string | *"https://slack.com/api"
+TypingReaction: string | *"hourglass_flowing_sand"
+MaxRequestSkew: int | *300
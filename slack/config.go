@@ -0,0 +1,11 @@
+package slack
+
+import "encore.dev/config"
+
+type Config struct {
+	APIBaseURL     string // Slack Web API base URL, overridable for fakes
+	TypingReaction string // reaction added to a user message while bots are replying
+	MaxRequestSkew int    // maximum age of a signed request, in seconds
+}
+
+var cfg = config.Load[*Config]()
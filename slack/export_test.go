@@ -0,0 +1,27 @@
+package slack
+
+import (
+	"context"
+
+	"encore.app/chat/types"
+)
+
+// NewTestService creates a service calling the Web API at baseURL and sending
+// inbound messages to ingest, verifying requests with signingSecret
+func NewTestService(baseURL, signingSecret string, ingest func(ctx context.Context, msg *types.Message) (*types.Message, error)) *Service {
+	secrets.SlackSigningSecret = signingSecret
+	return &Service{
+		client: NewClient(baseURL, "xoxb-test", nil),
+		ingest: ingest,
+	}
+}
+
+// HandleResponse posts a bot reply, as the ChatResponses subscription does
+func (s *Service) HandleResponse(ctx context.Context, event *types.ResponseEvent) error {
+	return s.handleResponse(ctx, event)
+}
+
+// HandlePlatformEvent shows bot typing, as the PlatformEvents subscription does
+func (s *Service) HandlePlatformEvent(ctx context.Context, event *types.PlatformEvent) error {
+	return s.handlePlatformEvent(ctx, event)
+}
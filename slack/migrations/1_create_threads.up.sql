@@ -0,0 +1,10 @@
+-- Create threads table mapping Slack threads to chat conversations
+CREATE TABLE threads (
+    conversation_id TEXT PRIMARY KEY,
+    channel_id TEXT NOT NULL,
+    thread_ts TEXT NOT NULL,
+    last_user_ts TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (channel_id, thread_ts)
+);
@@ -0,0 +1,7 @@
+-- Create processed_events table recording the events each subscription has handled
+CREATE TABLE processed_events (
+    subscription TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (subscription, event_id)
+);
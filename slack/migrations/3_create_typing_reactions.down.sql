@@ -0,0 +1 @@
+DROP TABLE IF EXISTS typing_reactions;
@@ -0,0 +1,9 @@
+-- Create typing_reactions table recording the message each bot's typing reaction was added to
+CREATE TABLE typing_reactions (
+    message_id TEXT NOT NULL,
+    bot_id TEXT NOT NULL,
+    channel_id TEXT NOT NULL,
+    ts TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, bot_id)
+);
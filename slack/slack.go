@@ -0,0 +1,382 @@
+// Package slack connects Slack workspaces to the chat service through the
+// Events API for inbound messages and the Web API for bot replies.
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"encore.dev/pubsub"
+	"encore.dev/rlog"
+	"encore.dev/storage/sqldb"
+
+	"encore.app/chat"
+	chatpubsub "encore.app/chat/pubsub"
+	"encore.app/chat/types"
+)
+
+// Platform is the chat platform name used for Slack conversations
+const Platform = "slack"
+
+var secrets struct {
+	SlackSigningSecret string
+	SlackBotToken      string
+}
+
+// Define database
+var db = sqldb.NewDatabase("slack", sqldb.DatabaseConfig{
+	Migrations: "./migrations",
+})
+
+//encore:service
+type Service struct {
+	client *Client
+
+	// ingest sends a Slack user's message to the chat service
+	ingest func(ctx context.Context, msg *types.Message) (*types.Message, error)
+}
+
+// initService is automatically called by Encore when the service starts up.
+func initService() (*Service, error) {
+	return &Service{
+		client: NewClient(cfg.APIBaseURL, secrets.SlackBotToken, nil),
+		ingest: chat.IngestMessage,
+	}, nil
+}
+
+// Slack retry headers, sent when a delivery failed or wasn't answered in time
+const (
+	retryNumHeader    = "X-Slack-Retry-Num"
+	retryReasonHeader = "X-Slack-Retry-Reason"
+)
+
+// eventsLedger is the processed_events subscription of Events API deliveries
+const eventsLedger = "slack-events"
+
+// eventEnvelope represents an Events API request body
+type eventEnvelope struct {
+	Type      string        `json:"type"` // url_verification, event_callback
+	Challenge string        `json:"challenge,omitempty"`
+	TeamID    string        `json:"team_id,omitempty"`
+	EventID   string        `json:"event_id,omitempty"`
+	Event     *messageEvent `json:"event,omitempty"`
+}
+
+// messageEvent represents a Slack message event
+type messageEvent struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype,omitempty"`
+	Channel  string `json:"channel"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id,omitempty"`
+	Text     string `json:"text"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+}
+
+// thread represents a Slack thread mapped to a chat conversation
+type thread struct {
+	ConversationID string
+	ChannelID      string
+	ThreadTS       string
+	LastUserTS     string
+}
+
+// ServeEvents receives Slack Events API webhooks
+//
+//encore:api public raw method=POST path=/api/slack/events
+func (s *Service) ServeEvents(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	maxSkew := time.Duration(cfg.MaxRequestSkew) * time.Second
+	if err := VerifyRequest(secrets.SlackSigningSecret, req.Header, body, time.Now(), maxSkew); err != nil {
+		rlog.Warn("rejected slack request", "error", err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var envelope eventEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		http.Error(w, "Failed to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	switch envelope.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, envelope.Challenge)
+		return
+
+	case "event_callback":
+		if err := s.handleEvent(req.Context(), req.Header, &envelope); err != nil {
+			rlog.Error("Failed to handle slack event", "event_id", envelope.EventID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleEvent handles an event callback once. Slack retries a delivery that
+// failed or took too long to answer, so a retry of an event that was since
+// handled is acknowledged without handling it again.
+func (s *Service) handleEvent(ctx context.Context, header http.Header, envelope *eventEnvelope) error {
+	if retry := header.Get(retryNumHeader); retry != "" {
+		done, err := isProcessed(ctx, eventsLedger, envelope.EventID)
+		if err != nil {
+			return err
+		}
+		rlog.Info("slack event redelivered", "event_id", envelope.EventID,
+			"retry", retry, "reason", header.Get(retryReasonHeader), "processed", done)
+		if done {
+			return nil
+		}
+	}
+
+	if envelope.Event != nil && envelope.Event.Type == "message" {
+		if err := s.handleMessage(ctx, envelope.Event); err != nil {
+			return err
+		}
+	}
+	return markProcessed(ctx, eventsLedger, envelope.EventID)
+}
+
+// handleMessage forwards a user message from Slack to the chat service
+func (s *Service) handleMessage(ctx context.Context, ev *messageEvent) error {
+	// Ignore bot posts, including our own replies, and edits or joins
+	if ev.BotID != "" || ev.Subtype != "" || ev.User == "" {
+		return nil
+	}
+
+	// Top-level messages start a thread that bot replies are posted in
+	threadTS := ev.ThreadTS
+	if threadTS == "" {
+		threadTS = ev.TS
+	}
+
+	var conversationID string
+	err := db.QueryRow(ctx, `
+		SELECT conversation_id FROM threads
+		WHERE channel_id = $1 AND thread_ts = $2
+	`, ev.Channel, threadTS).Scan(&conversationID)
+	if err != nil && !errors.Is(err, sqldb.ErrNoRows) {
+		return fmt.Errorf("get thread: %w", err)
+	}
+
+	// Slack retries deliveries, so the message is deduped by its channel and timestamp
+	msg, err := s.ingest(ctx, &types.Message{
+		ConversationID:  conversationID,
+		ChannelID:       ev.Channel,
+		Platform:        Platform,
+		UserID:          ev.User,
+		Content:         ev.Text,
+		Type:            "text",
+		ClientMessageID: ev.Channel + ":" + ev.TS,
+	})
+	if err != nil {
+		return fmt.Errorf("send message: %w", err)
+	}
+
+	_, err = db.Exec(ctx, `
+		INSERT INTO threads (conversation_id, channel_id, thread_ts, last_user_ts)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (conversation_id) DO UPDATE
+		SET last_user_ts = EXCLUDED.last_user_ts, updated_at = NOW()
+	`, msg.ConversationID, ev.Channel, threadTS, ev.TS)
+	if err != nil {
+		return fmt.Errorf("store thread: %w", err)
+	}
+
+	return nil
+}
+
+// getThread retrieves the Slack thread of a conversation
+func getThread(ctx context.Context, conversationID string) (*thread, error) {
+	var t thread
+	err := db.QueryRow(ctx, `
+		SELECT conversation_id, channel_id, thread_ts, last_user_ts
+		FROM threads WHERE conversation_id = $1
+	`, conversationID).Scan(&t.ConversationID, &t.ChannelID, &t.ThreadTS, &t.LastUserTS)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Initialize subscriptions for bot responses and typing indicators
+var _ = pubsub.NewSubscription(
+	chatpubsub.ChatResponses, "handle-slack-responses",
+	pubsub.SubscriptionConfig[*types.ResponseEvent]{
+		Handler: pubsub.MethodHandler((*Service).handleResponse),
+	},
+)
+
+var _ = pubsub.NewSubscription(
+	chatpubsub.PlatformEvents, "handle-slack-typing",
+	pubsub.SubscriptionConfig[*types.PlatformEvent]{
+		Handler: pubsub.MethodHandler((*Service).handlePlatformEvent),
+	},
+)
+
+// handleResponse posts bot replies back to Slack. Redelivered events are
+// skipped, so a reply is posted once.
+func (s *Service) handleResponse(ctx context.Context, event *types.ResponseEvent) error {
+	// Only new Slack bot messages are posted
+	if event.Platform != Platform || event.Type != types.EventMessage || event.Message == nil {
+		return nil
+	}
+	if done, err := isProcessed(ctx, "handle-slack-responses", event.EventID); err != nil || done {
+		return err
+	}
+
+	// The thread is stored right after the inbound message is sent, so a
+	// missing thread is retried rather than dropped
+	t, err := getThread(ctx, event.Message.ConversationID)
+	if err != nil {
+		return fmt.Errorf("get thread for conversation %s: %w", event.Message.ConversationID, err)
+	}
+
+	params := &PostMessageParams{
+		Channel:  t.ChannelID,
+		Text:     event.Message.Content,
+		ThreadTS: t.ThreadTS,
+	}
+	if bot, err := chat.GetBot(ctx, event.Message.BotID); err == nil {
+		params.Username = bot.Name
+		params.IconURL = bot.Avatar
+	}
+	if _, err := s.client.PostMessage(ctx, params); err != nil {
+		return fmt.Errorf("post message: %w", err)
+	}
+
+	return markProcessed(ctx, "handle-slack-responses", event.EventID)
+}
+
+// isProcessed reports whether a subscription has already handled an event
+func isProcessed(ctx context.Context, subscription, eventID string) (bool, error) {
+	var done bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM processed_events WHERE subscription = $1 AND event_id = $2)
+	`, subscription, eventID).Scan(&done)
+	if err != nil {
+		return false, fmt.Errorf("check processed event: %w", err)
+	}
+	return done, nil
+}
+
+// markProcessed records that a subscription has handled an event
+func markProcessed(ctx context.Context, subscription, eventID string) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO processed_events (subscription, event_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, subscription, eventID)
+	if err != nil {
+		return fmt.Errorf("mark event processed: %w", err)
+	}
+	return nil
+}
+
+// handlePlatformEvent shows bot typing on Slack
+func (s *Service) handlePlatformEvent(ctx context.Context, event *types.PlatformEvent) error {
+	// Only bot typing is shown; Slack users are shown typing by Slack itself
+	if event.Platform != Platform || event.Type != types.EventTyping || event.Typing == nil || event.Typing.BotID == "" {
+		return nil
+	}
+
+	t, err := getThread(ctx, event.ConversationID)
+	if err != nil {
+		return fmt.Errorf("get thread for conversation %s: %w", event.ConversationID, err)
+	}
+
+	// Slack has no typing indicator for bots, so react to the message instead.
+	// The message reacted to is recorded, as the user may post again before
+	// the bot replies, and the reaction must be removed from the same message.
+	typing := &ReactionParams{
+		Channel:   t.ChannelID,
+		Timestamp: t.LastUserTS,
+		Name:      cfg.TypingReaction,
+	}
+	if event.Typing.MessageID != "" {
+		channel, ts, err := typingReaction(ctx, event.Typing.MessageID, event.Typing.BotID)
+		if err != nil {
+			return err
+		}
+		if ts != "" {
+			typing.Channel, typing.Timestamp = channel, ts
+		} else if !event.Typing.Active {
+			// Never reacted, or already removed
+			return nil
+		}
+	}
+
+	if event.Typing.Active {
+		if event.Typing.MessageID != "" {
+			if err := recordTypingReaction(ctx, event.Typing.MessageID, event.Typing.BotID, typing); err != nil {
+				return err
+			}
+		}
+		if err := s.client.AddReaction(ctx, typing); err != nil && !isAPIError(err, "already_reacted") {
+			return fmt.Errorf("add typing reaction: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.client.RemoveReaction(ctx, typing); err != nil && !isAPIError(err, "no_reaction") {
+		rlog.Warn("failed to remove typing reaction", "error", err)
+	}
+	if event.Typing.MessageID != "" {
+		_, err := db.Exec(ctx, `
+			DELETE FROM typing_reactions WHERE message_id = $1 AND bot_id = $2
+		`, event.Typing.MessageID, event.Typing.BotID)
+		if err != nil {
+			return fmt.Errorf("delete typing reaction: %w", err)
+		}
+	}
+	return nil
+}
+
+// typingReaction returns the Slack message a bot's typing reaction to a chat
+// message was added to, or "" if none was
+func typingReaction(ctx context.Context, messageID, botID string) (channel, ts string, err error) {
+	err = db.QueryRow(ctx, `
+		SELECT channel_id, ts FROM typing_reactions
+		WHERE message_id = $1 AND bot_id = $2
+	`, messageID, botID).Scan(&channel, &ts)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", fmt.Errorf("get typing reaction: %w", err)
+	}
+	return channel, ts, nil
+}
+
+// recordTypingReaction records the Slack message a bot's typing reaction to a
+// chat message is added to
+func recordTypingReaction(ctx context.Context, messageID, botID string, typing *ReactionParams) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO typing_reactions (message_id, bot_id, channel_id, ts)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (message_id, bot_id) DO NOTHING
+	`, messageID, botID, typing.Channel, typing.Timestamp)
+	if err != nil {
+		return fmt.Errorf("store typing reaction: %w", err)
+	}
+	return nil
+}
+
+// isAPIError reports whether err is a Slack API error with the given code
+func isAPIError(err error, code string) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
@@ -0,0 +1,264 @@
+package slack_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"encore.app/chat/types"
+	"encore.app/slack"
+	"encore.app/slack/slackfake"
+)
+
+const testSecret = "signing-secret"
+
+// fakeChat stands in for the chat service, starting a conversation for
+// messages sent without one
+type fakeChat struct {
+	mu       sync.Mutex
+	messages []*types.Message
+	err      error
+}
+
+func (f *fakeChat) ingest(_ context.Context, msg *types.Message) (*types.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	if msg.ConversationID == "" {
+		msg.ConversationID = fmt.Sprintf("conv_%s", uuid.New().String())
+	}
+	f.messages = append(f.messages, msg)
+	return msg, nil
+}
+
+// testEnv is a slack service wired to a fake Web API and chat service
+type testEnv struct {
+	api  *slackfake.Server
+	chat *fakeChat
+	svc  *slack.Service
+	url  string // events endpoint
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	api := slackfake.NewServer()
+	t.Cleanup(api.Close)
+	chat := &fakeChat{}
+	svc := slack.NewTestService(api.URL, testSecret, chat.ingest)
+	srv := httptest.NewServer(http.HandlerFunc(svc.ServeEvents))
+	t.Cleanup(srv.Close)
+	return &testEnv{api: api, chat: chat, svc: svc, url: srv.URL}
+}
+
+// post sends a signed Events API request, returning the status and body
+func (e *testEnv) post(t *testing.T, secret string, body []byte, header http.Header) (int, string) {
+	t.Helper()
+	req, err := slackfake.NewEventRequest(e.url, secret, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+// newTS returns a unique Slack message timestamp
+func newTS() string {
+	return fmt.Sprintf("%d.%06d", time.Now().Unix(), time.Now().Nanosecond()/1000)
+}
+
+func TestServeEvents(t *testing.T) {
+	botMessage := []byte(`{"type":"event_callback","event_id":"EvBot","event":{"type":"message","channel":"C1","bot_id":"B1","text":"hi","ts":"1.1"}}`)
+	tests := []struct {
+		name       string
+		secret     string
+		body       []byte
+		wantStatus int
+		wantBody   string
+		wantSent   int
+	}{
+		{"url verification", testSecret, []byte(`{"type":"url_verification","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`),
+			http.StatusOK, "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P", 0},
+		{"wrong signature", "other-secret", []byte(`{"type":"url_verification","challenge":"c"}`), http.StatusUnauthorized, "", 0},
+		{"malformed", testSecret, []byte(`{"type":`), http.StatusBadRequest, "", 0},
+		{"user message", testSecret, slackfake.MessageEvent("C1", "U1", "hello", newTS(), ""), http.StatusOK, "", 1},
+		{"bot message", testSecret, botMessage, http.StatusOK, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			status, body := env.post(t, tt.secret, tt.body, nil)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", status, tt.wantStatus, body)
+			}
+			if tt.wantBody != "" && body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if len(env.chat.messages) != tt.wantSent {
+				t.Errorf("sent %d messages, want %d", len(env.chat.messages), tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestRetriedEvents(t *testing.T) {
+	retry := http.Header{"X-Slack-Retry-Num": {"1"}, "X-Slack-Retry-Reason": {"http_timeout"}}
+	tests := []struct {
+		name        string
+		firstFails  bool
+		wantFirst   int
+		wantRetried int
+	}{
+		{"handled", false, http.StatusOK, 1},
+		{"first delivery failed", true, http.StatusInternalServerError, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			event := slackfake.MessageEvent("C1", "U1", "hello", newTS(), "")
+
+			if tt.firstFails {
+				env.chat.err = errors.New("chat unavailable")
+			}
+			if status, _ := env.post(t, testSecret, event, nil); status != tt.wantFirst {
+				t.Fatalf("first delivery status = %d, want %d", status, tt.wantFirst)
+			}
+			env.chat.err = nil
+
+			if status, _ := env.post(t, testSecret, event, retry); status != http.StatusOK {
+				t.Fatalf("retry status = %d", status)
+			}
+			if len(env.chat.messages) != tt.wantRetried {
+				t.Errorf("sent %d messages, want %d", len(env.chat.messages), tt.wantRetried)
+			}
+		})
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	channel := "C" + strings.ToUpper(uuid.New().String()[:8])
+
+	// A top-level message starts a thread, and replies in it join its conversation
+	rootTS := newTS()
+	if status, body := env.post(t, testSecret, slackfake.MessageEvent(channel, "U1", "hello", rootTS, ""), nil); status != http.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	replyTS := newTS()
+	if status, body := env.post(t, testSecret, slackfake.MessageEvent(channel, "U1", "more", replyTS, rootTS), nil); status != http.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	if len(env.chat.messages) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(env.chat.messages))
+	}
+	root, reply := env.chat.messages[0], env.chat.messages[1]
+	if reply.ConversationID != root.ConversationID {
+		t.Errorf("thread reply went to %s, want %s", reply.ConversationID, root.ConversationID)
+	}
+	if root.ClientMessageID != channel+":"+rootTS || root.Platform != slack.Platform || root.UserID != "U1" {
+		t.Errorf("message = %+v", root)
+	}
+
+	// The bot's reply is posted in the thread once, however often it's delivered
+	event := &types.ResponseEvent{
+		EventID:   uuid.New().String(),
+		Type:      types.EventMessage,
+		Platform:  slack.Platform,
+		ChannelID: channel,
+		Message:   &types.Message{ConversationID: root.ConversationID, Content: "hi there"},
+	}
+	for i := 0; i < 2; i++ {
+		if err := env.svc.HandleResponse(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	calls := env.api.Calls("chat.postMessage")
+	if len(calls) != 1 {
+		t.Fatalf("posted %d messages, want 1", len(calls))
+	}
+	if p := calls[0].Params; p["channel"] != channel || p["thread_ts"] != rootTS || p["text"] != "hi there" {
+		t.Errorf("posted %v", p)
+	}
+	if calls[0].Token != "xoxb-test" {
+		t.Errorf("token = %q", calls[0].Token)
+	}
+}
+
+func TestTypingReaction(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	channel := "C" + strings.ToUpper(uuid.New().String()[:8])
+
+	rootTS := newTS()
+	if status, body := env.post(t, testSecret, slackfake.MessageEvent(channel, "U1", "hello", rootTS, ""), nil); status != http.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	root := env.chat.messages[0]
+	typing := func(messageID string, active bool) *types.PlatformEvent {
+		return &types.PlatformEvent{
+			EventID:        uuid.New().String(),
+			Type:           types.EventTyping,
+			Platform:       slack.Platform,
+			ChannelID:      channel,
+			ConversationID: root.ConversationID,
+			Typing:         &types.Typing{BotID: "bot_1", MessageID: messageID, Active: active},
+		}
+	}
+
+	// Each step runs against the state the previous steps left
+	steps := []struct {
+		name   string
+		run    func() error
+		method string // reactions method the step calls, if any
+		wantTS string
+	}{
+		{"bot starts typing", func() error { return env.svc.HandlePlatformEvent(ctx, typing(root.ID, true)) }, "reactions.add", rootTS},
+		{"user posts again", func() error {
+			if status, body := env.post(t, testSecret, slackfake.MessageEvent(channel, "U1", "more", newTS(), rootTS), nil); status != http.StatusOK {
+				return fmt.Errorf("status = %d: %s", status, body)
+			}
+			return nil
+		}, "", ""},
+		{"bot stops typing", func() error { return env.svc.HandlePlatformEvent(ctx, typing(root.ID, false)) }, "reactions.remove", rootTS},
+		{"stop redelivered", func() error { return env.svc.HandlePlatformEvent(ctx, typing(root.ID, false)) }, "", ""},
+	}
+	for _, step := range steps {
+		before := len(env.api.Calls("reactions.add")) + len(env.api.Calls("reactions.remove"))
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		after := len(env.api.Calls("reactions.add")) + len(env.api.Calls("reactions.remove"))
+		if step.method == "" {
+			if after != before {
+				t.Errorf("%s: made %d reactions calls, want none", step.name, after-before)
+			}
+			continue
+		}
+		calls := env.api.Calls(step.method)
+		if len(calls) == 0 || after != before+1 {
+			t.Fatalf("%s: want one %s call", step.name, step.method)
+		}
+		if p := calls[len(calls)-1].Params; p["channel"] != channel || p["timestamp"] != step.wantTS {
+			t.Errorf("%s: %s %v, want timestamp %s", step.name, step.method, p, step.wantTS)
+		}
+	}
+}
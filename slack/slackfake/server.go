@@ -0,0 +1,129 @@
+// Package slackfake provides a local fake of the Slack Web API and helpers for
+// sending signed Events API requests, for exercising the slack service in tests.
+package slackfake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"encore.app/slack"
+)
+
+// Call records a Web API method invocation received by the fake
+type Call struct {
+	Method string
+	Token  string
+	Params map[string]interface{}
+}
+
+// Server is a fake Slack Web API server
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	calls    []Call
+	failures map[string]string
+	nextTS   int
+}
+
+// NewServer starts a fake Slack Web API server. Callers must Close it.
+func NewServer() *Server {
+	s := &Server{failures: make(map[string]string)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// FailWith makes subsequent calls to method fail with the given Slack error code
+func (s *Server) FailWith(method, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = code
+}
+
+// Calls returns the calls received so far, optionally filtered by method
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []Call
+	for _, c := range s.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (s *Server) handle(w http.ResponseWriter, req *http.Request) {
+	method := strings.TrimPrefix(req.URL.Path, "/")
+
+	var params map[string]interface{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{
+		Method: method,
+		Token:  strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "),
+		Params: params,
+	})
+	code, failing := s.failures[method]
+	s.nextTS++
+	ts := fmt.Sprintf("%d.%06d", time.Now().Unix(), s.nextTS)
+	s.mu.Unlock()
+
+	resp := map[string]interface{}{"ok": !failing}
+	if failing {
+		resp["error"] = code
+	} else if method == "chat.postMessage" {
+		resp["channel"] = params["channel"]
+		resp["ts"] = ts
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// MessageEvent builds an Events API event_callback body for a user message
+func MessageEvent(channel, user, text, ts, threadTS string) []byte {
+	event := map[string]interface{}{
+		"type":    "message",
+		"channel": channel,
+		"user":    user,
+		"text":    text,
+		"ts":      ts,
+	}
+	if threadTS != "" {
+		event["thread_ts"] = threadTS
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"type":     "event_callback",
+		"team_id":  "T00000000",
+		"event_id": "Ev" + strings.ReplaceAll(ts, ".", ""),
+		"event":    event,
+	})
+	return body
+}
+
+// NewEventRequest builds an Events API request signed with secret
+func NewEventRequest(url, secret string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", slack.Sign(secret, timestamp, body))
+	return req, nil
+}
@@ -0,0 +1,48 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Slack request signing headers
+const (
+	timestampHeader = "X-Slack-Request-Timestamp"
+	signatureHeader = "X-Slack-Signature"
+)
+
+// Sign computes the Slack request signature for a body sent at the given timestamp
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRequest checks the signature of an inbound Slack request.
+// Requests older than maxSkew are rejected to prevent replays.
+func VerifyRequest(secret string, header http.Header, body []byte, now time.Time, maxSkew time.Duration) error {
+	if secret == "" {
+		return fmt.Errorf("signing secret is not configured")
+	}
+
+	timestamp := header.Get(timestampHeader)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid request timestamp")
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > maxSkew || age < -maxSkew {
+		return fmt.Errorf("request timestamp outside allowed window")
+	}
+
+	expected := Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(signatureHeader))) {
+		return fmt.Errorf("invalid request signature")
+	}
+	return nil
+}
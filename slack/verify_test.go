@@ -0,0 +1,46 @@
+package slack
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyRequest(t *testing.T) {
+	const secret = "signing-secret"
+	body := []byte(`{"type":"event_callback"}`)
+	now := time.Unix(1700000000, 0)
+
+	signed := func(at time.Time, secret string, body []byte) http.Header {
+		ts := strconv.FormatInt(at.Unix(), 10)
+		h := http.Header{}
+		h.Set(timestampHeader, ts)
+		h.Set(signatureHeader, Sign(secret, ts, body))
+		return h
+	}
+	tests := []struct {
+		name    string
+		secret  string
+		header  http.Header
+		wantErr bool
+	}{
+		{"valid", secret, signed(now, secret, body), false},
+		{"within skew", secret, signed(now.Add(-4*time.Minute), secret, body), false},
+		{"no secret configured", "", signed(now, secret, body), true},
+		{"wrong secret", secret, signed(now, "other", body), true},
+		{"tampered body", secret, signed(now, secret, []byte(`{}`)), true},
+		{"stale", secret, signed(now.Add(-6*time.Minute), secret, body), true},
+		{"future", secret, signed(now.Add(6*time.Minute), secret, body), true},
+		{"no timestamp", secret, http.Header{signatureHeader: {Sign(secret, "", body)}}, true},
+		{"no signature", secret, http.Header{timestampHeader: {strconv.FormatInt(now.Unix(), 10)}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyRequest(tt.secret, tt.header, body, now, 5*time.Minute)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS vault_access_log;
+DROP TABLE IF EXISTS vault_records;
@@ -0,0 +1,23 @@
+CREATE TABLE vault_records (
+    id TEXT PRIMARY KEY,
+    subject TEXT NOT NULL,
+    key_id TEXT NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    ciphertext BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX vault_records_subject_idx ON vault_records (subject);
+
+-- Access log, kept after records are purged
+CREATE TABLE vault_access_log (
+    id BIGSERIAL PRIMARY KEY,
+    record_id TEXT,
+    subject TEXT NOT NULL,
+    action TEXT NOT NULL, -- store, reveal or purge
+    actor TEXT NOT NULL,
+    purpose TEXT NOT NULL,
+    accessed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX vault_access_log_subject_idx ON vault_access_log (subject, accessed_at);
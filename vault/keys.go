@@ -0,0 +1,131 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"encore.dev"
+	"encore.dev/rlog"
+)
+
+// KeyProvider wraps the data keys records are encrypted with, in the role
+// of a key management service. Key encryption keys never leave it.
+type KeyProvider interface {
+	// Wrap encrypts a data key with the current key encryption key
+	Wrap(dataKey []byte) (keyID string, wrapped []byte, err error)
+	// Unwrap decrypts a data key wrapped with the given key encryption key
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeys is a KeyProvider holding its key encryption keys in process,
+// standing in for a key management service. New data keys are wrapped with
+// the current key; older keys are kept to unwrap existing records.
+type LocalKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// ParseLocalKeys parses a comma-separated list of id:key pairs, each key a
+// base64 encoded 32 bytes. The first key is the current one.
+func ParseLocalKeys(spec string) (*LocalKeys, error) {
+	k := &LocalKeys{keys: make(map[string][]byte)}
+	for _, pair := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid key entry: expected id:key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %s must be 32 bytes", id)
+		}
+		if _, ok := k.keys[id]; ok {
+			return nil, fmt.Errorf("duplicate key id: %s", id)
+		}
+		if k.current == "" {
+			k.current = id
+		}
+		k.keys[id] = key
+	}
+	return k, nil
+}
+
+// Wrap encrypts a data key with the current key
+func (k *LocalKeys) Wrap(dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(k.keys[k.current], dataKey, []byte(k.current))
+	if err != nil {
+		return "", nil, fmt.Errorf("wrap data key: %w", err)
+	}
+	return k.current, wrapped, nil
+}
+
+// Unwrap decrypts a data key wrapped with the given key
+func (k *LocalKeys) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key: %s", keyID)
+	}
+	dataKey, err := open(key, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// loadKeys loads the vault's key encryption keys. Local environments
+// without keys use a fixed development key, which must never hold real data.
+func loadKeys() (KeyProvider, error) {
+	if secrets.VaultMasterKeys == "" && encore.Meta().Environment.Cloud == "local" {
+		rlog.Warn("no vault master keys set, using the development key")
+		key := sha256.Sum256([]byte("vault local development key"))
+		return &LocalKeys{current: "local", keys: map[string][]byte{"local": key[:]}}, nil
+	}
+
+	keys, err := ParseLocalKeys(secrets.VaultMasterKeys)
+	if err != nil {
+		return nil, fmt.Errorf("parse vault master keys: %w", err)
+	}
+	return keys, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM, prefixing the random nonce.
+// The additional data is authenticated but not encrypted.
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a ciphertext made by seal
+func open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
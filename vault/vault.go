@@ -0,0 +1,278 @@
+// Package vault stores personal data encrypted, so other services can hold
+// and pass around references to it instead. Each record is encrypted with
+// its own data key, which is stored wrapped by a key encryption key, and
+// every access is recorded in an audit log.
+package vault
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"encore.dev/beta/errs"
+	"encore.dev/storage/sqldb"
+	"github.com/google/uuid"
+)
+
+var db = sqldb.NewDatabase("vault", sqldb.DatabaseConfig{
+	Migrations: "db/migrations",
+})
+
+var secrets struct {
+	VaultMasterKeys string // comma-separated id:key pairs, current key first
+}
+
+// Access log actions
+const (
+	ActionStore  = "store"
+	ActionReveal = "reveal"
+	ActionPurge  = "purge"
+)
+
+//encore:service
+type Service struct {
+	keys KeyProvider
+}
+
+func initService() (*Service, error) {
+	keys, err := loadKeys()
+	if err != nil {
+		return nil, err
+	}
+	return &Service{keys: keys}, nil
+}
+
+// StoreParams represents the request for storing personal data
+type StoreParams struct {
+	Subject string            `json:"subject"` // what the data belongs to, such as a workflow ID
+	Fields  map[string]string `json:"fields"`
+	Actor   string            `json:"actor"` // who is storing it, for the access log
+	Purpose string            `json:"purpose"`
+}
+
+// StoreResponse represents the response for storing personal data
+type StoreResponse struct {
+	Ref string `json:"ref"` // reference to the record, safe to store and log
+}
+
+// Store encrypts and stores personal data, returning a reference to it
+//
+//encore:api private method=POST path=/vault/records
+func (s *Service) Store(ctx context.Context, p *StoreParams) (*StoreResponse, error) {
+	if p.Subject == "" || p.Actor == "" || p.Purpose == "" {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "subject, actor and purpose are required"}
+	}
+
+	plaintext, err := json.Marshal(p.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("marshal fields: %w", err)
+	}
+
+	ref := fmt.Sprintf("pii_%s", uuid.New().String())
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+	// Binding the ciphertext to its reference stops it being moved to another record
+	ciphertext, err := seal(dataKey, plaintext, []byte(ref))
+	if err != nil {
+		return nil, fmt.Errorf("encrypt record: %w", err)
+	}
+	keyID, wrappedKey, err := s.keys.Wrap(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO vault_records (id, subject, key_id, wrapped_key, ciphertext)
+		VALUES ($1, $2, $3, $4, $5)
+	`, ref, p.Subject, keyID, wrappedKey, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("insert record: %w", err)
+	}
+	if err := audit(ctx, tx, ref, p.Subject, ActionStore, p.Actor, p.Purpose); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return &StoreResponse{Ref: ref}, nil
+}
+
+// RevealParams represents the request for reading personal data
+type RevealParams struct {
+	Actor   string `json:"actor"`   // who is reading it, for the access log
+	Purpose string `json:"purpose"` // why it is needed, for the access log
+}
+
+// RevealResponse represents the decrypted personal data of a record
+type RevealResponse struct {
+	Fields map[string]string `json:"fields"`
+}
+
+// Reveal decrypts the personal data of a record. Every read is audited.
+//
+//encore:api private method=POST path=/vault/records/:ref/reveal
+func (s *Service) Reveal(ctx context.Context, ref string, p *RevealParams) (*RevealResponse, error) {
+	if p.Actor == "" || p.Purpose == "" {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "actor and purpose are required"}
+	}
+
+	var subject, keyID string
+	var wrappedKey, ciphertext []byte
+	err := db.QueryRow(ctx, `
+		SELECT subject, key_id, wrapped_key, ciphertext FROM vault_records WHERE id = $1
+	`, ref).Scan(&subject, &keyID, &wrappedKey, &ciphertext)
+	if err == sqldb.ErrNoRows {
+		return nil, &errs.Error{Code: errs.NotFound, Message: "record not found"}
+	} else if err != nil {
+		return nil, fmt.Errorf("get record: %w", err)
+	}
+
+	// Record the access before revealing anything
+	if err := audit(ctx, db, ref, subject, ActionReveal, p.Actor, p.Purpose); err != nil {
+		return nil, err
+	}
+
+	dataKey, err := s.keys.Unwrap(keyID, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(dataKey, ciphertext, []byte(ref))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt record: %w", err)
+	}
+
+	var fields map[string]string
+	if err := json.Unmarshal(plaintext, &fields); err != nil {
+		return nil, fmt.Errorf("unmarshal fields: %w", err)
+	}
+	return &RevealResponse{Fields: fields}, nil
+}
+
+// PurgeParams represents the request for deleting a subject's personal data
+type PurgeParams struct {
+	Subject string `json:"subject"`
+	Actor   string `json:"actor"`
+	Purpose string `json:"purpose"`
+}
+
+// PurgeResponse represents the response for deleting a subject's personal data
+type PurgeResponse struct {
+	Purged int `json:"purged"` // number of records deleted
+}
+
+// Purge deletes every record of a subject. The access log keeps that they
+// existed and were purged.
+//
+//encore:api private method=POST path=/vault/purge
+func (s *Service) Purge(ctx context.Context, p *PurgeParams) (*PurgeResponse, error) {
+	if p.Subject == "" || p.Actor == "" || p.Purpose == "" {
+		return nil, &errs.Error{Code: errs.InvalidArgument, Message: "subject, actor and purpose are required"}
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(ctx, `DELETE FROM vault_records WHERE subject = $1 RETURNING id`, p.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("delete records: %w", err)
+	}
+	var refs []string
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan record: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("delete records: %w", err)
+	}
+
+	for _, ref := range refs {
+		if err := audit(ctx, tx, ref, p.Subject, ActionPurge, p.Actor, p.Purpose); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return &PurgeResponse{Purged: len(refs)}, nil
+}
+
+// AccessLogEntry is a recorded access to personal data
+type AccessLogEntry struct {
+	RecordID   string    `json:"record_id"`
+	Action     string    `json:"action"` // store, reveal or purge
+	Actor      string    `json:"actor"`
+	Purpose    string    `json:"purpose"`
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+// AccessLogResponse represents a subject's access log
+type AccessLogResponse struct {
+	Entries []*AccessLogEntry `json:"entries"`
+}
+
+// GetAccessLog returns every recorded access to a subject's personal data,
+// oldest first
+//
+//encore:api private method=GET path=/vault/subjects/:subject/access-log
+func (s *Service) GetAccessLog(ctx context.Context, subject string) (*AccessLogResponse, error) {
+	rows, err := db.Query(ctx, `
+		SELECT COALESCE(record_id, ''), action, actor, purpose, accessed_at
+		FROM vault_access_log
+		WHERE subject = $1
+		ORDER BY accessed_at ASC, id ASC
+	`, subject)
+	if err != nil {
+		return nil, fmt.Errorf("list access log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*AccessLogEntry{}
+	for rows.Next() {
+		var e AccessLogEntry
+		if err := rows.Scan(&e.RecordID, &e.Action, &e.Actor, &e.Purpose, &e.AccessedAt); err != nil {
+			return nil, fmt.Errorf("scan access log: %w", err)
+		}
+		entries = append(entries, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list access log: %w", err)
+	}
+	return &AccessLogResponse{Entries: entries}, nil
+}
+
+// execer is implemented by both *sqldb.Database and *sqldb.Tx
+type execer interface {
+	Exec(ctx context.Context, query string, args ...any) (sqldb.ExecResult, error)
+}
+
+// audit records an access to personal data
+func audit(ctx context.Context, e execer, ref, subject, action, actor, purpose string) error {
+	_, err := e.Exec(ctx, `
+		INSERT INTO vault_access_log (record_id, subject, action, actor, purpose)
+		VALUES ($1, $2, $3, $4, $5)
+	`, ref, subject, action, actor, purpose)
+	if err != nil {
+		return fmt.Errorf("record access: %w", err)
+	}
+	return nil
+}